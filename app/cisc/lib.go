@@ -175,10 +175,10 @@ func getGroup(c *cli.Context) *config.Group {
 	log.ErrFatal(err)
 	defer gr.Close()
 	groups, err := config.ReadGroupDescToml(gr)
-	log.ErrFatal(err)
-	if groups == nil || groups.Roster == nil || len(groups.Roster.List) == 0 {
+	if err == config.ErrEmptyRoster {
 		log.Fatal("No servers found in roster from", gfile)
 	}
+	log.ErrFatal(err)
 	return groups
 }
 
@@ -59,6 +59,10 @@ func main() {
 			Value: 0,
 			Usage: "debug-level: 1 for terse, 5 for maximal",
 		},
+		cli.DurationFlag{
+			Name:  "max-runtime",
+			Usage: "stop the server after this time, e.g. 24h - 0 runs forever",
+		},
 	}
 
 	cliApp.Commands = []cli.Command{
@@ -85,6 +89,7 @@ func main() {
 			},
 			Flags: serverFlags,
 		},
+		server.CommandInfo,
 	}
 	cliApp.Flags = serverFlags
 	// default action
@@ -110,7 +115,17 @@ func runServer(ctx *cli.Context) {
 	if err != nil {
 		log.Fatal("Couldn't parse config:", err)
 	}
-	conode.Start()
+	defer server.CloseOnSignal(conode)()
+	maxRuntime := ctx.Duration("max-runtime")
+	if maxRuntime == 0 {
+		conode.Start()
+		return
+	}
+	go conode.Start()
+	if err := conode.WaitForCloseTimeout(maxRuntime); err != nil {
+		log.Lvl1("Stopping server after", maxRuntime)
+		log.ErrFatal(conode.Close())
+	}
 }
 
 func getDefaultConfigFile() string {
@@ -3,11 +3,17 @@ package config
 import (
 	"bufio"
 	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"strings"
+	"time"
 
 	"os/user"
 
@@ -18,6 +24,7 @@ import (
 	"github.com/dedis/cothority/sda"
 	"github.com/dedis/crypto/abstract"
 	"github.com/dedis/crypto/config"
+	"gopkg.in/yaml.v2"
 )
 
 var in *bufio.Reader
@@ -28,11 +35,59 @@ func init() {
 	out = os.Stdout
 }
 
+// ErrEmptyRoster is returned when a group-definition doesn't hold any
+// server.
+var ErrEmptyRoster = errors.New("No servers found in roster")
+
+// MalformedConfigError is returned when a configuration or a
+// group-definition can't be decoded or holds invalid values. Errors of
+// the underlying reader, like a missing file, are returned as is.
+type MalformedConfigError struct {
+	// Format is the format of the definition, e.g. GroupFormatToml.
+	Format string
+	// Cause is the error of the decoder.
+	Cause error
+}
+
+// Error implements the error-interface.
+func (e *MalformedConfigError) Error() string {
+	return fmt.Sprintf("Malformed %s configuration: %s", e.Format, e.Cause)
+}
+
+// malformed returns err as a MalformedConfigError.
+func malformed(format string, err error) error {
+	return &MalformedConfigError{Format: format, Cause: err}
+}
+
 // CothoritydConfig is the configuration structure of the cothority daemon.
 type CothoritydConfig struct {
 	Public  string
 	Private string
 	Address network.Address
+	// The following fields are only used if Address is of type tls.
+	// TLSCert and TLSKey are the PEM-files of the certificate of this
+	// conode, TLSCA is the PEM-file with the certificates used to verify
+	// the other conodes. If TLSVerifyClient is true, conodes without a
+	// certificate signed by TLSCA are refused.
+	TLSCert         string `toml:",omitempty"`
+	TLSKey          string `toml:",omitempty"`
+	TLSCA           string `toml:",omitempty"`
+	TLSVerifyClient bool   `toml:",omitempty"`
+	// MACKey is an optional hex-encoded key shared by all conodes. If set,
+	// all packets are authenticated with it, see network.SetMACKey.
+	MACKey string `toml:",omitempty"`
+	// MaxConnections and IdleTimeout limit the connections kept open to
+	// other conodes, see network.PoolConfig. KeepAlive is the interval of
+	// the TCP keep-alive messages. IdleTimeout and KeepAlive are durations
+	// like "5m".
+	MaxConnections int    `toml:",omitempty"`
+	IdleTimeout    string `toml:",omitempty"`
+	KeepAlive      string `toml:",omitempty"`
+	// Compression is the size in bytes from which the packets sent to
+	// other conodes are compressed, see network.Router.SetCompression. It
+	// should only be set if all other conodes understand compressed
+	// packets.
+	Compression int `toml:",omitempty"`
 }
 
 // Save will save this CothoritydConfig to the given file name. It
@@ -52,23 +107,88 @@ func (hc *CothoritydConfig) Save(file string) error {
 
 // ParseCothorityd parses the config file into a CothoritydConfig.
 // It returns the CothoritydConfig, the Host so we can already use it, and an error if
-// the file is inaccessible or has wrong values in it.
+// the file is inaccessible or has wrong values in it, in which case it is a
+// *MalformedConfigError.
 func ParseCothorityd(file string) (*CothoritydConfig, *sda.Conode, error) {
-	hc := &CothoritydConfig{}
-	_, err := toml.DecodeFile(file, hc)
+	f, err := os.Open(file)
 	if err != nil {
 		return nil, nil, err
 	}
+	defer f.Close()
+	return ParseCothoritydReader(f)
+}
+
+// ParseCothoritydReader works like ParseCothorityd, but reads the
+// configuration from r.
+func ParseCothoritydReader(r io.Reader) (*CothoritydConfig, *sda.Conode, error) {
+	hc := &CothoritydConfig{}
+	_, err := toml.DecodeReader(r, hc)
+	if err != nil {
+		return nil, nil, malformed(GroupFormatToml, err)
+	}
 	// Try to decode the Hex values
 	secret, err := crypto.ReadScalarHex(network.Suite, hc.Private)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, malformed(GroupFormatToml,
+			fmt.Errorf("Invalid Private: %s", err))
 	}
 	point, err := crypto.ReadPubHex(network.Suite, hc.Public)
 	if err != nil {
-		return nil, nil, err
+		return nil, nil, malformed(GroupFormatToml,
+			fmt.Errorf("Invalid Public: %s", err))
+	}
+	if hc.MACKey != "" {
+		key, err := hex.DecodeString(hc.MACKey)
+		if err != nil {
+			return nil, nil, malformed(GroupFormatToml,
+				fmt.Errorf("Invalid MACKey: %s", err))
+		}
+		network.SetMACKey(key)
+	}
+	pool := network.PoolConfig{MaxConnections: hc.MaxConnections}
+	if hc.IdleTimeout != "" {
+		pool.IdleTimeout, err = time.ParseDuration(hc.IdleTimeout)
+		if err != nil {
+			return nil, nil, malformed(GroupFormatToml,
+				fmt.Errorf("Invalid IdleTimeout: %s", err))
+		}
+	}
+	if hc.KeepAlive != "" {
+		keepAlive, err := time.ParseDuration(hc.KeepAlive)
+		if err != nil {
+			return nil, nil, malformed(GroupFormatToml,
+				fmt.Errorf("Invalid KeepAlive: %s", err))
+		}
+		network.SetKeepAlive(keepAlive)
 	}
-	conode := sda.NewConodeTCP(network.NewServerIdentity(point, hc.Address), secret)
+	si := network.NewServerIdentity(point, hc.Address)
+	var conode *sda.Conode
+	switch hc.Address.ConnType() {
+	case network.PlainTCP:
+		conode = sda.NewConodeTCP(si, secret)
+	case network.TLS:
+		if hc.TLSCert == "" || hc.TLSKey == "" || hc.TLSCA == "" {
+			return nil, nil, malformed(GroupFormatToml,
+				errors.New("TLSCert, TLSKey and TLSCA are needed for a tls-address"))
+		}
+		conf, err := network.LoadTLSConfig(TildeToHome(hc.TLSCert),
+			TildeToHome(hc.TLSKey), TildeToHome(hc.TLSCA), hc.TLSVerifyClient)
+		if err != nil {
+			return nil, nil, err
+		}
+		if conode, err = sda.NewConodeTLS(si, secret, conf); err != nil {
+			return nil, nil, err
+		}
+	case network.Unix:
+		if conode, err = sda.NewConodeUnix(si, secret); err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, malformed(GroupFormatToml,
+			fmt.Errorf("Unsupported address %s", hc.Address))
+	}
+	conode.SetPool(pool)
+	conode.SetCompression(hc.Compression)
 	return hc, conode, nil
 }
 
@@ -148,15 +268,64 @@ func (g *Group) GetDescription(e *network.ServerIdentity) string {
 	return g.description[e]
 }
 
+// The formats a group-definition can be read from.
+const (
+	GroupFormatToml = "toml"
+	GroupFormatJSON = "json"
+	GroupFormatYAML = "yaml"
+)
+
+// GroupFormat returns the format of a group-definition file depending on
+// its extension. Files with an unknown extension are supposed to be TOML.
+func GroupFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".json":
+		return GroupFormatJSON
+	case ".yaml", ".yml":
+		return GroupFormatYAML
+	}
+	return GroupFormatToml
+}
+
 // ReadGroupDescToml reads a group.toml file and returns the list of ServerIdentities
 // and descriptions in the file.
 // If the file couldn't be decoded or doesn't hold valid ServerIdentities,
-// an error is returned.
+// a *MalformedConfigError is returned, if it is empty, ErrEmptyRoster.
 func ReadGroupDescToml(f io.Reader) (*Group, error) {
+	return ReadGroupDesc(f, GroupFormatToml)
+}
+
+// ReadGroupDesc reads a group-definition in the given format, one of
+// GroupFormatToml, GroupFormatJSON or GroupFormatYAML, and returns the list
+// of ServerIdentities and descriptions. The fields are the same in all
+// formats.
+// If the definition couldn't be decoded or holds invalid ServerIdentities, a
+// *MalformedConfigError is returned. If it doesn't hold any server,
+// ErrEmptyRoster is returned.
+func ReadGroupDesc(f io.Reader, format string) (*Group, error) {
 	group := &GroupToml{}
-	_, err := toml.DecodeReader(f, group)
-	if err != nil {
-		return nil, err
+	switch format {
+	case GroupFormatToml:
+		if _, err := toml.DecodeReader(f, group); err != nil {
+			return nil, malformed(format, err)
+		}
+	case GroupFormatJSON:
+		if err := json.NewDecoder(f).Decode(group); err != nil {
+			return nil, malformed(format, err)
+		}
+	case GroupFormatYAML:
+		buf, err := ioutil.ReadAll(f)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(buf, group); err != nil {
+			return nil, malformed(format, err)
+		}
+	default:
+		return nil, fmt.Errorf("Unknown format of group-definition: %s", format)
+	}
+	if len(group.Servers) == 0 {
+		return nil, ErrEmptyRoster
 	}
 	// convert from ServerTomls to entities
 	var entities = make([]*network.ServerIdentity, len(group.Servers))
@@ -164,7 +333,8 @@ func ReadGroupDescToml(f io.Reader) (*Group, error) {
 	for i, s := range group.Servers {
 		en, err := s.toServerIdentity(network.Suite)
 		if err != nil {
-			return nil, err
+			return nil, malformed(format, fmt.Errorf("Invalid server %s: %s",
+				s.Address, err))
 		}
 		entities[i] = en
 		descs[en] = s.Description
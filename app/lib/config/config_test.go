@@ -5,13 +5,17 @@ import (
 	"bytes"
 	"strings"
 	"testing"
+	"time"
 
 	"io/ioutil"
 
 	"os"
+	"path"
 
+	"github.com/dedis/cothority/crypto"
 	"github.com/dedis/cothority/log"
 	"github.com/dedis/cothority/network"
+	"github.com/dedis/crypto/config"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -56,6 +60,67 @@ func TestReadGroupDescToml(t *testing.T) {
 	}
 }
 
+var serverGroupJSON = `{
+  "Description": "Default Dedis Cosi servers",
+  "Servers": [
+    {
+      "Address": "tcp://5.135.161.91:2000",
+      "Public": "lLglU3nhHfUWe4p647hffn618TiUq+6FvTGzJw8eTGU=",
+      "Description": "Nikkolasg's server: spreading the love of signing"
+    },
+    {
+      "Address": "tcp://185.26.156.40:61117",
+      "Public": "apIWOKSt6JcOvNnjcVcPCNcaJJh/kPEjkbn2xSW+W+Q=",
+      "Description": "Ismail's server"
+    }
+  ]
+}`
+
+var serverGroupYAML = `description: Default Dedis Cosi servers
+servers:
+  - address: tcp://5.135.161.91:2000
+    public: lLglU3nhHfUWe4p647hffn618TiUq+6FvTGzJw8eTGU=
+    description: "Nikkolasg's server: spreading the love of signing"
+  - address: tcp://185.26.156.40:61117
+    public: apIWOKSt6JcOvNnjcVcPCNcaJJh/kPEjkbn2xSW+W+Q=
+    description: Ismail's server
+`
+
+func TestReadGroupDesc(t *testing.T) {
+	for format, desc := range map[string]string{
+		GroupFormatToml: serverGroup,
+		GroupFormatJSON: serverGroupJSON,
+		GroupFormatYAML: serverGroupYAML,
+	} {
+		group, err := ReadGroupDesc(strings.NewReader(desc), format)
+		require.Nil(t, err, format)
+		require.Equal(t, 2, len(group.Roster.List), format)
+		assert.Equal(t, network.NewTCPAddress("5.135.161.91:2000"),
+			group.Roster.List[0].Address, format)
+		assert.Equal(t, "Ismail's server",
+			group.GetDescription(group.Roster.List[1]), format)
+	}
+
+	_, err := ReadGroupDesc(strings.NewReader(`Description = "empty"`),
+		GroupFormatToml)
+	assert.Equal(t, ErrEmptyRoster, err)
+	_, err = ReadGroupDesc(strings.NewReader("{}"), GroupFormatJSON)
+	assert.Equal(t, ErrEmptyRoster, err)
+	_, err = ReadGroupDesc(strings.NewReader("{"), GroupFormatJSON)
+	require.IsType(t, &MalformedConfigError{}, err)
+	assert.Equal(t, GroupFormatJSON, err.(*MalformedConfigError).Format)
+	_, err = ReadGroupDesc(strings.NewReader(strings.Replace(serverGroup,
+		"lLglU3nhHfUWe4p647hffn618TiUq", "invalid", 1)), GroupFormatToml)
+	assert.IsType(t, &MalformedConfigError{}, err)
+	_, err = ReadGroupDesc(strings.NewReader(serverGroup), "xml")
+	assert.NotNil(t, err)
+
+	assert.Equal(t, GroupFormatToml, GroupFormat("group.toml"))
+	assert.Equal(t, GroupFormatJSON, GroupFormat("/tmp/group.JSON"))
+	assert.Equal(t, GroupFormatYAML, GroupFormat("group.yml"))
+	assert.Equal(t, GroupFormatToml, GroupFormat("group"))
+}
+
 func TestInput(t *testing.T) {
 	setInput("Y")
 	assert.Equal(t, "Y", Input("def", "Question"))
@@ -94,6 +159,128 @@ func TestCopy(t *testing.T) {
 	log.ErrFatal(os.Remove(ndst))
 }
 
+func TestParseCothoritydTLS(t *testing.T) {
+	kp := config.NewKeyPair(network.Suite)
+	priv, err := crypto.ScalarHex(network.Suite, kp.Secret)
+	log.ErrFatal(err)
+	pub, err := crypto.PubHex(network.Suite, kp.Public)
+	log.ErrFatal(err)
+	tmp, err := ioutil.TempFile("", "cothorityd")
+	log.ErrFatal(err)
+	log.ErrFatal(tmp.Close())
+	defer os.Remove(tmp.Name())
+
+	hc := &CothoritydConfig{
+		Public:  pub,
+		Private: priv,
+		Address: network.NewTLSAddress("127.0.0.1:2000"),
+	}
+	log.ErrFatal(hc.Save(tmp.Name()))
+	_, _, err = ParseCothorityd(tmp.Name())
+	assert.NotNil(t, err, "tls without certificate should fail")
+
+	hc.TLSCert = tmp.Name() + ".missing"
+	hc.TLSKey = tmp.Name() + ".missing"
+	log.ErrFatal(hc.Save(tmp.Name()))
+	_, _, err = ParseCothorityd(tmp.Name())
+	assert.NotNil(t, err, "tls without CA should fail")
+
+	hc.TLSCA = tmp.Name() + ".missing"
+	log.ErrFatal(hc.Save(tmp.Name()))
+	_, _, err = ParseCothorityd(tmp.Name())
+	assert.NotNil(t, err, "tls with missing certificate should fail")
+}
+
+func TestParseCothoritydReader(t *testing.T) {
+	kp := config.NewKeyPair(network.Suite)
+	priv, err := crypto.ScalarHex(network.Suite, kp.Secret)
+	log.ErrFatal(err)
+	pub, err := crypto.PubHex(network.Suite, kp.Public)
+	log.ErrFatal(err)
+	tmp, err := ioutil.TempFile("", "cothorityd")
+	log.ErrFatal(err)
+	log.ErrFatal(tmp.Close())
+	defer os.Remove(tmp.Name())
+
+	hc := &CothoritydConfig{
+		Public:  pub,
+		Private: priv,
+		Address: network.NewTCPAddress("127.0.0.1:2002"),
+	}
+	log.ErrFatal(hc.Save(tmp.Name()))
+	buf, err := ioutil.ReadFile(tmp.Name())
+	log.ErrFatal(err)
+	conf, conode, err := ParseCothoritydReader(bytes.NewReader(buf))
+	log.ErrFatal(err)
+	assert.Equal(t, hc.Address, conf.Address)
+	assert.Equal(t, hc.Address, conode.ServerIdentity.Address)
+	log.ErrFatal(conode.Close())
+
+	hc.MACKey = "0102"
+	log.ErrFatal(hc.Save(tmp.Name()))
+	_, conode, err = ParseCothorityd(tmp.Name())
+	log.ErrFatal(err)
+	assert.Equal(t, []byte{1, 2}, network.MACKey())
+	network.SetMACKey(nil)
+	log.ErrFatal(conode.Close())
+	hc.MACKey = ""
+	hc.MaxConnections = 10
+	hc.IdleTimeout = "5m"
+	hc.Compression = network.DefaultCompressionThreshold
+	log.ErrFatal(hc.Save(tmp.Name()))
+	_, conode, err = ParseCothorityd(tmp.Name())
+	log.ErrFatal(err)
+	assert.Equal(t, network.PoolConfig{MaxConnections: 10,
+		IdleTimeout: 5 * time.Minute}, conode.Pool())
+	assert.Equal(t, network.DefaultCompressionThreshold, conode.Compression())
+	log.ErrFatal(conode.Close())
+	hc.Compression = 0
+	hc.IdleTimeout = "5 minutes"
+	log.ErrFatal(hc.Save(tmp.Name()))
+	_, _, err = ParseCothorityd(tmp.Name())
+	assert.IsType(t, &MalformedConfigError{}, err, "invalid IdleTimeout should fail")
+	hc.IdleTimeout = ""
+	hc.MACKey = "not hex"
+	log.ErrFatal(hc.Save(tmp.Name()))
+	_, _, err = ParseCothorityd(tmp.Name())
+	assert.IsType(t, &MalformedConfigError{}, err, "invalid MACKey should fail")
+
+	_, _, err = ParseCothoritydReader(strings.NewReader("Address = "))
+	assert.IsType(t, &MalformedConfigError{}, err, "invalid toml should fail")
+	assert.Contains(t, err.Error(), "Malformed toml configuration")
+	_, _, err = ParseCothorityd(tmp.Name() + ".missing")
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestParseCothoritydUnix(t *testing.T) {
+	kp := config.NewKeyPair(network.Suite)
+	priv, err := crypto.ScalarHex(network.Suite, kp.Secret)
+	log.ErrFatal(err)
+	pub, err := crypto.PubHex(network.Suite, kp.Public)
+	log.ErrFatal(err)
+	dir, err := ioutil.TempDir("", "cothorityd")
+	log.ErrFatal(err)
+	defer os.RemoveAll(dir)
+
+	hc := &CothoritydConfig{
+		Public:  pub,
+		Private: priv,
+		Address: network.NewUnixAddress(path.Join(dir, "conode.sock")),
+	}
+	file := path.Join(dir, "private.toml")
+	log.ErrFatal(hc.Save(file))
+	conf, conode, err := ParseCothorityd(file)
+	log.ErrFatal(err)
+	assert.Equal(t, hc.Address, conf.Address)
+	assert.Equal(t, hc.Address, conode.ServerIdentity.Address)
+	log.ErrFatal(conode.Close())
+
+	hc.Address = network.Address("purb://127.0.0.1:2002")
+	log.ErrFatal(hc.Save(file))
+	_, _, err = ParseCothorityd(file)
+	assert.IsType(t, &MalformedConfigError{}, err, "purb-address should fail")
+}
+
 func setInput(s string) {
 	// Flush output
 	getOutput()
@@ -0,0 +1,32 @@
+package server
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/dedis/cothority/sda"
+	"gopkg.in/codegangsta/cli.v1"
+)
+
+// CommandInfo prints the services and protocols compiled into the binary.
+// Apps can add it to their commands, so an operator can check why a service
+// is missing.
+var CommandInfo = cli.Command{
+	Name:  "info",
+	Usage: "Print the services and protocols compiled into this binary",
+	Action: func(c *cli.Context) error {
+		Info(os.Stdout)
+		return nil
+	},
+}
+
+// Info writes the names of all registered services and protocols to w.
+func Info(w io.Writer) {
+	services := sda.ServiceFactory.RegisteredServiceNames()
+	sort.Strings(services)
+	fmt.Fprintln(w, "Services:", strings.Join(services, ", "))
+	fmt.Fprintln(w, "Protocols:", strings.Join(sda.RegisteredProtocols(), ", "))
+}
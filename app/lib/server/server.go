@@ -17,6 +17,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/dedis/cothority/app/lib/config"
 	"github.com/dedis/cothority/crypto"
 	"github.com/dedis/cothority/log"
@@ -143,7 +144,8 @@ func InteractiveConfig(binaryName string) {
 	}
 
 	// create the keys
-	privStr, pubStr := createKeyPair()
+	privStr, pubStr, err := createKeyPair()
+	log.ErrFatal(err)
 	conf := &config.CothoritydConfig{
 		Public:  pubStr,
 		Private: privStr,
@@ -187,6 +189,42 @@ func InteractiveConfig(binaryName string) {
 	log.Info("All configurations saved, ready to serve signatures now.")
 }
 
+// NonInteractiveConfig creates the same configuration as InteractiveConfig,
+// but takes the values as arguments: addr is the address in the form
+// "ip:port" where the server listens and can be reached, and description is
+// the description in the group-definition. The configuration is written to
+// out and can be read by config.ParseCothorityd. The group-definition
+// snippet for the server is printed on the screen.
+func NonInteractiveConfig(name, addr, description string, out io.Writer) error {
+	log.Lvl1("Setting up a", name, "server.")
+	address := network.NewTCPAddress(addr)
+	if !address.Valid() {
+		return fmt.Errorf("Invalid address: %s", addr)
+	}
+	privStr, pubStr, err := createKeyPair()
+	if err != nil {
+		return err
+	}
+	conf := &config.CothoritydConfig{
+		Public:  pubStr,
+		Private: privStr,
+		Address: address,
+	}
+	if err := toml.NewEncoder(out).Encode(conf); err != nil {
+		return err
+	}
+
+	public, err := crypto.ReadPubHex(network.Suite, pubStr)
+	if err != nil {
+		return err
+	}
+	server := config.NewServerToml(network.Suite, public, address)
+	server.Description = description
+	log.Info("Group definition snippet for your server:\n" +
+		config.NewGroupToml(server).String())
+	return nil
+}
+
 // CheckConfig contacts all servers and verifies if it receives a valid
 // signature from each.
 // If the roster is empty it will return an error.
@@ -194,12 +232,8 @@ func InteractiveConfig(binaryName string) {
 func CheckConfig(tomlFileName string) error {
 	f, err := os.Open(tomlFileName)
 	log.ErrFatal(err, "Couldn't open group definition file")
-	group, err := config.ReadGroupDescToml(f)
+	group, err := config.ReadGroupDesc(f, config.GroupFormat(tomlFileName))
 	log.ErrFatal(err, "Error while reading group definition file", err)
-	if len(group.Roster.List) == 0 {
-		log.ErrFatalf(err, "Empty entity or invalid group defintion in: %s",
-			tomlFileName)
-	}
 	log.Info("Checking the availability and responsiveness of the servers in the group...")
 	return CheckServers(group)
 }
@@ -351,12 +385,12 @@ func checkOverwrite(file string) bool {
 }
 
 // createKeyPair returns the private and public key in hexadecimal representation.
-func createKeyPair() (string, string) {
+func createKeyPair() (string, string, error) {
 	log.Info("Creating ed25519 private and public keys.")
 	kp := crypconf.NewKeyPair(network.Suite)
 	privStr, err := crypto.ScalarHex(network.Suite, kp.Secret)
 	if err != nil {
-		log.Fatal("Error formating private key to hexadecimal. Abort.")
+		return "", "", errors.New("Error formating private key to hexadecimal")
 	}
 	var point abstract.Point
 	// use the transformation for EdDSA signatures
@@ -364,11 +398,11 @@ func createKeyPair() (string, string) {
 	point = kp.Public
 	pubStr, err := crypto.PubHex(network.Suite, point)
 	if err != nil {
-		log.Fatal("Could not parse public key. Abort.")
+		return "", "", errors.New("Could not parse public key")
 	}
 
 	log.Info("Public key: ", pubStr, "\n")
-	return privStr, pubStr
+	return privStr, pubStr, nil
 }
 
 // saveFiles takes a CothoritydConfig and its filename, and a GroupToml and its filename,
@@ -0,0 +1,70 @@
+package server
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/dedis/cothority/app/lib/config"
+	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/sda"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMain(m *testing.M) {
+	log.MainTest(m)
+}
+
+func TestNonInteractiveConfig(t *testing.T) {
+	var out bytes.Buffer
+	require.NotNil(t, NonInteractiveConfig("cothorityd", "no-port", "", &out))
+
+	err := NonInteractiveConfig("cothorityd", "127.0.0.1:2000", "test", &out)
+	require.Nil(t, err)
+	tmp, err := ioutil.TempFile("", "config.toml")
+	require.Nil(t, err)
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(out.Bytes())
+	require.Nil(t, err)
+	tmp.Close()
+
+	conf, conode, err := config.ParseCothorityd(tmp.Name())
+	require.Nil(t, err)
+	assert.Equal(t, "tcp://127.0.0.1:2000", string(conf.Address))
+	assert.Equal(t, conf.Address, conode.ServerIdentity.Address)
+}
+
+func TestCloseOnSignal(t *testing.T) {
+	conode := sda.NewLocalConode(0)
+	saved := make(chan bool, 1)
+	stop := CloseOnSignal(conode, func() { saved <- true })
+	defer stop()
+
+	require.Nil(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	require.Nil(t, conode.WaitForCloseTimeout(time.Second))
+	select {
+	case <-saved:
+	case <-time.After(time.Second):
+		t.Fatal("atExit-function not called")
+	}
+
+	// Removing the handler twice must not panic
+	stop()
+}
+
+func TestInfo(t *testing.T) {
+	sda.GlobalProtocolRegister("InfoTest", func(n *sda.TreeNodeInstance) (sda.ProtocolInstance, error) {
+		return nil, nil
+	})
+	var out bytes.Buffer
+	Info(&out)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Equal(t, 2, len(lines))
+	assert.True(t, strings.HasPrefix(lines[0], "Services:"))
+	assert.Contains(t, lines[1], "InfoTest")
+}
@@ -0,0 +1,44 @@
+package server
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+
+	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/sda"
+)
+
+// CloseOnSignal closes the conode once the process receives SIGINT or
+// SIGTERM and then calls the atExit-functions, e.g. to save the state of the
+// application. This lets conode.Start return, so that the binary can exit
+// normally. The returned function removes the signal handler and, if a
+// signal is being handled, waits for the atExit-functions to finish. It
+// should be called before the program exits.
+func CloseOnSignal(conode *sda.Conode, atExit ...func()) func() {
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM)
+	done := make(chan bool)
+	finished := make(chan bool)
+	go func() {
+		defer close(finished)
+		defer signal.Stop(sigs)
+		select {
+		case sig := <-sigs:
+			log.Lvl1("Received", sig, "- closing conode")
+			if err := conode.Close(); err != nil {
+				log.Error("Couldn't close conode:", err)
+			}
+			for _, f := range atExit {
+				f()
+			}
+		case <-done:
+		}
+	}()
+	var once sync.Once
+	return func() {
+		once.Do(func() { close(done) })
+		<-finished
+	}
+}
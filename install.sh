@@ -37,4 +37,6 @@ if [[ $BRANCH =~ $pattern ]]; then
 fi
 
 cd $TRAVIS_BUILD_DIR
+# app/lib/config reads YAML group definitions
+go get gopkg.in/yaml.v2
 go get -t ./...
@@ -0,0 +1,40 @@
+package log
+
+import (
+	"runtime"
+	"sync"
+)
+
+// callerCacheSize is the maximum number of call-sites whose name is kept in
+// the cache. Once it is full, the cache is emptied.
+const callerCacheSize = 4096
+
+// callerNames caches the function-names without path of the call-sites.
+var callerNames = map[uintptr]string{}
+var callerMut sync.Mutex
+
+// callerName returns the name of the function containing pc, without the
+// path of its package. The name is computed only once per call-site.
+func callerName(pc uintptr) string {
+	callerMut.Lock()
+	defer callerMut.Unlock()
+	if name, ok := callerNames[pc]; ok {
+		return name
+	}
+	if len(callerNames) >= callerCacheSize {
+		callerNames = map[uintptr]string{}
+	}
+	name := funcName(pc)
+	callerNames[pc] = name
+	return name
+}
+
+// funcName returns the name of the function containing pc, without the path
+// of its package.
+func funcName(pc uintptr) string {
+	f := runtime.FuncForPC(pc)
+	if f == nil {
+		return "???"
+	}
+	return regexpPaths.ReplaceAllString(f.Name(), "")
+}
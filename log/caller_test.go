@@ -0,0 +1,42 @@
+package log
+
+import (
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCallerName(t *testing.T) {
+	pc, _, _, _ := runtime.Caller(0)
+	assert.Equal(t, "log.TestCallerName", funcName(pc))
+	assert.Equal(t, funcName(pc), callerName(pc))
+	// second time from the cache
+	assert.Equal(t, funcName(pc), callerName(pc))
+
+	// a full cache is emptied
+	callerMut.Lock()
+	callerNames = map[uintptr]string{}
+	for i := 0; i < callerCacheSize; i++ {
+		callerNames[uintptr(i)] = ""
+	}
+	callerMut.Unlock()
+	assert.Equal(t, funcName(pc), callerName(pc))
+	callerMut.Lock()
+	assert.Equal(t, 1, len(callerNames))
+	callerMut.Unlock()
+}
+
+func BenchmarkFuncName(b *testing.B) {
+	pc, _, _, _ := runtime.Caller(0)
+	for i := 0; i < b.N; i++ {
+		funcName(pc)
+	}
+}
+
+func BenchmarkCallerName(b *testing.B) {
+	pc, _, _, _ := runtime.Caller(0)
+	for i := 0; i < b.N; i++ {
+		callerName(pc)
+	}
+}
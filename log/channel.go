@@ -0,0 +1,163 @@
+package log
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// LogEntry is a single message as it is sent to the channels registered
+// with ToChannel or returned by Subscribe.
+type LogEntry struct {
+	// Level is the debug-level of the message: 1..5 for the Lvl-family,
+	// -1..-5 for the always-printing LLvl-family and lower than -10 for
+	// Info, Print, Warn, Error, Panic and Fatal.
+	Level int
+	// Caller is the name of the function and the line-number of the caller
+	Caller string
+	// Line is the line-number of the caller
+	Line int
+	// Time is when the message has been logged
+	Time time.Time
+	// Message is the message itself, without a trailing newline
+	Message string
+}
+
+// logChannel holds a channel registered with ToChannel together with the
+// highest debug-level it wants to receive. If visible is true, minLevel is
+// ignored and the channel receives the messages that are printed.
+type logChannel struct {
+	ch       chan<- LogEntry
+	minLevel int
+	visible  bool
+}
+
+// logChannels is protected by debugMut
+var logChannels []logChannel
+
+// SubscribeBuffer is the size of the buffer of the channels returned by
+// Subscribe.
+const SubscribeBuffer = 100
+
+// subscribed maps the channels returned by Subscribe to their sending side,
+// it is protected by debugMut.
+var subscribed = map[<-chan LogEntry]chan LogEntry{}
+
+// channelDropped counts the entries that couldn't be sent because the
+// channel was full.
+var channelDropped uint64
+
+// ToChannel registers a channel that will receive all messages up to
+// minLevel, independently of the debug-level set with SetDebugVisible.
+// The always-printing LLvl-family is compared using its absolute level and
+// Info, Warn, Error, Panic and Fatal are always sent.
+// The logger never blocks on the channel: if it is full, the entry is dropped
+// and counted in ChannelDropped.
+func ToChannel(ch chan<- LogEntry, minLevel int) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	logChannels = append(logChannels, logChannel{ch: ch, minLevel: minLevel})
+}
+
+// RemoveChannel stops sending entries to a channel registered with
+// ToChannel. The channel is not closed.
+func RemoveChannel(ch chan<- LogEntry) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	removeChannel(ch)
+}
+
+// Subscribe returns a buffered channel receiving every message that is
+// printed, following the debug-level set with SetDebugVisible. Like with
+// ToChannel, entries are dropped and counted in ChannelDropped if the
+// channel is full. Unsubscribe stops sending and closes the channel.
+func Subscribe() <-chan LogEntry {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	ch := make(chan LogEntry, SubscribeBuffer)
+	logChannels = append(logChannels, logChannel{ch: ch, visible: true})
+	subscribed[ch] = ch
+	return ch
+}
+
+// Unsubscribe stops sending entries to a channel returned by Subscribe and
+// closes it.
+func Unsubscribe(ch <-chan LogEntry) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	c, ok := subscribed[ch]
+	if !ok {
+		return
+	}
+	delete(subscribed, ch)
+	removeChannel(c)
+	close(c)
+}
+
+// removeChannel removes ch from the registered channels. debugMut must be
+// held by the caller.
+func removeChannel(ch chan<- LogEntry) {
+	for i, lc := range logChannels {
+		if lc.ch == ch {
+			logChannels = append(logChannels[:i], logChannels[i+1:]...)
+			return
+		}
+	}
+}
+
+// ChannelDropped returns how many entries have been dropped since the
+// start of the program because a channel was full.
+func ChannelDropped() uint64 {
+	return atomic.LoadUint64(&channelDropped)
+}
+
+// wantsLevel returns true if an entry of level l is to be sent to lc, vis
+// being the debug-level of the message.
+func (lc logChannel) wantsLevel(l, vis int) bool {
+	if lc.visible {
+		return l <= vis
+	}
+	if l <= lvlPrint {
+		return true
+	}
+	if l < 0 {
+		l = -l
+	}
+	return l <= lc.minLevel
+}
+
+// channelsWant returns true if at least one registered channel wants entries
+// of level l with debug-level vis. debugMut must be held by the caller.
+func channelsWant(l, vis int) bool {
+	for _, lc := range logChannels {
+		if lc.wantsLevel(l, vis) {
+			return true
+		}
+	}
+	return false
+}
+
+// sendChannels sends the entry to all interested channels without blocking.
+// debugMut must be held by the caller.
+func sendChannels(l, vis int, name string, line int, msg string) {
+	if len(logChannels) == 0 {
+		return
+	}
+	entry := LogEntry{
+		Level:   l,
+		Caller:  fmt.Sprintf("%s:%d", name, line),
+		Line:    line,
+		Time:    time.Now(),
+		Message: msg,
+	}
+	for _, lc := range logChannels {
+		if !lc.wantsLevel(l, vis) {
+			continue
+		}
+		select {
+		case lc.ch <- entry:
+		default:
+			atomic.AddUint64(&channelDropped, 1)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package log
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToChannel(t *testing.T) {
+	SetDebugVisible(1)
+	ch := make(chan LogEntry, 10)
+	ToChannel(ch, 3)
+	defer RemoveChannel(ch)
+
+	Lvl1("one")
+	Lvl3("three")
+	Lvl4("four")
+	LLvl2("always")
+	Error("error")
+	getStdOut()
+	getStdErr()
+
+	for _, exp := range []struct {
+		lvl int
+		msg string
+	}{{1, "one"}, {3, "three"}, {-2, "always"}, {lvlError, "error"}} {
+		select {
+		case e := <-ch:
+			assert.Equal(t, exp.lvl, e.Level)
+			assert.Equal(t, exp.msg, e.Message)
+			assert.Equal(t, "log.TestToChannel:0", e.Caller)
+			assert.False(t, e.Time.IsZero())
+		case <-time.After(time.Second):
+			t.Fatal("Didn't get entry", exp.msg)
+		}
+	}
+	select {
+	case e := <-ch:
+		t.Fatal("Got unwanted entry", e)
+	default:
+	}
+}
+
+func TestToChannelFull(t *testing.T) {
+	SetDebugVisible(0)
+	defer SetDebugVisible(1)
+	ch := make(chan LogEntry, 1)
+	ToChannel(ch, 1)
+	defer RemoveChannel(ch)
+
+	dropped := ChannelDropped()
+	done := make(chan bool)
+	go func() {
+		for i := 0; i < 10; i++ {
+			Lvl1("flooding", i)
+		}
+		done <- true
+	}()
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("Logger blocked on full channel")
+	}
+	require.Equal(t, dropped+9, ChannelDropped())
+	e := <-ch
+	assert.Equal(t, "flooding 0", e.Message)
+}
+
+func TestToChannelFiltered(t *testing.T) {
+	SetDebugVisible(1)
+	SetRateLimit(1)
+	SetSuppressRepeatedErrors(true)
+	ch := make(chan LogEntry, 10)
+	ToChannel(ch, 1)
+	defer RemoveChannel(ch)
+
+	for i := 0; i < 3; i++ {
+		Lvl1("flood")
+		Error("again")
+	}
+	SetSuppressRepeatedErrors(false)
+	SetRateLimit(0)
+	getStdOut()
+	getStdErr()
+	for _, exp := range []string{"flood", "again"} {
+		select {
+		case e := <-ch:
+			assert.Equal(t, exp, e.Message)
+		case <-time.After(time.Second):
+			t.Fatal("Didn't get entry", exp)
+		}
+	}
+	select {
+	case e := <-ch:
+		t.Fatal("Got suppressed entry", e)
+	default:
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	SetDebugVisible(2)
+	defer SetDebugVisible(1)
+	ch := Subscribe()
+
+	Lvl2("two")
+	Lvl3("hidden")
+	LLvl4("always")
+	getStdOut()
+
+	for _, exp := range []struct {
+		lvl int
+		msg string
+	}{{2, "two"}, {-4, "always"}} {
+		select {
+		case e := <-ch:
+			assert.Equal(t, exp.lvl, e.Level)
+			assert.Equal(t, exp.msg, e.Message)
+			assert.Equal(t, "log.TestSubscribe:0", e.Caller)
+			assert.Equal(t, 0, e.Line)
+		case <-time.After(time.Second):
+			t.Fatal("Didn't get entry", exp.msg)
+		}
+	}
+
+	Unsubscribe(ch)
+	Lvl1("unsubscribed")
+	getStdOut()
+	_, ok := <-ch
+	assert.False(t, ok, "channel should be closed")
+	Unsubscribe(ch)
+}
@@ -0,0 +1,89 @@
+package log
+
+import (
+	"fmt"
+	"time"
+)
+
+// suppressErrors indicates whether repeated errors are suppressed. It is
+// protected by debugMut, as is lastError.
+var suppressErrors bool
+
+// lastError is the last error printed while suppressErrors is true.
+var lastError *repeatedError
+
+// errorSummaryInterval is how long repeated errors are suppressed before a
+// summary is printed.
+var errorSummaryInterval = 10 * time.Second
+
+// repeatedError counts the repetitions of an error.
+type repeatedError struct {
+	name     string
+	line     int
+	message  string
+	repeated int
+	timer    *time.Timer
+}
+
+// SetSuppressRepeatedErrors turns on or off the suppression of repeated
+// errors: if the same error is logged from the same place more than once
+// in a row, only the first one is printed. Once a different error is logged
+// or after some seconds, a summary "(N more identical errors suppressed)"
+// is printed.
+func SetSuppressRepeatedErrors(suppress bool) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	if !suppress {
+		flushRepeatedError()
+		lastError = nil
+	}
+	suppressErrors = suppress
+}
+
+// SuppressRepeatedErrors returns whether repeated errors are suppressed.
+func SuppressRepeatedErrors() bool {
+	debugMut.RLock()
+	defer debugMut.RUnlock()
+	return suppressErrors
+}
+
+// errorAllows returns false if the message is an error that has just been
+// printed. debugMut must be held by the caller.
+func errorAllows(lvl int, name string, line int, message string) bool {
+	if !suppressErrors || lvl != lvlError {
+		return true
+	}
+	if lastError != nil && lastError.name == name &&
+		lastError.line == line && lastError.message == message {
+		lastError.repeated++
+		if lastError.timer == nil {
+			lastError.timer = time.AfterFunc(errorSummaryInterval, func() {
+				debugMut.Lock()
+				defer debugMut.Unlock()
+				flushRepeatedError()
+			})
+		}
+		return false
+	}
+	flushRepeatedError()
+	lastError = &repeatedError{name: name, line: line, message: message}
+	return true
+}
+
+// flushRepeatedError prints the summary of the suppressed errors, if any.
+// debugMut must be held by the caller.
+func flushRepeatedError() {
+	if lastError == nil {
+		return
+	}
+	if lastError.timer != nil {
+		lastError.timer.Stop()
+		lastError.timer = nil
+	}
+	if lastError.repeated > 0 {
+		output(lvlError, lastError.name, lastError.line,
+			fmt.Sprintf("(%d more identical errors suppressed)\n",
+				lastError.repeated))
+		lastError.repeated = 0
+	}
+}
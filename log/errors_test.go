@@ -0,0 +1,56 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSuppressRepeatedErrors(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	SetDebugVisible(1)
+	var out, errOut bytes.Buffer
+	SetOutput(&out, &errOut)
+	SetSuppressRepeatedErrors(true)
+	defer SetSuppressRepeatedErrors(false)
+	assert.True(t, SuppressRepeatedErrors())
+
+	for i := 0; i < 5; i++ {
+		Error("connection failed")
+	}
+	assert.Equal(t, 1, strings.Count(errOut.String(), "\n"))
+	assert.Contains(t, errOut.String(), "- connection failed\n")
+
+	Error("other error")
+	lines := strings.Split(strings.TrimSpace(errOut.String()), "\n")
+	assert.Equal(t, 3, len(lines))
+	assert.Contains(t, lines[1], "- (4 more identical errors suppressed)")
+	assert.Contains(t, lines[2], "- other error")
+	assert.Equal(t, "", out.String())
+}
+
+func TestSuppressRepeatedErrorsTimer(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	defer func(i time.Duration) { errorSummaryInterval = i }(errorSummaryInterval)
+	errorSummaryInterval = 10 * time.Millisecond
+	SetDebugVisible(1)
+	var out, errOut bytes.Buffer
+	SetOutput(&out, &errOut)
+	SetSuppressRepeatedErrors(true)
+	defer SetSuppressRepeatedErrors(false)
+
+	for i := 0; i < 3; i++ {
+		Error("flapping")
+	}
+	time.Sleep(50 * time.Millisecond)
+	debugMut.Lock()
+	str := errOut.String()
+	debugMut.Unlock()
+	assert.Equal(t, 1, strings.Count(str, "- flapping\n"))
+	assert.Contains(t, str, "- (2 more identical errors suppressed)\n")
+}
@@ -0,0 +1,242 @@
+package log
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// Logger prints messages together with a set of fields. The fields are
+// sorted by their key and printed as 'key=value' between the caller and the
+// message:
+//	l := log.WithFields(map[string]interface{}{"round": 3, "host": name})
+//	l.Lvl2("Got commitment")
+// prints
+//	2 : (                     main.commit:  42) - host=conode1 round=3 Got commitment
+type Logger struct {
+	fields map[string]interface{}
+	prefix string
+	// ctx is set by Ctx, once it is done, nothing is printed anymore
+	ctx context.Context
+	// visible is set by WithDebugVisible and overrides the global
+	// debug-level
+	visible *int
+	// ctxDoneLogged is set to 1 once the end of ctx has been logged, it is
+	// shared between all Loggers derived from the same Ctx call
+	ctxDoneLogged *int32
+}
+
+// WithFields returns a Logger that prints the given fields with every
+// message.
+func WithFields(fields map[string]interface{}) *Logger {
+	return (&Logger{}).WithFields(fields)
+}
+
+// WithFields returns a new Logger with the fields of l and the given fields.
+// Fields with an existing key replace the old value.
+func (l *Logger) WithFields(fields map[string]interface{}) *Logger {
+	nl := &Logger{
+		fields:        make(map[string]interface{}),
+		ctx:           l.ctx,
+		visible:       l.visible,
+		ctxDoneLogged: l.ctxDoneLogged,
+	}
+	for k, v := range l.fields {
+		nl.fields[k] = v
+	}
+	for k, v := range fields {
+		nl.fields[k] = v
+	}
+	keys := make([]string, 0, len(nl.fields))
+	for k := range nl.fields {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for i, k := range keys {
+		if i > 0 {
+			nl.prefix += " "
+		}
+		nl.prefix += fmt.Sprintf("%s=%v", k, nl.fields[k])
+	}
+	return nl
+}
+
+// Fields returns a copy of the fields of this Logger.
+func (l *Logger) Fields() map[string]interface{} {
+	f := make(map[string]interface{})
+	for k, v := range l.fields {
+		f[k] = v
+	}
+	return f
+}
+
+// Ctx returns a Logger that stops printing once ctx is done. The first call
+// after ctx is done prints a single line telling that further messages are
+// suppressed. If ctx has a deadline, it is shown together with the time.
+func Ctx(ctx context.Context) *Logger {
+	return (&Logger{}).Ctx(ctx)
+}
+
+// Ctx returns a new Logger with the fields of l that stops printing once ctx
+// is done.
+func (l *Logger) Ctx(ctx context.Context) *Logger {
+	nl := l.WithFields(nil)
+	nl.ctx = ctx
+	nl.ctxDoneLogged = new(int32)
+	return nl
+}
+
+// WithDebugVisible returns a new Logger with the fields of l that shows
+// the messages of the Lvl-family up to the debug-level lvl, independently
+// of the level set with SetDebugVisible.
+func (l *Logger) WithDebugVisible(lvl int) *Logger {
+	nl := l.WithFields(nil)
+	nl.visible = &lvl
+	return nl
+}
+
+// Like the global functions, these need two functions to keep the
+// caller-depth the same.
+func (l *Logger) lvlf(lv int, f string, args ...interface{}) {
+	if l.ctxDone() {
+		return
+	}
+	lvlDeadline(lv, 3, l.visible, l.deadline(), l.args(fmt.Sprintf(f, args...))...)
+}
+func (l *Logger) lvld(lv int, args ...interface{}) {
+	if l.ctxDone() {
+		return
+	}
+	lvlDeadline(lv, 3, l.visible, l.deadline(), l.args(args...)...)
+}
+func (l *Logger) lvlUI(lv int, args ...interface{}) {
+	if l.ctxDone() {
+		return
+	}
+	if useLvlFormat() {
+		lvlDeadline(lv, 3, l.visible, l.deadline(), l.args(args...)...)
+	} else if msg, ok := print(lv, 3, l.args(args...)...); ok {
+		callHooks(lv, "", msg)
+	}
+}
+
+// ctxDone returns true if the context of the Logger is done. The first time
+// it prints that the messages will be suppressed.
+func (l *Logger) ctxDone() bool {
+	if l.ctx == nil {
+		return false
+	}
+	select {
+	case <-l.ctx.Done():
+	default:
+		return false
+	}
+	if atomic.CompareAndSwapInt32(l.ctxDoneLogged, 0, 1) {
+		lvlDeadline(lvlInfo, 4, nil, time.Time{}, l.args("context done, suppressing further logs:",
+			l.ctx.Err())...)
+	}
+	return true
+}
+
+// deadline returns the deadline of the context or the zero time.
+func (l *Logger) deadline() time.Time {
+	if l.ctx == nil {
+		return time.Time{}
+	}
+	d, _ := l.ctx.Deadline()
+	return d
+}
+
+// args prepends the fields to the arguments.
+func (l *Logger) args(args ...interface{}) []interface{} {
+	if l.prefix == "" {
+		return args
+	}
+	return append([]interface{}{l.prefix}, args...)
+}
+
+// Lvl1 is like log.Lvl1 but with the fields of the Logger
+func (l *Logger) Lvl1(args ...interface{}) { l.lvld(1, args...) }
+
+// Lvl2 is like log.Lvl2 but with the fields of the Logger
+func (l *Logger) Lvl2(args ...interface{}) { l.lvld(2, args...) }
+
+// Lvl3 is like log.Lvl3 but with the fields of the Logger
+func (l *Logger) Lvl3(args ...interface{}) { l.lvld(3, args...) }
+
+// Lvl4 is like log.Lvl4 but with the fields of the Logger
+func (l *Logger) Lvl4(args ...interface{}) { l.lvld(4, args...) }
+
+// Lvl5 is like log.Lvl5 but with the fields of the Logger
+func (l *Logger) Lvl5(args ...interface{}) { l.lvld(5, args...) }
+
+// Lvlf1 is like log.Lvlf1 but with the fields of the Logger
+func (l *Logger) Lvlf1(f string, args ...interface{}) { l.lvlf(1, f, args...) }
+
+// Lvlf2 is like log.Lvlf2 but with the fields of the Logger
+func (l *Logger) Lvlf2(f string, args ...interface{}) { l.lvlf(2, f, args...) }
+
+// Lvlf3 is like log.Lvlf3 but with the fields of the Logger
+func (l *Logger) Lvlf3(f string, args ...interface{}) { l.lvlf(3, f, args...) }
+
+// Lvlf4 is like log.Lvlf4 but with the fields of the Logger
+func (l *Logger) Lvlf4(f string, args ...interface{}) { l.lvlf(4, f, args...) }
+
+// Lvlf5 is like log.Lvlf5 but with the fields of the Logger
+func (l *Logger) Lvlf5(f string, args ...interface{}) { l.lvlf(5, f, args...) }
+
+// LLvl1 *always* prints, with the fields of the Logger
+func (l *Logger) LLvl1(args ...interface{}) { l.lvld(-1, args...) }
+
+// LLvl2 *always* prints, with the fields of the Logger
+func (l *Logger) LLvl2(args ...interface{}) { l.lvld(-2, args...) }
+
+// LLvl3 *always* prints, with the fields of the Logger
+func (l *Logger) LLvl3(args ...interface{}) { l.lvld(-3, args...) }
+
+// LLvl4 *always* prints, with the fields of the Logger
+func (l *Logger) LLvl4(args ...interface{}) { l.lvld(-4, args...) }
+
+// LLvl5 *always* prints, with the fields of the Logger
+func (l *Logger) LLvl5(args ...interface{}) { l.lvld(-5, args...) }
+
+// LLvlf1 *always* prints, with the fields of the Logger
+func (l *Logger) LLvlf1(f string, args ...interface{}) { l.lvlf(-1, f, args...) }
+
+// LLvlf2 *always* prints, with the fields of the Logger
+func (l *Logger) LLvlf2(f string, args ...interface{}) { l.lvlf(-2, f, args...) }
+
+// LLvlf3 *always* prints, with the fields of the Logger
+func (l *Logger) LLvlf3(f string, args ...interface{}) { l.lvlf(-3, f, args...) }
+
+// LLvlf4 *always* prints, with the fields of the Logger
+func (l *Logger) LLvlf4(f string, args ...interface{}) { l.lvlf(-4, f, args...) }
+
+// LLvlf5 *always* prints, with the fields of the Logger
+func (l *Logger) LLvlf5(f string, args ...interface{}) { l.lvlf(-5, f, args...) }
+
+// Info is like log.Info but with the fields of the Logger
+func (l *Logger) Info(args ...interface{}) { l.lvlUI(lvlInfo, args...) }
+
+// Warn is like log.Warn but with the fields of the Logger
+func (l *Logger) Warn(args ...interface{}) { l.lvlUI(lvlWarning, args...) }
+
+// Error is like log.Error but with the fields of the Logger
+func (l *Logger) Error(args ...interface{}) { l.lvlUI(lvlError, args...) }
+
+// Infof is like log.Infof but with the fields of the Logger
+func (l *Logger) Infof(f string, args ...interface{}) {
+	l.lvlUI(lvlInfo, fmt.Sprintf(f, args...))
+}
+
+// Warnf is like log.Warnf but with the fields of the Logger
+func (l *Logger) Warnf(f string, args ...interface{}) {
+	l.lvlUI(lvlWarning, fmt.Sprintf(f, args...))
+}
+
+// Errorf is like log.Errorf but with the fields of the Logger
+func (l *Logger) Errorf(f string, args ...interface{}) {
+	l.lvlUI(lvlError, fmt.Sprintf(f, args...))
+}
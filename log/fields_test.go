@@ -0,0 +1,85 @@
+package log
+
+import (
+	"bytes"
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithFields(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	SetDebugVisible(2)
+	defer SetDebugVisible(1)
+	var out, errOut bytes.Buffer
+	SetOutput(&out, &errOut)
+
+	l := WithFields(map[string]interface{}{"round": 3, "host": "conode1"})
+	l.Lvl1("one")
+	assert.Equal(t, "1 : (                      log.TestWithFields:   0) - host=conode1 round=3 one\n",
+		out.String())
+	out.Reset()
+	l.WithFields(map[string]interface{}{"protocol": "cosi", "round": 4}).Lvlf2("%d", 2)
+	assert.Equal(t, "2 : (                      log.TestWithFields:   0) - host=conode1 protocol=cosi round=4 2\n",
+		out.String())
+	l.Errorf("failed %s", "now")
+	assert.Equal(t, "E : (                      log.TestWithFields:   0) - host=conode1 round=3 failed now\n",
+		errOut.String())
+	assert.Equal(t, 2, len(l.Fields()))
+}
+
+func TestWithDebugVisible(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	SetDebugVisible(1)
+	var out bytes.Buffer
+	SetOutput(&out, nil)
+
+	l := WithFields(nil).WithDebugVisible(3)
+	l.Lvl3("three")
+	l.Lvl4("four")
+	Lvl3("global")
+	assert.Equal(t, "3 : (                log.TestWithDebugVisible:   0) - three\n",
+		out.String())
+
+	out.Reset()
+	SetDebugVisible(5)
+	l.WithFields(map[string]interface{}{"a": 1}).Lvl4("four")
+	WithFields(nil).WithDebugVisible(0).Lvl1("hidden")
+	assert.Equal(t, "", out.String())
+	SetDebugVisible(1)
+}
+
+func TestCtx(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	SetDebugVisible(1)
+	var out, errOut bytes.Buffer
+	SetOutput(&out, &errOut)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	l := Ctx(ctx).WithFields(map[string]interface{}{"round": 1})
+	l.Lvl1("before")
+	cancel()
+	l.Lvl1("after")
+	l.Lvlf1("%s", "after")
+	l.Error("after")
+	Ctx(ctx).Lvl1("other handle")
+	assert.Equal(t, "1 : (                             log.TestCtx:   0) - round=1 before\n"+
+		"I : (                             log.TestCtx:   0) - round=1 context done, suppressing further logs: context canceled\n"+
+		"I : (                             log.TestCtx:   0) - context done, suppressing further logs: context canceled\n",
+		out.String())
+	assert.Equal(t, "", errOut.String())
+
+	out.Reset()
+	SetShowTime(true)
+	defer SetShowTime(false)
+	ctx, cancel = context.WithTimeout(context.Background(), time.Hour)
+	defer cancel()
+	Ctx(ctx).Lvl1("deadline")
+	assert.Contains(t, out.String(), " [deadline in 3")
+	assert.Contains(t, out.String(), "- deadline\n")
+}
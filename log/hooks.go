@@ -0,0 +1,51 @@
+package log
+
+import "sync"
+
+// Hook is called for every message that has been printed. level is the
+// level of the message as described in LogEntry, caller is the name of the
+// function and the line-number of the caller, or empty if the common messages
+// are printed with FormatPython or FormatNone. msg is the message without a
+// trailing newline.
+type Hook func(level int, caller, msg string)
+
+// hooks holds the hooks added by AddHook. It is protected by hooksMut and
+// not by debugMut, so that the hooks can be called without holding
+// debugMut.
+var hooks []Hook
+var hooksMut sync.RWMutex
+
+// AddHook adds a hook that is called synchronously for every message once
+// it is printed, for example to forward errors to an external service:
+//	log.AddHook(func(level int, caller, msg string) {
+//		if level == log.LevelError || level == log.LevelFatal {
+//			alert(caller, msg)
+//		}
+//	})
+// A hook can log itself, but must not log messages at the levels it
+// reacts on, as this would call it again endlessly. Slow hooks should hand
+// over the messages to a goroutine, as they slow down every call to the
+// log-package.
+func AddHook(h Hook) {
+	hooksMut.Lock()
+	defer hooksMut.Unlock()
+	hooks = append(hooks, h)
+}
+
+// ClearHooks removes all hooks added by AddHook.
+func ClearHooks() {
+	hooksMut.Lock()
+	defer hooksMut.Unlock()
+	hooks = nil
+}
+
+// callHooks calls all hooks with the given message. debugMut must not be
+// held by the caller.
+func callHooks(level int, caller, msg string) {
+	hooksMut.RLock()
+	hs := hooks
+	hooksMut.RUnlock()
+	for _, h := range hs {
+		h(level, caller, msg)
+	}
+}
@@ -0,0 +1,46 @@
+package log
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAddHook(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	defer ClearHooks()
+	SetDebugVisible(1)
+	var out, errOut bytes.Buffer
+	SetOutput(&out, &errOut)
+
+	type entry struct {
+		level  int
+		caller string
+		msg    string
+	}
+	var entries []entry
+	AddHook(func(level int, caller, msg string) {
+		entries = append(entries, entry{level, caller, msg})
+		if msg == "one" {
+			// logging from a hook must not deadlock
+			Lvl1("hooked", msg)
+		}
+	})
+
+	Lvl1("one")
+	Lvl2("hidden")
+	Error("failed")
+	assert.Equal(t, []entry{
+		{1, "log.TestAddHook:0", "one"},
+		{1, "log.TestAddHook.func1:0", "hooked one"},
+		{LevelError, "log.TestAddHook:0", "failed"},
+	}, entries)
+	assert.Contains(t, out.String(), "hooked one")
+
+	ClearHooks()
+	entries = nil
+	Lvl1("two")
+	assert.Nil(t, entries)
+}
@@ -11,6 +11,7 @@
 //	log.Lvl3("Eventually flooding information")
 //	log.Lvl4("Definitively flooding information")
 //	log.Lvl5("I hope you never need this")
+//	log.Trace("Only when chasing a bug")
 // in your program, then according to the debug-level one or more levels of
 // output will be shown. To set the debug-level, use
 //	log.SetDebugVisible(3)
@@ -30,16 +31,22 @@
 //	log.Panic("Something really went bad - calls panic")
 //	log.Fatal("No way to continue - calls os.Exit")
 //
-// These messages are printed according to the value of 'Format':
-// - Format == FormatLvl - same as log.Lvl
-// - Format == FormatPython - with some nice python-style formatting
-// - Format == FormatNone - just as plain text
+// These messages are printed according to the format set with SetFormat:
+// - FormatLvl - same as log.Lvl, the default
+// - FormatPython - with some nice python-style formatting
+// - FormatNone - just as plain text
+// For compatibility, a debug-level of FormatPython or FormatNone set with
+// SetDebugVisible has the same effect while the format is FormatLvl.
 //
 // The log-package also takes into account the following environment-variables:
 //	DEBUG_LVL // will act like SetDebugVisible
 //	DEBUG_TIME // if 'true' it will print the date and time
 //	DEBUG_COLOR // if 'false' it will not use colors
-// But for this the function ParseEnv() or AddFlags() has to be called.
+//	DEBUG_STACK // if 'true' Fatal and ErrFatal print the stack
+// But for this the function ParseEnv(), RegisterFlags() or AddFlags() has to
+// be called. RegisterFlags only defines the flags "-debug", "-debug-time",
+// ... and has to be called before flag.Parse, while AddFlags also parses the
+// command-line and has to be called after all other flags are defined.
 package log
 
 import (
@@ -50,6 +57,7 @@ import (
 	"regexp"
 	"runtime"
 	"strconv"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -59,7 +67,7 @@ import (
 	"github.com/daviddengcn/go-colortext"
 )
 
-// For debugging purposes we can change the output-writer
+// The writers used for the output, they can be changed using SetOutput.
 var stdOut io.Writer
 var stdErr io.Writer
 
@@ -77,12 +85,27 @@ const (
 	lvlPrint
 )
 
-// These formats can be used in place of the debugVisible
+// These levels can be used in SetLevelWriter to refer to the common messages.
+const (
+	LevelWarning = lvlWarning
+	LevelError   = lvlError
+	LevelFatal   = lvlFatal
+	LevelPanic   = lvlPanic
+	LevelInfo    = lvlInfo
+	LevelPrint   = lvlPrint
+)
+
+// lvlTrace is the level of Trace, it is more verbose than Lvl5.
+const lvlTrace = 6
+
+// These formats can be used with SetFormat or in place of the debugVisible
 const (
 	// FormatPython uses [x] and others to indicate what is shown
 	FormatPython = -1
 	// FormatNone is just pure print
 	FormatNone = 0
+	// FormatLvl prints the common messages like the log-level messages
+	FormatLvl = 1
 )
 
 // defaultMainTest indicates what debug-level should be used when `go test -v`
@@ -113,30 +136,92 @@ var StaticMsg = ""
 // generated by adjusting the 'DebugVisible' variable.
 var debugVisible = 1
 
+// format is the format of the common messages, one of FormatLvl,
+// FormatPython or FormatNone.
+var format = FormatLvl
+
 // If showTime is true, it will print the time for each line of debug-output.
 var showTime = false
 
+// If relativeTime is true, the time shown is the time in seconds since the
+// last call to ResetClock.
+var relativeTime = false
+
+// clockStart is the reference for the relative time.
+var clockStart = time.Now()
+
+// If stackOnFatal is true, Fatal and ErrFatal print the stack before
+// exiting.
+var stackOnFatal = false
+
 // If useColors is true, debug-output will be colored (defaults to monochrome
 // output).
 var useColors = false
 
+// defaultPalette holds the colors used for every level if no other colors
+// are set with SetPalette.
+var defaultPalette = map[int]ct.Color{
+	1:          ct.Yellow,
+	2:          ct.Cyan,
+	3:          ct.Green,
+	4:          ct.Blue,
+	5:          ct.Cyan,
+	lvlTrace:   ct.Magenta,
+	lvlWarning: ct.Green,
+	lvlError:   ct.Red,
+	lvlFatal:   ct.Red,
+	lvlPanic:   ct.Red,
+	lvlInfo:    ct.White,
+	lvlPrint:   ct.White,
+}
+
+// palette holds the colors actually used, it is protected by debugMut.
+var palette = copyPalette(defaultPalette)
+
+// foreground sets the color of the terminal, it is replaced in the tests.
+var foreground = ct.Foreground
+
 // outputLines can be false to suppress outputting of lines in tests.
 var outputLines = true
 
 var debugMut sync.RWMutex
 
+// levelWriters holds the writers set with SetLevelWriter, it is protected
+// by debugMut.
+var levelWriters = map[int]io.Writer{}
+
 var regexpPaths, _ = regexp.Compile(".*/")
 
 func lvl(lvl, skip int, args ...interface{}) {
+	lvlDeadline(lvl, skip+1, nil, time.Time{}, args...)
+}
+
+// lvlDeadline is like lvl, but if deadline is not zero, it is shown with
+// the time. If visible is not nil, it is used instead of the debug-level set
+// with SetDebugVisible.
+func lvlDeadline(lvl, skip int, visible *int, deadline time.Time, args ...interface{}) {
+	caller, message, ok := lvlWrite(lvl, skip+1, visible, deadline, args...)
+	if ok {
+		callHooks(lvl, caller, message)
+	}
+}
+
+// lvlWrite prints the message like lvlDeadline. If the message has been
+// printed, it returns the caller and the message without the trailing
+// newline, so that the hooks can be called once debugMut is released.
+func lvlWrite(lvl, skip int, visible *int, deadline time.Time, args ...interface{}) (string, string, bool) {
 	debugMut.Lock()
 	defer debugMut.Unlock()
 
-	if lvl > debugVisible {
-		return
+	vis := debugVisible
+	if visible != nil {
+		vis = *visible
+	}
+	if lvl > vis && !channelsWant(lvl, vis) {
+		return "", "", false
 	}
 	pc, _, line, _ := runtime.Caller(skip)
-	name := regexpPaths.ReplaceAllString(runtime.FuncForPC(pc).Name(), "")
-	lineStr := fmt.Sprintf("%d", line)
+	name := callerName(pc)
 
 	// For the testing-framework, we check the resulting string. So as not to
 	// have the tests fail every time somebody moves the functions, we put
@@ -144,19 +229,46 @@ func lvl(lvl, skip int, args ...interface{}) {
 	if !outputLines {
 		line = 0
 	}
+	message := fmt.Sprintln(args...)
+	caller := fmt.Sprintf("%s:%d", name, line)
+	// Lines suppressed on the console are not sent to the channels either.
+	if lvl <= vis {
+		if !errorAllows(lvl, name, line, message) {
+			return "", "", false
+		}
+		if !rateLimitAllows(lvl, name, line, message) {
+			return "", "", false
+		}
+	}
+	sendChannels(lvl, vis, name, line, strings.TrimSuffix(message, "\n"))
+	if lvl > vis {
+		return "", "", false
+	}
+	outputDeadline(lvl, name, line, message, deadline)
+	return caller, strings.TrimSuffix(message, "\n"), true
+}
 
+// output formats and prints the message. debugMut must be held by the
+// caller.
+func output(lvl int, name string, line int, message string) {
+	outputDeadline(lvl, name, line, message, time.Time{})
+}
+
+// outputDeadline is like output, but if deadline is not zero, it is shown
+// with the time.
+func outputDeadline(lvl int, name string, line int, message string, deadline time.Time) {
+	lineStr := fmt.Sprintf("%d", line)
 	if len(name) > NamePadding && NamePadding > 0 {
 		NamePadding = len(name)
 	}
 	if len(lineStr) > LinePadding && LinePadding > 0 {
-		LinePadding = len(name)
+		LinePadding = len(lineStr)
 	}
 	fmtstr := fmt.Sprintf("%%%ds: %%%dd", NamePadding, LinePadding)
 	caller := fmt.Sprintf(fmtstr, name, line)
 	if StaticMsg != "" {
 		caller += "@" + StaticMsg
 	}
-	message := fmt.Sprintln(args...)
 	bright := lvl < 0
 	lvlAbs := lvl
 	if bright {
@@ -166,52 +278,70 @@ func lvl(lvl, skip int, args ...interface{}) {
 	if lvl < 0 {
 		lvlStr += "!"
 	}
+	color := lvl
 	switch lvl {
-	case lvlPrint:
-		fg(ct.White, true)
-		lvlStr = "I"
-	case lvlInfo:
-		fg(ct.White, true)
+	case lvlPrint, lvlInfo:
 		lvlStr = "I"
 	case lvlWarning:
-		fg(ct.Green, true)
 		lvlStr = "W"
 	case lvlError:
-		fg(ct.Red, false)
+		bright = false
 		lvlStr = "E"
 	case lvlFatal:
-		fg(ct.Red, true)
 		lvlStr = "F"
 	case lvlPanic:
-		fg(ct.Red, true)
 		lvlStr = "P"
 	default:
-		if lvl != 0 {
-			if lvlAbs <= 5 {
-				colors := []ct.Color{ct.Yellow, ct.Cyan, ct.Green, ct.Blue, ct.Cyan}
-				fg(colors[lvlAbs-1], bright)
-			}
-		}
+		// the LLvl-family uses the colors of the Lvl-family
+		color = lvlAbs
+	}
+	if sysLog != nil {
+		// the system logger adds the time and has no colors
+		writeSyslog(lvl, fmt.Sprintf("%-2s: (%s) - %s", lvlStr, caller, message))
+		capture(lvlStr, fmt.Sprintf("%s:%d", name, line), message)
+		return
+	}
+	if c, ok := palette[color]; ok {
+		fg(c, bright)
 	}
 	str := fmt.Sprintf(": (%s) - %s", caller, message)
 	if showTime {
-		ti := time.Now()
-		str = fmt.Sprintf("%s.%09d%s", ti.Format("06/02/01 15:04:05"), ti.Nanosecond(), str)
+		if !deadline.IsZero() {
+			str = fmt.Sprintf(" [deadline in %.3fs]%s",
+				deadline.Sub(time.Now()).Seconds(), str)
+		}
+		if relativeTime {
+			str = fmt.Sprintf("%12.6f%s", time.Since(clockStart).Seconds(), str)
+		} else {
+			ti := time.Now()
+			str = fmt.Sprintf("%s.%09d%s", ti.Format("06/02/01 15:04:05"), ti.Nanosecond(), str)
+		}
 	}
 	str = fmt.Sprintf("%-2s%s", lvlStr, str)
-	if lvl < lvlInfo {
-		fmt.Fprint(stdErr, str)
-	} else {
-		fmt.Fprint(stdOut, str)
-	}
+	fmt.Fprint(levelWriter(lvl), str)
+	capture(lvlStr, fmt.Sprintf("%s:%d", name, line), message)
 	if useColors {
 		ct.ResetColor()
 	}
 }
 
+// levelWriter returns the writer for messages of level l: either the one set
+// with SetLevelWriter, or stdErr for warnings, errors, panics and fatal
+// messages and stdOut for everything else. debugMut must be held by the
+// caller.
+func levelWriter(l int) io.Writer {
+	if w, ok := levelWriters[l]; ok {
+		return w
+	}
+	if l < lvlInfo {
+		return stdErr
+	}
+	return stdOut
+}
+
 func fg(c ct.Color, bright bool) {
 	if useColors {
-		ct.Foreground(c, bright)
+		foreground(c, bright)
 	}
 }
 
@@ -278,6 +408,23 @@ func Lvlf5(f string, args ...interface{}) {
 	lvlf(5, f, args...)
 }
 
+// Trace is for output that is only useful when chasing a specific bug, like
+// per-element traces in inner loops. It is shown with SetDebugVisible(6).
+func Trace(args ...interface{}) {
+	lvld(lvlTrace, args...)
+}
+
+// Tracef is like Trace but with a format-string
+func Tracef(f string, args ...interface{}) {
+	lvlf(lvlTrace, f, args...)
+}
+
+// LTrace *always* prints
+func LTrace(args ...interface{}) { lvld(-lvlTrace, args...) }
+
+// LTracef *always* prints
+func LTracef(f string, args ...interface{}) { lvlf(-lvlTrace, f, args...) }
+
 // LLvl1 *always* prints
 func LLvl1(args ...interface{}) { lvld(-1, args...) }
 
@@ -337,6 +484,21 @@ func DebugVisible() int {
 	return debugVisible
 }
 
+// SetFormat sets the format of the common messages Info, Print, Warn,
+// Error, Panic and Fatal: FormatLvl, FormatPython or FormatNone.
+func SetFormat(f int) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	format = f
+}
+
+// Format returns the format of the common messages.
+func Format() int {
+	debugMut.RLock()
+	defer debugMut.RUnlock()
+	return format
+}
+
 // SetShowTime allows for turning on the flag that adds the current
 // time to the debug-output
 func SetShowTime(show bool) {
@@ -353,6 +515,44 @@ func ShowTime() bool {
 	return showTime
 }
 
+// SetRelativeTime changes the time shown with SetShowTime to be the seconds
+// since the start of the program or the last call to ResetClock, with
+// microsecond precision.
+func SetRelativeTime(relative bool) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	relativeTime = relative
+}
+
+// RelativeTime returns whether the time is shown relative to ResetClock.
+func RelativeTime() bool {
+	debugMut.RLock()
+	defer debugMut.RUnlock()
+	return relativeTime
+}
+
+// SetStackOnFatal turns on or off printing the stack of the calling goroutine
+// in Fatal, Fatalf, ErrFatal and ErrFatalf before exiting.
+func SetStackOnFatal(stack bool) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	stackOnFatal = stack
+}
+
+// StackOnFatal returns whether the stack is printed on fatal errors.
+func StackOnFatal() bool {
+	debugMut.RLock()
+	defer debugMut.RUnlock()
+	return stackOnFatal
+}
+
+// ResetClock sets the reference of the relative time to now.
+func ResetClock() {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	clockStart = time.Now()
+}
+
 // SetUseColors can turn off or turn on the use of colors in the debug-output
 func SetUseColors(show bool) {
 	debugMut.Lock()
@@ -360,6 +560,38 @@ func SetUseColors(show bool) {
 	useColors = show
 }
 
+// SetPalette changes the colors used for the levels given in p, the other
+// levels keep their colors. The keys are the debug-levels 1-6 for the
+// Lvl-family, which are also used for the LLvl-family, and LevelWarning,
+// LevelError, LevelFatal, LevelPanic, LevelInfo and LevelPrint for the
+// other messages. A nil palette resets all colors to the defaults.
+func SetPalette(p map[int]ct.Color) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	if p == nil {
+		palette = copyPalette(defaultPalette)
+		return
+	}
+	for l, c := range p {
+		palette[l] = c
+	}
+}
+
+// Palette returns a copy of the colors used for the levels.
+func Palette() map[int]ct.Color {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	return copyPalette(palette)
+}
+
+func copyPalette(p map[int]ct.Color) map[int]ct.Color {
+	cp := make(map[int]ct.Color, len(p))
+	for l, c := range p {
+		cp[l] = c
+	}
+	return cp
+}
+
 // UseColors returns the actual setting of the color-usage in log
 func UseColors() bool {
 	debugMut.Lock()
@@ -367,6 +599,43 @@ func UseColors() bool {
 	return useColors
 }
 
+// SetOutput changes the writers for the output: all errors, warnings and
+// fatal messages go to err, everything else goes to out. A nil writer
+// resets the output to os.Stdout or os.Stderr respectively.
+func SetOutput(out, err io.Writer) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	if out == nil {
+		out = os.Stdout
+	}
+	if err == nil {
+		err = os.Stderr
+	}
+	stdOut = out
+	stdErr = err
+}
+
+// SetLevelWriter directs all messages of the given level to w, overriding
+// the writers set with SetOutput. The level is 1..5 for the Lvl-family,
+// -1..-5 for the LLvl-family, or one of LevelInfo, LevelPrint, LevelWarning,
+// LevelError, LevelFatal and LevelPanic. A nil writer removes the override.
+func SetLevelWriter(level int, w io.Writer) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	if w == nil {
+		delete(levelWriters, level)
+		return
+	}
+	levelWriters[level] = w
+}
+
+// Output returns the writers for the standard and the error output.
+func Output() (out, err io.Writer) {
+	debugMut.RLock()
+	defer debugMut.RUnlock()
+	return stdOut, stdErr
+}
+
 // MainTest can be called from TestMain. It will parse the flags and
 // set the DebugVisible to defaultMainTest, then run the tests and check for
 // remaining go-routines.
@@ -401,6 +670,9 @@ func MainTest(m *testing.M, ls ...int) {
 //   DEBUG_LVL - for the actual debug-lvl - default is 1
 //   DEBUG_TIME - whether to show the timestamp - default is false
 //   DEBUG_COLOR - whether to color the output - default is false
+//   DEBUG_PADDING_NAME - the NamePadding, negative for a fixed width
+//   DEBUG_PADDING_LINE - the LinePadding, negative for a fixed width
+//   DEBUG_STACK - whether to print the stack on fatal errors - default is false
 func ParseEnv() {
 	var err error
 	dv := os.Getenv("DEBUG_LVL")
@@ -427,13 +699,67 @@ func ParseEnv() {
 			Error("Couldn't convert", dc, "to boolean")
 		}
 	}
+	ds := os.Getenv("DEBUG_STACK")
+	if ds != "" {
+		stackOnFatal, err = strconv.ParseBool(ds)
+		Lvl3("Setting stackOnFatal to", ds, stackOnFatal, err)
+		if err != nil {
+			Error("Couldn't convert", ds, "to boolean")
+		}
+	}
+	dpn := os.Getenv("DEBUG_PADDING_NAME")
+	if dpn != "" {
+		NamePadding, err = strconv.Atoi(dpn)
+		Lvl3("Setting NamePadding to", dpn, NamePadding, err)
+		if err != nil {
+			Error("Couldn't convert", dpn, "to padding")
+		}
+	}
+	dpl := os.Getenv("DEBUG_PADDING_LINE")
+	if dpl != "" {
+		LinePadding, err = strconv.Atoi(dpl)
+		Lvl3("Setting LinePadding to", dpl, LinePadding, err)
+		if err != nil {
+			Error("Couldn't convert", dpl, "to padding")
+		}
+	}
 }
 
 // RegisterFlags adds the flags and the variables for the debug-control using
-// the standard flag-package.
+// the standard flag-package. The flags only take effect once flag.Parse is
+// called, so RegisterFlags has to be called before flag.Parse.
 func RegisterFlags() {
+	registerFlags(flag.CommandLine)
+}
+
+// AddFlags registers the debug-flags like RegisterFlags and parses the
+// command-line, so the debug-level is set right away. It has to be called
+// after all other flags of the application are defined, else flag.Parse
+// fails on them. Calling flag.Parse again afterwards, or calling AddFlags
+// after RegisterFlags, does no harm.
+func AddFlags() {
+	// flag.CommandLine exits on errors, so there is nothing to return
+	addFlags(flag.CommandLine, os.Args[1:])
+}
+
+// addFlags registers the debug-flags to fs if they're not yet there and
+// parses args.
+func addFlags(fs *flag.FlagSet, args []string) error {
+	if fs.Lookup("debug") == nil {
+		registerFlags(fs)
+	}
+	return fs.Parse(args)
+}
+
+// registerFlags adds the flags for the debug-control to fs.
+func registerFlags(fs *flag.FlagSet) {
 	ParseEnv()
-	flag.IntVar(&debugVisible, "debug", DebugVisible(), "Change debug level (0-5)")
-	flag.BoolVar(&showTime, "debug-time", ShowTime(), "Shows the time of each message")
-	flag.BoolVar(&useColors, "debug-color", UseColors(), "Colors each message")
+	fs.IntVar(&debugVisible, "debug", DebugVisible(), "Change debug level (0-6)")
+	fs.BoolVar(&showTime, "debug-time", ShowTime(), "Shows the time of each message")
+	fs.BoolVar(&useColors, "debug-color", UseColors(), "Colors each message")
+	fs.BoolVar(&stackOnFatal, "debug-stack", StackOnFatal(), "Prints the stack on fatal errors")
+	fs.IntVar(&NamePadding, "debug-padding-name", NamePadding,
+		"Width of the function-names, negative for a fixed width")
+	fs.IntVar(&LinePadding, "debug-padding-line", LinePadding,
+		"Width of the line-numbers, negative for a fixed width")
 }
@@ -1,11 +1,19 @@
 package log
 
 import (
+	"bytes"
+	"fmt"
 	"os"
+	"strconv"
 	"strings"
 	"testing"
+	"time"
 
 	"errors"
+	"flag"
+
+	"github.com/daviddengcn/go-colortext"
+	"github.com/stretchr/testify/assert"
 )
 
 func init() {
@@ -78,6 +86,25 @@ func TestFlags(t *testing.T) {
 	SetUseColors(color)
 }
 
+func TestFlagsPadding(t *testing.T) {
+	defer func(n, l int) {
+		NamePadding = n
+		LinePadding = l
+	}(NamePadding, LinePadding)
+
+	os.Setenv("DEBUG_PADDING_NAME", "-20")
+	os.Setenv("DEBUG_PADDING_LINE", "5")
+	ParseEnv()
+	assert.Equal(t, -20, NamePadding)
+	assert.Equal(t, 5, LinePadding)
+
+	os.Setenv("DEBUG_PADDING_NAME", "")
+	os.Setenv("DEBUG_PADDING_LINE", "")
+	ParseEnv()
+	assert.Equal(t, -20, NamePadding)
+	assert.Equal(t, 5, LinePadding)
+}
+
 func TestOutputFuncs(t *testing.T) {
 	ErrFatal(checkOutput(func() {
 		Lvl1("Testing stdout")
@@ -192,3 +219,200 @@ func ExampleLvl3() {
 	// 1 : (log.thisIsAVeryLongFunctionNameThatWillOverflow:   0) - Overflow
 	// 1 : (log.ExampleLvl3:   0) - After
 }
+
+func TestSetOutput(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	SetDebugVisible(1)
+
+	var out, errOut bytes.Buffer
+	SetOutput(&out, &errOut)
+	tests := []struct {
+		f      func(...interface{})
+		msg    string
+		stdout string
+		stderr string
+	}{
+		{Lvl1, "one", "1 : (                       log.TestSetOutput:   0) - one\n", ""},
+		{Lvl2, "two", "", ""},
+		{Info, "info", "I : (                       log.TestSetOutput:   0) - info\n", ""},
+		{Error, "error", "", "E : (                       log.TestSetOutput:   0) - error\n"},
+	}
+	for _, test := range tests {
+		test.f(test.msg)
+		assert.Equal(t, test.stdout, out.String())
+		assert.Equal(t, test.stderr, errOut.String())
+		out.Reset()
+		errOut.Reset()
+	}
+
+	SetOutput(nil, nil)
+	o, e := Output()
+	assert.Equal(t, os.Stdout, o)
+	assert.Equal(t, os.Stderr, e)
+}
+
+func TestSetLevelWriter(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	SetDebugVisible(1)
+
+	var out, errOut, errFile, lvl2 bytes.Buffer
+	SetOutput(&out, &errOut)
+	SetLevelWriter(LevelError, &errFile)
+	SetLevelWriter(-2, &lvl2)
+	defer SetLevelWriter(LevelError, nil)
+	defer SetLevelWriter(-2, nil)
+
+	Lvl1("one")
+	LLvl2("two")
+	Warn("warning")
+	Error("error")
+	assert.Equal(t, "1 : (                  log.TestSetLevelWriter:   0) - one\n",
+		out.String())
+	assert.Equal(t, "2!: (                  log.TestSetLevelWriter:   0) - two\n",
+		lvl2.String())
+	assert.Equal(t, "W : (                  log.TestSetLevelWriter:   0) - warning\n",
+		errOut.String())
+	assert.Equal(t, "E : (                  log.TestSetLevelWriter:   0) - error\n",
+		errFile.String())
+
+	SetLevelWriter(LevelError, nil)
+	errOut.Reset()
+	Error("error")
+	assert.Equal(t, "E : (                  log.TestSetLevelWriter:   0) - error\n",
+		errOut.String())
+}
+
+func TestTrace(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	defer SetDebugVisible(1)
+	defer SetUseColors(false)
+	var out bytes.Buffer
+	SetOutput(&out, nil)
+
+	SetDebugVisible(5)
+	Trace("hidden")
+	Tracef("%s", "hidden")
+	assert.Equal(t, "", out.String())
+	LTrace("always")
+	assert.Equal(t, "6!: (                           log.TestTrace:   0) - always\n",
+		out.String())
+
+	out.Reset()
+	SetDebugVisible(6)
+	SetUseColors(true)
+	Tracef("%d", 6)
+	assert.Equal(t, "6 : (                           log.TestTrace:   0) - 6\n",
+		out.String())
+}
+
+func TestSetPalette(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	defer SetDebugVisible(1)
+	defer SetUseColors(false)
+	defer SetPalette(nil)
+	oldForeground := foreground
+	defer func() { foreground = oldForeground }()
+	var colors []ct.Color
+	foreground = func(c ct.Color, bright bool) {
+		colors = append(colors, c)
+	}
+	SetOutput(&bytes.Buffer{}, &bytes.Buffer{})
+	SetDebugVisible(2)
+	SetUseColors(true)
+
+	Lvl2("default")
+	Error("default")
+	assert.Equal(t, []ct.Color{ct.Cyan, ct.Red}, colors)
+
+	colors = nil
+	SetPalette(map[int]ct.Color{2: ct.Magenta, LevelError: ct.Blue})
+	Lvl2("custom")
+	LLvl2("custom")
+	Error("custom")
+	Lvl1("unchanged")
+	assert.Equal(t, []ct.Color{ct.Magenta, ct.Magenta, ct.Blue, ct.Yellow},
+		colors)
+	assert.Equal(t, ct.Magenta, Palette()[2])
+
+	colors = nil
+	SetPalette(nil)
+	Lvl2("default")
+	assert.Equal(t, []ct.Color{ct.Cyan}, colors)
+}
+
+func TestPadding(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	defer func(n, l int) {
+		NamePadding = n
+		LinePadding = l
+	}(NamePadding, LinePadding)
+	var out bytes.Buffer
+	SetOutput(&out, nil)
+	NamePadding = 40
+	LinePadding = 3
+
+	name := "log." + strings.Repeat("x", 50)
+	debugMut.Lock()
+	output(1, name, 1234, "long\n")
+	debugMut.Unlock()
+	assert.Equal(t, len(name), NamePadding)
+	assert.Equal(t, 4, LinePadding)
+	assert.Equal(t, "1 : ("+name+": 1234) - long\n", out.String())
+
+	out.Reset()
+	debugMut.Lock()
+	output(1, "log.short", 12, "short\n")
+	debugMut.Unlock()
+	assert.Equal(t, len(name), NamePadding)
+	assert.Equal(t, 4, LinePadding)
+	assert.Equal(t, fmt.Sprintf("1 : (%*s:   12) - short\n", len(name), "log.short"),
+		out.String())
+}
+
+func TestRelativeTime(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	var out bytes.Buffer
+	SetOutput(&out, nil)
+	SetDebugVisible(1)
+	SetShowTime(true)
+	defer SetShowTime(false)
+	SetRelativeTime(true)
+	defer SetRelativeTime(false)
+	assert.True(t, RelativeTime())
+
+	stamp := func() float64 {
+		out.Reset()
+		Lvl1("tick")
+		f, err := strconv.ParseFloat(strings.TrimSuffix(
+			strings.Fields(out.String())[1], ":"), 64)
+		assert.Nil(t, err)
+		return f
+	}
+	ResetClock()
+	last := stamp()
+	for i := 0; i < 5; i++ {
+		time.Sleep(2 * time.Millisecond)
+		now := stamp()
+		assert.True(t, now > last, now, "should be after", last)
+		last = now
+	}
+	assert.True(t, last >= 0.01)
+	ResetClock()
+	assert.True(t, stamp() < 0.01)
+}
+
+func TestAddFlags(t *testing.T) {
+	defer SetDebugVisible(DebugVisible())
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	assert.Nil(t, addFlags(fs, []string{"-debug=4"}))
+	assert.Equal(t, 4, DebugVisible())
+	// registering twice would panic
+	assert.Nil(t, addFlags(fs, []string{"-debug", "2"}))
+	assert.Equal(t, 2, DebugVisible())
+}
@@ -0,0 +1,145 @@
+package log
+
+import (
+	"fmt"
+	"math/rand"
+	"time"
+)
+
+// rateLimit is the maximum number of identical messages per second, 0
+// turns the rate-limiting off. It is protected by debugMut, as are
+// rateBuckets and rateSummary.
+var rateLimit int
+
+// rateBuckets holds one token-bucket per caller and message.
+var rateBuckets = map[string]*rateBucket{}
+
+// rateSummary is the last time the suppressed messages have been reported.
+var rateSummary time.Time
+
+// rateSummaryInterval is how often the suppressed messages are reported.
+var rateSummaryInterval = time.Second
+
+// rateTimer prints the summary once a flood stopped, so that it doesn't wait
+// for the next message.
+var rateTimer *time.Timer
+
+// rateBucket is a token-bucket for one caller and message. It remembers the
+// message so that the summary can be printed in its place.
+type rateBucket struct {
+	tokens     float64
+	last       time.Time
+	suppressed int
+	lvl        int
+	name       string
+	line       int
+	message    string
+}
+
+// SetRateLimit drops identical messages from the same caller once more than
+// maxPerSecond of them are printed in a second. Every second a summary
+// "(suppressed N messages)" is printed for every dropped message, also if
+// no other message follows. The
+// always-printing LLvl-family is never dropped. A value of 0 or lower turns
+// off the rate-limiting.
+func SetRateLimit(maxPerSecond int) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	rateLimit = maxPerSecond
+	rateBuckets = map[string]*rateBucket{}
+	if rateTimer != nil {
+		rateTimer.Stop()
+		rateTimer = nil
+	}
+	rateSummary = time.Now()
+}
+
+// RateLimit returns the maximum number of identical messages per second.
+func RateLimit() int {
+	debugMut.RLock()
+	defer debugMut.RUnlock()
+	return rateLimit
+}
+
+// rateLimitAllows returns true if the message can be printed. It also prints
+// the summaries of suppressed messages if they are due. debugMut must be
+// held by the caller.
+func rateLimitAllows(lvl int, name string, line int, message string) bool {
+	if rateLimit <= 0 {
+		return true
+	}
+	now := time.Now()
+	if now.Sub(rateSummary) >= rateSummaryInterval {
+		printSuppressed()
+		rateSummary = now
+	}
+	if lvl < 0 && lvl > lvlPrint {
+		return true
+	}
+	key := fmt.Sprintf("%s:%d:%s", name, line, message)
+	b, ok := rateBuckets[key]
+	if !ok {
+		b = &rateBucket{
+			tokens:  float64(rateLimit),
+			last:    now,
+			lvl:     lvl,
+			name:    name,
+			line:    line,
+			message: message,
+		}
+		rateBuckets[key] = b
+	}
+	b.tokens += now.Sub(b.last).Seconds() * float64(rateLimit)
+	if b.tokens > float64(rateLimit) {
+		b.tokens = float64(rateLimit)
+	}
+	b.last = now
+	if b.tokens < 1 {
+		b.suppressed++
+		if rateTimer == nil {
+			rateTimer = time.AfterFunc(rateSummaryInterval, func() {
+				debugMut.Lock()
+				defer debugMut.Unlock()
+				rateTimer = nil
+				printSuppressed()
+				rateSummary = time.Now()
+			})
+		}
+		return false
+	}
+	b.tokens--
+	return true
+}
+
+// printSuppressed outputs a summary for every message that has been dropped
+// and removes the buckets of the messages that are not limited anymore.
+// debugMut must be held by the caller.
+func printSuppressed() {
+	for key, b := range rateBuckets {
+		if b.suppressed > 0 {
+			output(b.lvl, b.name, b.line,
+				fmt.Sprintf("(suppressed %d messages) %s", b.suppressed,
+					b.message))
+			b.suppressed = 0
+		} else if b.tokens >= float64(rateLimit)-1 {
+			delete(rateBuckets, key)
+		}
+	}
+}
+
+// sampleRand decides which messages of LvlSampled are printed. It is seeded
+// with a fixed value so that runs are reproducible, and protected by
+// debugMut.
+var sampleRand = rand.New(rand.NewSource(1))
+
+// LvlSampled prints only about a fraction rate of the calls at the given
+// level, e.g. with a rate of 0.1 about every tenth message is printed. This
+// is useful in hot paths where every message would flood the output.
+func LvlSampled(rate float64, level int, args ...interface{}) {
+	debugMut.Lock()
+	show := sampleRand.Float64() < rate
+	debugMut.Unlock()
+	if show {
+		lvl(level, 2, args...)
+	}
+}
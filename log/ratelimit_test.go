@@ -0,0 +1,93 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetRateLimit(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	SetDebugVisible(1)
+	var out, errOut bytes.Buffer
+	SetOutput(&out, &errOut)
+	SetRateLimit(2)
+	defer SetRateLimit(0)
+	assert.Equal(t, 2, RateLimit())
+
+	for i := 0; i < 10; i++ {
+		Lvl1("flood")
+		Error("error flood")
+		LLvl1("always")
+	}
+	assert.Equal(t, 2, strings.Count(out.String(), "- flood\n"))
+	assert.Equal(t, 2, strings.Count(errOut.String(), "- error flood\n"))
+	assert.Equal(t, 10, strings.Count(out.String(), "- always\n"))
+
+	out.Reset()
+	errOut.Reset()
+	debugMut.Lock()
+	rateSummary = time.Now().Add(-rateSummaryInterval)
+	debugMut.Unlock()
+	Lvl1("other")
+	assert.Contains(t, out.String(), "- (suppressed 8 messages) flood\n")
+	assert.Contains(t, out.String(), "- other\n")
+	assert.Contains(t, errOut.String(), "- (suppressed 8 messages) error flood\n")
+
+	SetRateLimit(0)
+	out.Reset()
+	for i := 0; i < 10; i++ {
+		Lvl1("flood")
+	}
+	assert.Equal(t, 10, strings.Count(out.String(), "- flood\n"))
+}
+
+func TestSetRateLimitTimer(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	defer func(i time.Duration) { rateSummaryInterval = i }(rateSummaryInterval)
+	rateSummaryInterval = 10 * time.Millisecond
+	SetDebugVisible(1)
+	var out, errOut bytes.Buffer
+	SetOutput(&out, &errOut)
+	SetRateLimit(2)
+	defer SetRateLimit(0)
+
+	for i := 0; i < 5; i++ {
+		Lvl1("flood")
+	}
+	time.Sleep(50 * time.Millisecond)
+	debugMut.Lock()
+	str := out.String()
+	debugMut.Unlock()
+	assert.Equal(t, 2, strings.Count(str, "- flood\n"))
+	assert.Contains(t, str, "- (suppressed 3 messages) flood\n")
+}
+
+func TestLvlSampled(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	SetDebugVisible(1)
+	var out bytes.Buffer
+	SetOutput(&out, nil)
+
+	calls := 10000
+	for i := 0; i < calls; i++ {
+		LvlSampled(0.1, 1, "sample")
+	}
+	n := strings.Count(out.String(), "- sample\n")
+	assert.True(t, n > calls/10*8/10 && n < calls/10*12/10,
+		"Got", n, "messages instead of about", calls/10)
+	assert.Contains(t, out.String(), "log.TestLvlSampled:")
+
+	out.Reset()
+	LvlSampled(0, 1, "never")
+	LvlSampled(1, 1, "always")
+	LvlSampled(1, 2, "hidden")
+	assert.Equal(t, 1, strings.Count(out.String(), "\n"))
+	assert.Contains(t, out.String(), "- always\n")
+}
@@ -0,0 +1,73 @@
+package log
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// ErrSyslogUnsupported is returned by UseSyslog on platforms without a
+// system logger.
+var ErrSyslogUnsupported = errors.New("Syslog is not supported on this platform")
+
+// sysLogger is the part of *syslog.Writer used by the log-package.
+type sysLogger interface {
+	Crit(m string) error
+	Err(m string) error
+	Warning(m string) error
+	Info(m string) error
+	Debug(m string) error
+	Close() error
+}
+
+// sysLog receives all messages if it is not nil. It is protected by
+// debugMut.
+var sysLog sysLogger
+
+// setSyslog sends all messages to w and turns off the colors, or sends
+// them to the writers set with SetOutput again if w is nil. The previous
+// logger is closed.
+func setSyslog(w sysLogger) error {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	var err error
+	if sysLog != nil {
+		err = sysLog.Close()
+	}
+	sysLog = w
+	if w != nil {
+		useColors = false
+	}
+	return err
+}
+
+// CloseSyslog stops sending the messages to the system logger set up with
+// UseSyslog and prints them to the writers set with SetOutput again.
+func CloseSyslog() error {
+	return setSyslog(nil)
+}
+
+// writeSyslog sends msg to sysLog with a severity depending on lvl:
+// fatal messages and panics are critical, errors and warnings keep their
+// severity, Lvl1, Info and Print are informational and all other levels
+// are debug-messages. debugMut must be held by the caller.
+func writeSyslog(lvl int, msg string) {
+	msg = strings.TrimSuffix(msg, "\n")
+	var err error
+	switch lvl {
+	case lvlFatal, lvlPanic:
+		err = sysLog.Crit(msg)
+	case lvlError:
+		err = sysLog.Err(msg)
+	case lvlWarning:
+		err = sysLog.Warning(msg)
+	case lvlInfo, lvlPrint, 1, -1:
+		err = sysLog.Info(msg)
+	default:
+		err = sysLog.Debug(msg)
+	}
+	if err != nil {
+		// the system logger is gone, so this is the only place left
+		fmt.Fprintln(stdErr, "Couldn't write to syslog:", err, msg)
+	}
+}
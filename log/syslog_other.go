@@ -0,0 +1,10 @@
+// +build windows nacl plan9
+
+package log
+
+// UseSyslog always returns ErrSyslogUnsupported, as there is no system
+// logger on this platform. facility is an int, as the log/syslog package
+// is missing, too.
+func UseSyslog(tag string, facility int) error {
+	return ErrSyslogUnsupported
+}
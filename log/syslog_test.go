@@ -0,0 +1,66 @@
+package log
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSyslog records the messages by severity.
+type fakeSyslog struct {
+	msgs   map[string][]string
+	closed bool
+}
+
+func newFakeSyslog() *fakeSyslog {
+	return &fakeSyslog{msgs: map[string][]string{}}
+}
+
+func (f *fakeSyslog) add(sev, m string) error {
+	f.msgs[sev] = append(f.msgs[sev], m)
+	return nil
+}
+
+func (f *fakeSyslog) Crit(m string) error    { return f.add("crit", m) }
+func (f *fakeSyslog) Err(m string) error     { return f.add("err", m) }
+func (f *fakeSyslog) Warning(m string) error { return f.add("warning", m) }
+func (f *fakeSyslog) Info(m string) error    { return f.add("info", m) }
+func (f *fakeSyslog) Debug(m string) error   { return f.add("debug", m) }
+func (f *fakeSyslog) Close() error {
+	f.closed = true
+	return nil
+}
+
+func TestSyslog(t *testing.T) {
+	defer SetUseColors(UseColors())
+	defer SetDebugVisible(DebugVisible())
+	SetDebugVisible(3)
+	SetUseColors(true)
+	fs := newFakeSyslog()
+	require.Nil(t, setSyslog(fs))
+	assert.False(t, UseColors())
+
+	Error("failed")
+	Warn("careful")
+	Lvl1("one")
+	Lvl3("three")
+	Info("info")
+	Lvl4("hidden")
+	require.Nil(t, CloseSyslog())
+	assert.True(t, fs.closed)
+	assert.Equal(t, "", getStdOut())
+	assert.Equal(t, "", getStdErr())
+
+	assert.Equal(t, 1, len(fs.msgs["err"]))
+	assert.Contains(t, fs.msgs["err"][0], "E : (")
+	assert.Contains(t, fs.msgs["err"][0], "- failed")
+	assert.NotContains(t, fs.msgs["err"][0], "\n")
+	assert.Equal(t, 1, len(fs.msgs["warning"]))
+	assert.Equal(t, 2, len(fs.msgs["info"]))
+	assert.Equal(t, 1, len(fs.msgs["debug"]))
+	assert.Contains(t, fs.msgs["debug"][0], "- three")
+
+	Lvl1("back")
+	assert.Contains(t, getStdOut(), "back")
+}
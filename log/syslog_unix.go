@@ -0,0 +1,18 @@
+// +build !windows,!nacl,!plan9
+
+package log
+
+import "log/syslog"
+
+// UseSyslog sends all messages to the system logger instead of the writers
+// set with SetOutput, tagged with tag and using the given facility, e.g.
+// syslog.LOG_DAEMON. The severity of each message depends on its level, see
+// writeSyslog. The system logger adds the time, so SetShowTime has no
+// effect, and the colors are turned off.
+func UseSyslog(tag string, facility syslog.Priority) error {
+	w, err := syslog.New(facility|syslog.LOG_INFO, tag)
+	if err != nil {
+		return err
+	}
+	return setSyslog(w)
+}
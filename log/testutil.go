@@ -2,15 +2,64 @@ package log
 
 import (
 	"fmt"
+	"reflect"
 	"runtime"
 	"runtime/debug"
 	"sort"
 	"strings"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/daviddengcn/go-colortext"
 )
 
-func interestingGoroutines() (gs []string) {
+// ignoredLeaks holds the substrings added by IgnoreLeak, it is protected by
+// ignoredLeaksMut.
+var ignoredLeaks []string
+var ignoredLeaksMut sync.Mutex
+
+// IgnoreLeak makes AfterTest and MainTest ignore all goroutines whose stack
+// contains substr, for example the name of a function running in the
+// background on purpose:
+//	defer log.IgnoreLeak("monitor.(*Monitor).Listen")()
+// It is valid for all following calls to AfterTest, until the returned
+// function is called.
+func IgnoreLeak(substr string) func() {
+	ignoredLeaksMut.Lock()
+	defer ignoredLeaksMut.Unlock()
+	ignoredLeaks = append(ignoredLeaks, substr)
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			ignoredLeaksMut.Lock()
+			defer ignoredLeaksMut.Unlock()
+			for i, l := range ignoredLeaks {
+				if l == substr {
+					ignoredLeaks = append(ignoredLeaks[:i], ignoredLeaks[i+1:]...)
+					break
+				}
+			}
+		})
+	}
+}
+
+// isIgnoredLeak returns true if stack contains one of the substrings in
+// ignore or added by IgnoreLeak.
+func isIgnoredLeak(stack string, ignore []string) bool {
+	ignoredLeaksMut.Lock()
+	defer ignoredLeaksMut.Unlock()
+	for _, list := range [][]string{ignore, ignoredLeaks} {
+		for _, i := range list {
+			if strings.Contains(stack, i) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func interestingGoroutines(ignore []string) (gs []string) {
 	buf := make([]byte, 2<<20)
 	buf = buf[:runtime.Stack(buf, true)]
 	for _, g := range strings.Split(string(buf), "\n\n") {
@@ -27,7 +76,8 @@ func interestingGoroutines() (gs []string) {
 			strings.Contains(stack, "interestingGoroutines") ||
 			strings.Contains(stack, "created by runtime.gc") ||
 			strings.Contains(stack, "runtime.MHeap_Scavenger") ||
-			strings.Contains(stack, "log.MainTest") {
+			strings.Contains(stack, "log.MainTest") ||
+			isIgnoredLeak(stack, ignore) {
 			continue
 		}
 		gs = append(gs, stack)
@@ -38,15 +88,17 @@ func interestingGoroutines() (gs []string) {
 
 // AfterTest can be called to wait for leaking goroutines to finish. If
 // they do not finish after a reasonable time (600ms) the test will fail.
+// Goroutines whose stack contains one of the substrings in ignore or added
+// by IgnoreLeak are not taken into account.
 //
 // Inspired by https://golang.org/src/net/http/main_test.go
 // and https://github.com/coreos/etcd/blob/master/pkg/testutil/leak.go
-func AfterTest(t *testing.T) {
+func AfterTest(t *testing.T, ignore ...string) {
 	var stackCount map[string]int
 	for i := 0; i < 6; i++ {
 		n := 0
 		stackCount = make(map[string]int)
-		gs := interestingGoroutines()
+		gs := interestingGoroutines(ignore)
 		for _, g := range gs {
 			stackCount[g]++
 			n++
@@ -77,3 +129,158 @@ func AfterTest(t *testing.T) {
 func Stack() string {
 	return string(debug.Stack())
 }
+
+// Diff prints the expected and the actual value side by side. Structures,
+// slices and maps are split up in their fields, and every line where the two
+// values differ is marked with a '!' and printed in red if colors are
+// turned on.
+func Diff(expected, actual interface{}) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	lines := diffLines(expected, actual)
+	wKey, wExp := 0, len("expected")
+	for _, l := range lines {
+		if len(l.key) > wKey {
+			wKey = len(l.key)
+		}
+		if len(l.expected) > wExp {
+			wExp = len(l.expected)
+		}
+	}
+	format := fmt.Sprintf("%%s %%-%ds | %%-%ds | %%s\n", wKey, wExp)
+	fmt.Fprintf(stdOut, format, " ", "", "expected", "actual")
+	for _, l := range lines {
+		mark := " "
+		if l.expected != l.actual {
+			mark = "!"
+			fg(ct.Red, true)
+		}
+		fmt.Fprintf(stdOut, format, mark, l.key, l.expected, l.actual)
+		if useColors {
+			ct.ResetColor()
+		}
+	}
+}
+
+// diffLine is one field of the values compared in Diff.
+type diffLine struct {
+	key      string
+	expected string
+	actual   string
+}
+
+// diffLines splits both values in their fields and puts the fields with the
+// same name on the same line.
+func diffLines(expected, actual interface{}) []diffLine {
+	exp := diffFields(reflect.ValueOf(expected))
+	act := diffFields(reflect.ValueOf(actual))
+	var lines []diffLine
+	seen := make(map[string]bool)
+	for _, f := range exp {
+		seen[f[0]] = true
+		l := diffLine{key: f[0], expected: f[1], actual: "<missing>"}
+		for _, a := range act {
+			if a[0] == f[0] {
+				l.actual = a[1]
+				break
+			}
+		}
+		lines = append(lines, l)
+	}
+	for _, a := range act {
+		if !seen[a[0]] {
+			lines = append(lines, diffLine{key: a[0], expected: "<missing>",
+				actual: a[1]})
+		}
+	}
+	return lines
+}
+
+// diffFields returns the name and the formatted value of every field of v.
+// Values that have no fields are returned as one field with an empty name.
+func diffFields(v reflect.Value) [][2]string {
+	for v.Kind() == reflect.Ptr && !v.IsNil() {
+		v = v.Elem()
+	}
+	var fields [][2]string
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			fields = append(fields, [2]string{v.Type().Field(i).Name,
+				fmt.Sprintf("%+v", v.Field(i))})
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			fields = append(fields, [2]string{fmt.Sprintf("[%d]", i),
+				fmt.Sprintf("%+v", v.Index(i))})
+		}
+	case reflect.Map:
+		values := make(map[string]string)
+		var keys []string
+		for _, k := range v.MapKeys() {
+			key := fmt.Sprintf("[%v]", k)
+			keys = append(keys, key)
+			values[key] = fmt.Sprintf("%+v", v.MapIndex(k))
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			fields = append(fields, [2]string{k, values[k]})
+		}
+	case reflect.Invalid:
+		fields = append(fields, [2]string{"", "<nil>"})
+	default:
+		fields = append(fields, [2]string{"", fmt.Sprintf("%+v", v)})
+	}
+	return fields
+}
+
+// Captured is one message logged while capturing. The caller is separated
+// from the message, so that tests don't depend on the padding.
+type Captured struct {
+	// Level is the level as printed, e.g. "2", "1!", "E" or "W". It is empty
+	// for messages printed with FormatNone or FormatPython.
+	Level string
+	// Caller is the function and the line-number of the caller without
+	// padding
+	Caller string
+	// Message is the message without the trailing newline
+	Message string
+}
+
+// capturing is true between StartCapture and StopCapture. It is protected
+// by debugMut, as is captured.
+var capturing bool
+var captured []Captured
+
+// StartCapture records all messages that are printed until StopCapture is
+// called. The messages are still written to the output.
+func StartCapture() {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	capturing = true
+	captured = nil
+}
+
+// StopCapture stops the capture and returns all messages printed since
+// StartCapture.
+func StopCapture() []Captured {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	capturing = false
+	c := captured
+	captured = nil
+	return c
+}
+
+// capture records the message if StartCapture has been called. debugMut must
+// be held by the caller.
+func capture(level, caller, message string) {
+	if !capturing {
+		return
+	}
+	captured = append(captured, Captured{
+		Level:   level,
+		Caller:  caller,
+		Message: strings.TrimSuffix(message, "\n"),
+	})
+}
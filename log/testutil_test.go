@@ -0,0 +1,93 @@
+package log
+
+import (
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type diffStruct struct {
+	Name  string
+	Value int
+	List  []int
+}
+
+func TestDiff(t *testing.T) {
+	getStdOut()
+	Diff(diffStruct{"one", 1, []int{1, 2}}, &diffStruct{"one", 2, []int{1, 2}})
+	lines := strings.Split(strings.TrimSpace(getStdOut()), "\n")
+	assert.Equal(t, 4, len(lines))
+	assert.Contains(t, lines[0], "expected")
+	assert.True(t, strings.HasPrefix(lines[1], "  Name "), lines[1])
+	assert.True(t, strings.HasPrefix(lines[2], "! Value"), lines[2])
+	assert.Contains(t, lines[2], "| 1 ")
+	assert.Contains(t, lines[2], "| 2")
+	assert.True(t, strings.HasPrefix(lines[3], "  List "), lines[3])
+	// columns have to be aligned
+	assert.Equal(t, strings.Index(lines[1], "|"), strings.Index(lines[2], "|"))
+
+	Diff(map[string]int{"a": 1}, map[string]int{"a": 1, "b": 2})
+	out := getStdOut()
+	assert.Contains(t, out, "  [a]")
+	assert.Contains(t, out, "! [b]")
+	assert.Contains(t, out, "<missing>")
+}
+
+func TestCapture(t *testing.T) {
+	SetDebugVisible(1)
+	getStdOut()
+	getStdErr()
+	StartCapture()
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			Lvl1("routine", i)
+		}(i)
+	}
+	wg.Wait()
+	Lvl2("hidden")
+	Error("something failed")
+	c := StopCapture()
+	Lvl1("not captured")
+
+	assert.Equal(t, 11, len(c))
+	assert.Equal(t, Captured{"E", "log.TestCapture:0", "something failed"}, c[10])
+	assert.Equal(t, "1", c[0].Level)
+	assert.Contains(t, c[0].Message, "routine")
+	assert.Equal(t, 11, strings.Count(getStdOut(), "\n"))
+	assert.Contains(t, getStdErr(), "something failed")
+	assert.Equal(t, 0, len(StopCapture()))
+}
+
+func leakingForTest(started, done chan bool) {
+	started <- true
+	<-done
+}
+
+func leakingIgnoredForTest(started, done chan bool) {
+	started <- true
+	<-done
+}
+
+func TestAfterTestIgnore(t *testing.T) {
+	started := make(chan bool)
+	done := make(chan bool)
+	defer close(done)
+	go leakingForTest(started, done)
+	go leakingIgnoredForTest(started, done)
+	<-started
+	<-started
+	assert.Equal(t, 2, len(interestingGoroutines(nil)))
+	AfterTest(t, "log.leakingForTest", "log.leakingIgnoredForTest")
+
+	remove := IgnoreLeak("log.leakingIgnoredForTest")
+	AfterTest(t, "log.leakingForTest")
+	assert.Equal(t, 1, len(interestingGoroutines(nil)))
+	remove()
+	remove()
+	assert.Equal(t, 2, len(interestingGoroutines(nil)))
+}
@@ -0,0 +1,47 @@
+package log
+
+import "time"
+
+// Stopwatch measures the time between its creation by Timer and the call to
+// Done. It is a value and doesn't allocate, so it can also be used in hot
+// paths.
+type Stopwatch struct {
+	label string
+	lvl   int
+	start time.Time
+}
+
+// Timer returns a Stopwatch that prints label and the elapsed time at
+// debug-level 1 once Done is called:
+//	defer log.Timer("Build").Done()
+// prints
+//	1 : (          platform.(*Deterlab).Build: 179) - Build finished after 1m2.5s
+func Timer(label string) Stopwatch {
+	return Stopwatch{label: label, lvl: 1, start: time.Now()}
+}
+
+// Lvl returns a copy of the Stopwatch printing at level l, which can be any
+// level of the Lvl- or LLvl-family:
+//	defer log.Timer("Round").Lvl(3).Done()
+func (s Stopwatch) Lvl(l int) Stopwatch {
+	s.lvl = l
+	return s
+}
+
+// Done prints the label and the time elapsed since the call to Timer, if
+// the level of the Stopwatch is visible. It returns the elapsed time.
+func (s Stopwatch) Done() time.Duration {
+	d := time.Since(s.start)
+	if levelVisible(s.lvl) {
+		lvl(s.lvl, 2, s.label, "finished after", d)
+	}
+	return d
+}
+
+// levelVisible returns true if a message of level l would be printed or
+// sent to a channel.
+func levelVisible(l int) bool {
+	debugMut.RLock()
+	defer debugMut.RUnlock()
+	return l <= debugVisible || channelsWant(l, debugVisible)
+}
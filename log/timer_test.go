@@ -0,0 +1,41 @@
+package log
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimer(t *testing.T) {
+	oldOut, oldErr := Output()
+	defer SetOutput(oldOut, oldErr)
+	SetDebugVisible(1)
+	var out bytes.Buffer
+	SetOutput(&out, nil)
+
+	func() {
+		defer Timer("Sleep").Done()
+		time.Sleep(10 * time.Millisecond)
+	}()
+	assert.True(t, strings.HasPrefix(out.String(),
+		"1 : (                     log.TestTimer.func1:   0) - Sleep finished after"),
+		out.String())
+
+	out.Reset()
+	d := Timer("Hidden").Lvl(2).Done()
+	assert.Equal(t, "", out.String())
+	assert.True(t, d >= 0)
+
+	Timer("Forced").Lvl(-2).Done()
+	assert.True(t, strings.HasPrefix(out.String(), "2!: "), out.String())
+}
+
+func BenchmarkTimerHidden(b *testing.B) {
+	SetDebugVisible(1)
+	for i := 0; i < b.N; i++ {
+		Timer("Hidden").Lvl(3).Done()
+	}
+}
@@ -3,17 +3,27 @@ package log
 import (
 	"fmt"
 	"os"
+	"runtime"
 	"strconv"
+	"strings"
 )
 
 func lvlUI(l int, args ...interface{}) {
-	if debugVisible > 0 {
+	if useLvlFormat() {
 		lvl(l, 3, args...)
-	} else {
-		print(l, args...)
+	} else if msg, ok := print(l, 3, args...); ok {
+		callHooks(l, "", msg)
 	}
 }
 
+// useLvlFormat returns true if the common messages are to be printed like
+// the log-level messages.
+func useLvlFormat() bool {
+	debugMut.RLock()
+	defer debugMut.RUnlock()
+	return format == FormatLvl && debugVisible > 0
+}
+
 // Info prints the arguments given with a 'info'-format
 func Info(args ...interface{}) {
 	lvlUI(lvlInfo, args...)
@@ -43,7 +53,7 @@ func Panic(args ...interface{}) {
 // Fatal prints out the fatal message and quits
 func Fatal(args ...interface{}) {
 	lvlUI(lvlFatal, args...)
-	os.Exit(1)
+	exit(1)
 }
 
 // Infof takes a format-string and calls Info
@@ -75,14 +85,14 @@ func Panicf(f string, args ...interface{}) {
 // Fatalf is like Fatal but with a format-string
 func Fatalf(f string, args ...interface{}) {
 	lvlUI(lvlFatal, fmt.Sprintf(f, args...))
-	os.Exit(-1)
+	exit(-1)
 }
 
 // ErrFatal calls log.Fatal in the case err != nil
 func ErrFatal(err error, args ...interface{}) {
 	if err != nil {
 		lvlUI(lvlFatal, err.Error()+" "+fmt.Sprint(args...))
-		os.Exit(1)
+		exit(1)
 	}
 }
 
@@ -90,26 +100,92 @@ func ErrFatal(err error, args ...interface{}) {
 func ErrFatalf(err error, f string, args ...interface{}) {
 	if err != nil {
 		lvlUI(lvlFatal, err.Error()+fmt.Sprintf(" "+f, args...))
-		os.Exit(1)
+		exit(1)
 	}
 }
 
-func print(lvl int, args ...interface{}) {
-	switch debugVisible {
+// LogErr calls log.Error with the args and the error in the case err != nil.
+// It returns err unchanged, so a failing call can be logged and returned
+// with a single `return log.LogErr(err, "while deploying")`.
+func LogErr(err error, args ...interface{}) error {
+	if err != nil {
+		lvlUI(lvlError, append(args, err)...)
+	}
+	return err
+}
+
+// LogErrf is like LogErr but with a format-string
+func LogErrf(err error, f string, args ...interface{}) error {
+	if err != nil {
+		lvlUI(lvlError, fmt.Sprintf(f, args...), err)
+	}
+	return err
+}
+
+// exit prints the stack of the current goroutine if SetStackOnFatal is
+// on, and then exits with the given code.
+func exit(code int) {
+	if StackOnFatal() {
+		debugMut.Lock()
+		fmt.Fprint(stdErr, "Stack:\n", Stack())
+		debugMut.Unlock()
+	}
+	osExit(code)
+}
+
+// osExit is replaced in the tests.
+var osExit = os.Exit
+
+// print writes the message without the caller, as used by FormatPython and
+// FormatNone. Like the log-level messages, it goes to the writer of its level
+// and through the filters and the channels. If the message has been printed,
+// it returns it without the trailing newline, so that the hooks can be called
+// once debugMut is released.
+func print(lvl, skip int, args ...interface{}) (string, bool) {
+	debugMut.Lock()
+	defer debugMut.Unlock()
+	pc, _, line, _ := runtime.Caller(skip)
+	name := callerName(pc)
+	if !outputLines {
+		line = 0
+	}
+	message := fmt.Sprintln(args...)
+	if !errorAllows(lvl, name, line, message) {
+		return "", false
+	}
+	if !rateLimitAllows(lvl, name, line, message) {
+		return "", false
+	}
+	sendChannels(lvl, debugVisible, name, line, strings.TrimSuffix(message, "\n"))
+	f := format
+	if f == FormatLvl {
+		f = debugVisible
+	}
+	if sysLog != nil {
+		writeSyslog(lvl, message)
+		capture("", "", message)
+		return strings.TrimSuffix(message, "\n"), true
+	}
+	w := levelWriter(lvl)
+	switch f {
 	case FormatPython:
 		prefix := []string{"[-]", "[!]", "[X]", "[Q]", "[+]", ""}
 		ind := lvl - lvlWarning
-		if ind < 0 || ind > 4 {
+		if ind < 0 || ind >= len(prefix) {
 			panic("index out of range " + strconv.Itoa(ind))
 		}
-		fmt.Fprint(stdOut, prefix[ind], " ")
+		if prefix[ind] != "" {
+			fmt.Fprint(w, prefix[ind], " ")
+		}
 	case FormatNone:
 	}
 	for i, a := range args {
-		fmt.Fprint(stdOut, a)
+		fmt.Fprint(w, a)
 		if i != len(args)-1 {
-			fmt.Fprint(stdOut, " ")
+			fmt.Fprint(w, " ")
 		}
 	}
-	fmt.Fprint(stdOut, "\n")
+	fmt.Fprint(w, "\n")
+	capture("", "", message)
+	return strings.TrimSuffix(message, "\n"), true
 }
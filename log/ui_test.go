@@ -5,7 +5,9 @@ import (
 
 	"bufio"
 	"bytes"
+	"errors"
 	"os"
+	"strings"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -70,3 +72,83 @@ func TestLvl(t *testing.T) {
 	assert.Equal(t, "W : (                             log.TestLvl:   0) - TestLvl\n",
 		getStdErr())
 }
+
+func TestSetFormat(t *testing.T) {
+	SetDebugVisible(1)
+	assert.Equal(t, FormatLvl, Format())
+	SetFormat(FormatPython)
+	defer SetFormat(FormatLvl)
+	Info("info")
+	Warn("warning")
+	Error("error")
+	Print("print")
+	assert.Equal(t, "[+] info\nprint\n", getStdOut())
+	assert.Equal(t, "[-] warning\n[!] error\n", getStdErr())
+	Lvl1("still lvl")
+	assert.Contains(t, getStdOut(), "1 : (")
+
+	SetFormat(FormatNone)
+	Info("none")
+	assert.Equal(t, "none\n", getStdOut())
+
+	// The messages go through the filters, the channels and the hooks.
+	SetSuppressRepeatedErrors(true)
+	ch := Subscribe()
+	var hooked []string
+	AddHook(func(level int, caller, msg string) {
+		hooked = append(hooked, msg)
+	})
+	Error("repeated")
+	Error("repeated")
+	WithFields(map[string]interface{}{"id": 1}).Warn("fields")
+	SetSuppressRepeatedErrors(false)
+	ClearHooks()
+	Unsubscribe(ch)
+	assert.Equal(t, 1, strings.Count(getStdErr(), "repeated\n"))
+	assert.Equal(t, 2, len(hooked))
+	assert.Equal(t, "repeated", hooked[0])
+	assert.Contains(t, hooked[1], "fields")
+	e := <-ch
+	assert.Equal(t, "repeated", e.Message)
+	e = <-ch
+	assert.Contains(t, e.Message, "fields")
+}
+
+func TestStackOnFatal(t *testing.T) {
+	defer func() { osExit = os.Exit }()
+	var code int
+	osExit = func(c int) { code = c }
+	SetDebugVisible(1)
+
+	ErrFatal(errors.New("no stack"))
+	assert.Equal(t, 1, code)
+	assert.NotContains(t, getStdErr(), "Stack:")
+
+	SetStackOnFatal(true)
+	defer SetStackOnFatal(false)
+	code = 0
+	Fatalf("with %s", "stack")
+	assert.Equal(t, -1, code)
+	out := getStdErr()
+	assert.Contains(t, out, "with stack")
+	assert.Contains(t, out, "Stack:")
+	assert.Contains(t, out, "log.TestStackOnFatal")
+
+	os.Setenv("DEBUG_STACK", "false")
+	defer os.Setenv("DEBUG_STACK", "")
+	ParseEnv()
+	assert.False(t, StackOnFatal())
+}
+
+func TestLogErr(t *testing.T) {
+	SetDebugVisible(1)
+	assert.Nil(t, LogErr(nil, "nothing"))
+	assert.Nil(t, LogErrf(nil, "nothing %d", 1))
+	assert.Equal(t, "", getStdErr())
+
+	err := errors.New("failed")
+	assert.Equal(t, err, LogErr(err, "while deploying"))
+	assert.Contains(t, getStdErr(), "while deploying failed\n")
+	assert.Equal(t, err, LogErrf(err, "while deploying %d:", 2))
+	assert.Contains(t, getStdErr(), "while deploying 2: failed\n")
+}
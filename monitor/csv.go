@@ -0,0 +1,53 @@
+package monitor
+
+import (
+	"encoding/csv"
+	"io"
+	"sort"
+	"strconv"
+)
+
+// CSVHeader are the columns written by WriteCSV.
+var CSVHeader = []string{"host", "round", "measure", "value"}
+
+// WriteCSV writes the measures received so far with one line per host,
+// round and measure, where host and round are the ones sent with the measure.
+// Only the last Metrics.MaxRounds rounds of every host and measure are kept.
+// The lines are sorted by measure, host and round and the values are written
+// with a fixed precision, so that two runs can be compared with diff. It
+// doesn't change the Stats, which are still written by WriteHeader and
+// WriteValues.
+func (m *Monitor) WriteCSV(w io.Writer) error {
+	return m.metrics.WriteCSV(w)
+}
+
+// WriteCSV writes the metrics as described in Monitor.WriteCSV.
+func (m *Metrics) WriteCSV(w io.Writer) error {
+	m.Lock()
+	defer m.Unlock()
+	cw := csv.NewWriter(w)
+	if err := cw.Write(CSVHeader); err != nil {
+		return err
+	}
+	for _, k := range m.sortedKeys() {
+		rounds := append(byRound{}, m.values[k].rounds...)
+		sort.Stable(rounds)
+		for _, r := range rounds {
+			err := cw.Write([]string{k.host, strconv.Itoa(r.round), k.name,
+				strconv.FormatFloat(r.value, 'f', 6, 64)})
+			if err != nil {
+				return err
+			}
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// byRound sorts the values by round, as measures of different connections
+// can arrive out of order.
+type byRound []roundValue
+
+func (r byRound) Len() int           { return len(r) }
+func (r byRound) Swap(i, j int)      { r[i], r[j] = r[j], r[i] }
+func (r byRound) Less(i, j int) bool { return r[i].round < r[j].round }
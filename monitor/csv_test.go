@@ -0,0 +1,52 @@
+package monitor
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dedis/cothority/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsWriteCSV(t *testing.T) {
+	m := NewMetrics()
+	m.Update(&SingleMeasure{Name: "round_wall", Value: 0.1, Host: "10.0.0.2"})
+	m.Update(&SingleMeasure{Name: "round_wall", Value: 2, Host: "10.0.0.1",
+		Round: 1})
+	m.Update(&SingleMeasure{Name: "round_wall", Value: 1.0 / 3, Host: "10.0.0.1"})
+	m.Update(&SingleMeasure{Name: "bandwidth_tx", Value: 1024, Host: "10.0.0.1",
+		Round: 3})
+
+	b := new(bytes.Buffer)
+	log.ErrFatal(m.WriteCSV(b))
+	assert.Equal(t, "host,round,measure,value\n"+
+		"10.0.0.1,3,bandwidth_tx,1024.000000\n"+
+		"10.0.0.1,0,round_wall,0.333333\n"+
+		"10.0.0.1,1,round_wall,2.000000\n"+
+		"10.0.0.2,0,round_wall,0.100000\n", b.String())
+
+	b.Reset()
+	log.ErrFatal(NewMetrics().WriteCSV(b))
+	assert.Equal(t, "host,round,measure,value\n", b.String())
+}
+
+func TestMetricsMaxRounds(t *testing.T) {
+	m := NewMetrics()
+	m.MaxRounds = 2
+	for i := 0; i < 5; i++ {
+		m.Update(&SingleMeasure{Name: "round_wall", Value: float64(i),
+			Host: "10.0.0.1", Round: i})
+	}
+	b := new(bytes.Buffer)
+	log.ErrFatal(m.WriteCSV(b))
+	assert.Equal(t, "host,round,measure,value\n"+
+		"10.0.0.1,3,round_wall,3.000000\n"+
+		"10.0.0.1,4,round_wall,4.000000\n", b.String())
+
+	// the other metrics still see all measures
+	b.Reset()
+	_, err := m.WriteTo(b)
+	log.ErrFatal(err)
+	assert.Contains(t, b.String(),
+		`cothority_measure_count{host="10.0.0.1",name="round_wall"} 5`)
+}
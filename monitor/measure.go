@@ -5,6 +5,8 @@ import (
 	"errors"
 	"fmt"
 	"net"
+	"os"
+	"sync"
 	"time"
 
 	"github.com/dedis/cothority/log"
@@ -14,6 +16,13 @@ import (
 // further analysis.
 var sink string
 
+// host is sent with every measure to tell the monitor where it comes from.
+var host string
+
+// rounds counts how many times every measure has been recorded.
+var rounds = make(map[string]int)
+var roundsMut sync.Mutex
+
 // Structs are encoded through a json encoder.
 var encoder *json.Encoder
 var connection net.Conn
@@ -46,6 +55,13 @@ type Measure interface {
 type SingleMeasure struct {
 	Name  string
 	Value float64
+	// Host is the name or address of the sender, filled in by Record. The
+	// monitor uses it to tell the measures of different hosts apart, even
+	// if they come through a proxy.
+	Host string
+	// Round is how many times a measure of this name has been recorded by
+	// the sender before, filled in by Record.
+	Round int
 }
 
 // TimeMeasure represents a measure regarding time: It includes the wallclock
@@ -74,12 +90,29 @@ func ConnectSink(addr string) error {
 		return err
 	}
 	log.Lvl3("Connected to sink:", addr)
+	if host == "" {
+		if host, err = os.Hostname(); err != nil {
+			log.Lvl2("Couldn't get hostname:", err)
+		}
+	}
 	sink = addr
 	connection = conn
 	encoder = json.NewEncoder(conn)
 	return nil
 }
 
+// SetHost sets the name sent with every measure, usually the address of the
+// conode. If it is not set, the hostname is used.
+func SetHost(h string) {
+	host = h
+}
+
+// Connected returns true if a sink has been connected using ConnectSink
+// and the measures are enabled.
+func Connected() bool {
+	return encoder != nil && enabled
+}
+
 // NewSingleMeasure returns a new measure freshly generated
 func NewSingleMeasure(name string, value float64) *SingleMeasure {
 	return &SingleMeasure{
@@ -90,6 +123,11 @@ func NewSingleMeasure(name string, value float64) *SingleMeasure {
 
 // Record sends the value to the monitor. Reset the value to 0.
 func (sm *SingleMeasure) Record() {
+	sm.Host = host
+	roundsMut.Lock()
+	sm.Round = rounds[sm.Name]
+	rounds[sm.Name]++
+	roundsMut.Unlock()
 	if err := send(sm); err != nil {
 		log.Error("Error sending SingleMeasure", sm.Name, " to monitor:", err)
 	}
@@ -0,0 +1,204 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/dedis/cothority/log"
+)
+
+// This file exports the measures received by the monitor in the text-format
+// understood by Prometheus, so that a running simulation can be scraped and
+// shown in Grafana.
+
+// DefaultMetricsPort is the port where the monitor serves the metrics if
+// enabled. DefaultSinkPort+1 is already taken by the proxy.
+const DefaultMetricsPort = DefaultSinkPort + 2
+
+// MetricsPath is the path of the HTTP-endpoint serving the metrics.
+const MetricsPath = "/metrics"
+
+// metricPrefix is prepended to all exported metrics.
+const metricPrefix = "cothority_measure"
+
+// Metrics holds the latest value, the sum and the number of measures received
+// for every host and measure-name. It can be written in the Prometheus
+// text-format using WriteTo or served over HTTP.
+type Metrics struct {
+	// MaxRounds is how many rounds are kept per host and measure for
+	// WriteCSV. Older rounds are dropped.
+	MaxRounds int
+	values    map[metricKey]*metric
+	sync.Mutex
+}
+
+// DefaultMaxRounds is the number of rounds kept by NewMetrics.
+const DefaultMaxRounds = 1000
+
+type metricKey struct {
+	host string
+	name string
+}
+
+type metric struct {
+	last  float64
+	sum   float64
+	count uint64
+	// the last MaxRounds values in the order they have been received, for
+	// WriteCSV
+	rounds []roundValue
+}
+
+type roundValue struct {
+	round int
+	value float64
+}
+
+// NewMetrics returns an empty Metrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		MaxRounds: DefaultMaxRounds,
+		values:    make(map[metricKey]*metric),
+	}
+}
+
+// Update stores the measure as coming from meas.Host.
+func (m *Metrics) Update(meas *SingleMeasure) {
+	m.Lock()
+	defer m.Unlock()
+	k := metricKey{meas.Host, meas.Name}
+	v, ok := m.values[k]
+	if !ok {
+		v = &metric{}
+		m.values[k] = v
+	}
+	v.last = meas.Value
+	v.sum += meas.Value
+	v.count++
+	v.rounds = append(v.rounds, roundValue{meas.Round, meas.Value})
+	if over := len(v.rounds) - m.MaxRounds; over > 0 {
+		v.rounds = append(v.rounds[:0], v.rounds[over:]...)
+	}
+}
+
+// WriteTo writes all metrics in the Prometheus text-format to w. The
+// metrics are sorted by measure-name and host, so that two calls with the
+// same measures give the same output.
+func (m *Metrics) WriteTo(w io.Writer) (int64, error) {
+	m.Lock()
+	keys := m.sortedKeys()
+	var b bytes.Buffer
+	for _, t := range []struct {
+		suffix, typ, help string
+		value             func(*metric) string
+	}{
+		{"", "gauge", "Last value received for a measure.",
+			func(v *metric) string { return formatFloat(v.last) }},
+		{"_sum", "counter", "Sum of all values received for a measure.",
+			func(v *metric) string { return formatFloat(v.sum) }},
+		{"_count", "counter", "Number of values received for a measure.",
+			func(v *metric) string { return strconv.FormatUint(v.count, 10) }},
+	} {
+		name := metricPrefix + t.suffix
+		fmt.Fprintf(&b, "# HELP %s %s\n", name, t.help)
+		fmt.Fprintf(&b, "# TYPE %s %s\n", name, t.typ)
+		for _, k := range keys {
+			fmt.Fprintf(&b, "%s{host=\"%s\",name=\"%s\"} %s\n", name,
+				labelEscaper.Replace(k.host), labelEscaper.Replace(k.name),
+				t.value(m.values[k]))
+		}
+	}
+	m.Unlock()
+	n, err := w.Write(b.Bytes())
+	return int64(n), err
+}
+
+// sortedKeys returns the keys sorted by measure-name and host. It must be
+// called with the lock held.
+func (m *Metrics) sortedKeys() metricKeys {
+	keys := make(metricKeys, 0, len(m.values))
+	for k := range m.values {
+		keys = append(keys, k)
+	}
+	sort.Sort(keys)
+	return keys
+}
+
+// ServeHTTP implements http.Handler and writes all metrics.
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	if _, err := m.WriteTo(w); err != nil {
+		log.Lvl2("Couldn't write metrics:", err)
+	}
+}
+
+// labelEscaper escapes the characters that are not allowed in a label-value.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// metricKeys sorts by name first, then by host.
+type metricKeys []metricKey
+
+func (mk metricKeys) Len() int      { return len(mk) }
+func (mk metricKeys) Swap(i, j int) { mk[i], mk[j] = mk[j], mk[i] }
+func (mk metricKeys) Less(i, j int) bool {
+	if mk[i].name != mk[j].name {
+		return mk[i].name < mk[j].name
+	}
+	return mk[i].host < mk[j].host
+}
+
+// listenMetrics starts serving the metrics on MetricsPort.
+func (m *Monitor) listenMetrics() error {
+	ln, err := net.Listen("tcp", Sink+":"+strconv.Itoa(m.MetricsPort))
+	if err != nil {
+		return fmt.Errorf("Error while monitor is binding metrics address: %v", err)
+	}
+	m.listenerLock.Lock()
+	m.metricsListener = ln
+	m.listenerLock.Unlock()
+	log.Lvl2("Monitor serving metrics on", Sink, ":", m.MetricsPort)
+	mux := http.NewServeMux()
+	mux.Handle(MetricsPath, m.metrics)
+	srv := &http.Server{Handler: mux}
+	// Scrapes are rare, so don't keep connections around once the
+	// listener is closed.
+	srv.SetKeepAlivesEnabled(false)
+	go func() {
+		// Serve returns once the listener is closed by stopMetrics.
+		srv.Serve(ln)
+	}()
+	return nil
+}
+
+// stopMetrics closes the metrics-listener if it is running. It must be
+// called with listenerLock held.
+func (m *Monitor) stopMetrics() {
+	if m.metricsListener == nil {
+		return
+	}
+	if err := m.metricsListener.Close(); err != nil {
+		log.Error("Couldn't close metrics listener:", err)
+	}
+	m.metricsListener = nil
+}
+
+// hostOf returns the host-part of a connection's remote address. It is only
+// used for measures of senders that don't fill in SingleMeasure.Host.
+func hostOf(conn net.Conn) string {
+	host, _, err := net.SplitHostPort(conn.RemoteAddr().String())
+	if err != nil {
+		return conn.RemoteAddr().String()
+	}
+	return host
+}
@@ -0,0 +1,86 @@
+package monitor
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dedis/cothority/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsWriteTo(t *testing.T) {
+	m := NewMetrics()
+	m.Update(&SingleMeasure{Name: "round_wall", Value: 2, Host: "10.0.0.2"})
+	m.Update(&SingleMeasure{Name: "round_wall", Value: 1, Host: "10.0.0.1"})
+	m.Update(&SingleMeasure{Name: "round_wall", Value: 3, Host: "10.0.0.1"})
+	m.Update(&SingleMeasure{Name: "bandwidth\"tx", Value: 1024, Host: "10.0.0.1"})
+
+	b := new(bytes.Buffer)
+	_, err := m.WriteTo(b)
+	log.ErrFatal(err)
+	out := b.String()
+	for _, line := range []string{
+		"# TYPE cothority_measure gauge",
+		"# TYPE cothority_measure_sum counter",
+		`cothority_measure{host="10.0.0.1",name="round_wall"} 3`,
+		`cothority_measure_sum{host="10.0.0.1",name="round_wall"} 4`,
+		`cothority_measure_count{host="10.0.0.1",name="round_wall"} 2`,
+		`cothority_measure_count{host="10.0.0.2",name="round_wall"} 1`,
+		`cothority_measure{host="10.0.0.1",name="bandwidth\"tx"} 1024`,
+	} {
+		assert.Contains(t, out, line+"\n")
+	}
+	assert.True(t, strings.Index(out, `host="10.0.0.1",name="round_wall"`) <
+		strings.Index(out, `host="10.0.0.2",name="round_wall"`))
+
+	b2 := new(bytes.Buffer)
+	_, err = m.WriteTo(b2)
+	log.ErrFatal(err)
+	assert.Equal(t, out, b2.String())
+}
+
+func TestMonitorMetrics(t *testing.T) {
+	stat := NewStats(map[string]string{"servers": "1"})
+	mon := NewMonitor(stat)
+	mon.SinkPort = DefaultSinkPort + 20
+	mon.MetricsPort = DefaultMetricsPort + 20
+	go mon.Listen()
+	time.Sleep(100 * time.Millisecond)
+	log.ErrFatal(ConnectSink("localhost:" + strconv.Itoa(mon.SinkPort)))
+	EnableMeasure(true)
+	SetHost("conode-1:2000")
+	defer SetHost("")
+
+	first := NewSingleMeasure("round", 10)
+	first.Record()
+	time.Sleep(100 * time.Millisecond)
+
+	url := "http://localhost:" + strconv.Itoa(mon.MetricsPort) + MetricsPath
+	client := &http.Client{Transport: &http.Transport{DisableKeepAlives: true}}
+	resp, err := client.Get(url)
+	log.ErrFatal(err)
+	body, err := ioutil.ReadAll(resp.Body)
+	log.ErrFatal(err)
+	resp.Body.Close()
+	assert.Contains(t, string(body),
+		`cothority_measure{host="conode-1:2000",name="round"} 10`)
+
+	NewSingleMeasure("round", 20).Record()
+	time.Sleep(100 * time.Millisecond)
+	b := new(bytes.Buffer)
+	log.ErrFatal(mon.WriteCSV(b))
+	assert.Contains(t, b.String(), fmt.Sprintf(
+		"conode-1:2000,%d,round,10.000000\nconode-1:2000,%d,round,20.000000\n",
+		first.Round, first.Round+1))
+
+	EndAndCleanup()
+	time.Sleep(100 * time.Millisecond)
+	_, err = client.Get(url)
+	assert.NotNil(t, err, "metrics should stop with the monitor")
+}
@@ -4,7 +4,8 @@
 // measures). The Monitor receives them and updates a Stats struct. This Stats
 // struct can hold many different kinds of Measurements (the measure of a
 // specific action such as "round time" or "verify time" etc). These
-// measurements contain Values which compute the actual min/max/dev/avg values.
+// measurements contain Values which compute the actual min/max/dev/avg values
+// and the percentiles given in Percentiles.
 //
 // The Proxy allows to relay Measure from
 // clients to the listening Monitor. A starter feature is also the DataFilter
@@ -60,6 +61,13 @@ type Monitor struct {
 	done chan string
 
 	SinkPort int
+	// MetricsPort, if not 0, is the port where the measures are served
+	// in the Prometheus text-format under MetricsPath.
+	MetricsPort int
+
+	// metrics of the received measures, per host
+	metrics         *Metrics
+	metricsListener net.Listener
 }
 
 // NewMonitor returns a new monitor given the stats
@@ -72,6 +80,7 @@ func NewMonitor(stats *Stats) *Monitor {
 		measures:     make(chan *SingleMeasure),
 		done:         make(chan string),
 		listenerLock: new(sync.Mutex),
+		metrics:      NewMetrics(),
 	}
 }
 
@@ -87,6 +96,15 @@ func (m *Monitor) Listen() error {
 	m.listener = ln
 	m.listenerLock.Unlock()
 	log.Lvl2("Monitor listening for stats on", Sink, ":", m.SinkPort)
+	if m.MetricsPort != 0 {
+		if err := m.listenMetrics(); err != nil {
+			m.listenerLock.Lock()
+			ln.Close()
+			m.listener = nil
+			m.listenerLock.Unlock()
+			return err
+		}
+	}
 	finished := false
 	go func() {
 		for {
@@ -128,6 +146,7 @@ func (m *Monitor) Listen() error {
 						err)
 				}
 				m.listener = nil
+				m.stopMetrics()
 				finished = true
 				m.listenerLock.Unlock()
 			}
@@ -149,6 +168,7 @@ func (m *Monitor) Stop() {
 			log.Error("Couldn't close listener:", err)
 		}
 	}
+	m.stopMetrics()
 	m.listenerLock.Unlock()
 	m.mutexConn.Lock()
 	for _, c := range m.conns {
@@ -188,6 +208,10 @@ func (m *Monitor) handleConnection(conn net.Conn) {
 			log.Lvl3("Finishing monitor")
 			m.done <- conn.RemoteAddr().String()
 		default:
+			if measure.Host == "" {
+				measure.Host = hostOf(conn)
+			}
+			m.metrics.Update(measure)
 			m.measures <- measure
 		}
 	}
@@ -209,3 +233,8 @@ func (m *Monitor) Stats() *Stats {
 	m.mutexStats.Unlock()
 	return s
 }
+
+// Metrics returns the measures received so far, per host.
+func (m *Monitor) Metrics() *Metrics {
+	return m.metrics
+}
@@ -294,6 +294,11 @@ func (s *Stats) readRunConfig(rc map[string]string, defaults ...string) {
 	s.filter = NewDataFilter(rc)
 }
 
+// Percentiles are the percentiles written for every Value, additionally to
+// min/max/avg/sum/dev. They are computed using the nearest-rank method over
+// all stored values.
+var Percentiles = []float64{50, 95, 99}
+
 // Value is used to compute the statistics
 // it reprensent the time to an action (setup, shamir round, coll round etc)
 // use it to compute streaming mean + dev
@@ -308,6 +313,8 @@ type Value struct {
 	oldS float64
 	newS float64
 	dev  float64
+	// percentiles as given by the global Percentiles
+	percentiles []float64
 
 	// Store where are kept the values
 	store []float64
@@ -357,6 +364,25 @@ func (t *Value) Collect() {
 		t.dev = math.Sqrt(t.newS / float64(t.n-1))
 		t.sum += newTime
 	}
+	t.percentiles = make([]float64, len(Percentiles))
+	for i, p := range Percentiles {
+		t.percentiles[i] = t.Percentile(p)
+	}
+}
+
+// Percentile returns the value below which perc percent of the stored
+// values fall, using the nearest-rank method. It returns 0 if no values are
+// stored.
+func (t *Value) Percentile(perc float64) float64 {
+	if len(t.store) == 0 {
+		return 0
+	}
+	v, err := stats.PercentileNearestRank(t.store, perc)
+	if err != nil {
+		log.Lvl2("Monitor: Error computing percentile", perc, "of", t.name, ":", err)
+		return 0
+	}
+	return v
 }
 
 // Filter outs its Values
@@ -414,10 +440,22 @@ func (t *Value) Dev() float64 {
 
 // HeaderFields returns the first line of the CSV-file
 func (t *Value) HeaderFields() []string {
-	return []string{t.name + "_min", t.name + "_max", t.name + "_avg", t.name + "_sum", t.name + "_dev"}
+	fields := []string{t.name + "_min", t.name + "_max", t.name + "_avg", t.name + "_sum", t.name + "_dev"}
+	for _, p := range Percentiles {
+		fields = append(fields, t.name+"_p"+strconv.FormatFloat(p, 'f', -1, 64))
+	}
+	return fields
 }
 
 // Values returns the string representation of a Value
 func (t *Value) Values() []string {
-	return []string{fmt.Sprintf("%f", t.Min()), fmt.Sprintf("%f", t.Max()), fmt.Sprintf("%f", t.Avg()), fmt.Sprintf("%f", t.Sum()), fmt.Sprintf("%f", t.Dev())}
+	values := []string{fmt.Sprintf("%f", t.Min()), fmt.Sprintf("%f", t.Max()), fmt.Sprintf("%f", t.Avg()), fmt.Sprintf("%f", t.Sum()), fmt.Sprintf("%f", t.Dev())}
+	for i := range Percentiles {
+		var p float64
+		if i < len(t.percentiles) {
+			p = t.percentiles[i]
+		}
+		values = append(values, fmt.Sprintf("%f", p))
+	}
+	return values
 }
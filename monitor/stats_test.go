@@ -96,6 +96,28 @@ func TestValues(t *testing.T) {
 	}
 }
 
+func TestValuesPercentile(t *testing.T) {
+	v := NewValue("test")
+	for i := 100; i > 0; i-- {
+		v.Store(float64(i))
+	}
+	v.Collect()
+	if v.Percentile(50) != 50 || v.Percentile(95) != 95 || v.Percentile(99) != 99 {
+		t.Fatal("Wrong percentiles:", v.Percentile(50), v.Percentile(95), v.Percentile(99))
+	}
+	header := v.HeaderFields()
+	values := v.Values()
+	if len(header) != len(values) {
+		t.Fatal("Header and values don't match")
+	}
+	if header[len(header)-1] != "test_p99" || values[len(values)-1] != "99.000000" {
+		t.Fatal("Percentiles not written:", header, values)
+	}
+	if NewValue("empty").Percentile(50) != 0 {
+		t.Fatal("Empty value should have 0 as percentile")
+	}
+}
+
 func TestStatsAverage(t *testing.T) {
 	m := make(map[string]string)
 	m["servers"] = "1"
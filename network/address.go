@@ -27,6 +27,8 @@ const (
 	PURB = "purb"
 	// Local is a channel based connection type.
 	Local = "local"
+	// Unix is a Unix domain socket connection for hosts on the same machine.
+	Unix = "unix"
 	// InvalidConnType is an invalid connection type.
 	InvalidConnType = "wrong"
 )
@@ -39,7 +41,7 @@ const typeAddressSep = "://"
 // it returns InvalidConnType.
 func connType(t string) ConnType {
 	ct := ConnType(t)
-	types := []ConnType{PlainTCP, TLS, PURB, Local}
+	types := []ConnType{PlainTCP, TLS, PURB, Local, Unix}
 	for _, t := range types {
 		if t == ct {
 			return ct
@@ -60,7 +62,8 @@ func (a Address) ConnType() ConnType {
 }
 
 // NetworkAddress returns the network address part of the address, which is
-// the IP address and the port joined by a colon.
+// the IP address and the port joined by a colon, or the path of the socket
+// for a Unix address.
 // It returns an empty string if the a.Valid() returns false.
 func (a Address) NetworkAddress() string {
 	if !a.Valid() {
@@ -77,13 +80,18 @@ func (a Address) NetworkAddress() string {
 // The IP address is validated by net.ParseIP & the port must be included in the
 // range [0;65536].
 // Ex. tls:192.168.1.10:5678
+// For a Unix address, the NetworkAddress is the path of the socket.
+// Ex. unix:///tmp/conode.sock
 func (a Address) Valid() bool {
 	vals := strings.Split(string(a), typeAddressSep)
 	if len(vals) != 2 {
 		return false
 	}
-	if connType(vals[0]) == InvalidConnType {
+	switch connType(vals[0]) {
+	case InvalidConnType:
 		return false
+	case Unix:
+		return vals[1] != ""
 	}
 
 	ip, port, e := net.SplitHostPort(vals[1])
@@ -146,6 +154,9 @@ func (a Address) Port() string {
 // Specifically it checks if it is a private address by checking
 // 192.168.**,10.***,127.***,172.16-31.**,169.254.**
 func (a Address) Public() bool {
+	if a.ConnType() == Unix {
+		return false
+	}
 	private, err := regexp.MatchString("(^127\\.)|(^10\\.)|"+
 		"(^172\\.1[6-9]\\.)|(^172\\.2[0-9]\\.)|"+
 		"(^172\\.3[0-1]\\.)|(^192\\.168\\.)|(^169\\.254)", a.NetworkAddress())
@@ -0,0 +1,128 @@
+package network
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+
+	"github.com/satori/go.uuid"
+)
+
+// ChunkPacketTypeID is the type of a packet holding a part of a bigger
+// packet. Big packets are split in chunks by the sending connection if
+// turned on with SetChunkSize. The receiving connection reassembles them
+// transparently, so every host can receive chunks.
+var ChunkPacketTypeID = PacketTypeID(uuid.NewV5(uuid.NamespaceURL,
+	NamespaceBodyType+"chunk"))
+
+// DefaultChunkSize is a reasonable size for SetChunkSize.
+const DefaultChunkSize = 1024 * 1024
+
+// chunkSize is accessed atomically. 0 means no chunks.
+var chunkSize int64
+
+// chunkHeaderSize is the size of the header of a chunk: the
+// ChunkPacketTypeID, the ID of the split packet, the index of the chunk
+// and the number of chunks.
+const chunkHeaderSize = 16 + 16 + 4 + 4
+
+// SetChunkSize turns on splitting packets bigger than size into chunks of
+// at most size bytes, which are sent one after the other. A size of 0 turns
+// it off, which is the default. Compression set with Router.SetCompression
+// is applied before splitting the packet.
+func SetChunkSize(size int) {
+	atomic.StoreInt64(&chunkSize, int64(size))
+}
+
+// ChunkSize returns the size set with SetChunkSize.
+func ChunkSize() int {
+	return int(atomic.LoadInt64(&chunkSize))
+}
+
+// splitPacket returns b split in chunks if SetChunkSize is on and b is
+// too big, else it returns b as the only element.
+func splitPacket(b []byte) [][]byte {
+	size := ChunkSize()
+	if size <= 0 || len(b) <= size {
+		return [][]byte{b}
+	}
+	id := uuid.NewV4()
+	total := (len(b) + size - 1) / size
+	chunks := make([][]byte, 0, total)
+	for i := 0; i < total; i++ {
+		end := (i + 1) * size
+		if end > len(b) {
+			end = len(b)
+		}
+		var buf bytes.Buffer
+		buf.Grow(chunkHeaderSize + end - i*size)
+		buf.Write(ChunkPacketTypeID[:])
+		buf.Write(id.Bytes())
+		binary.Write(&buf, globalOrder, uint32(i))
+		binary.Write(&buf, globalOrder, uint32(total))
+		buf.Write(b[i*size : end])
+		chunks = append(chunks, buf.Bytes())
+	}
+	return chunks
+}
+
+// reassembler collects the chunks received by a connection until a packet
+// is complete.
+type reassembler struct {
+	partial map[uuid.UUID]*partialPacket
+	sync.Mutex
+}
+
+// partialPacket holds the chunks received so far.
+type partialPacket struct {
+	next  uint32
+	total uint32
+	buf   bytes.Buffer
+}
+
+// add returns the packet b if it is not a chunk. If b is a chunk, add
+// returns the reassembled packet if b is the last chunk. The returned bool
+// is false as long as more chunks are needed. The chunks of a packet have to
+// be added in order.
+func (r *reassembler) add(b []byte) ([]byte, bool, error) {
+	if len(b) < chunkHeaderSize ||
+		!bytes.Equal(b[:16], ChunkPacketTypeID[:]) {
+		return b, true, nil
+	}
+	var id uuid.UUID
+	copy(id[:], b[16:32])
+	index := globalOrder.Uint32(b[32:36])
+	total := globalOrder.Uint32(b[36:40])
+
+	r.Lock()
+	defer r.Unlock()
+	if r.partial == nil {
+		r.partial = make(map[uuid.UUID]*partialPacket)
+	}
+	p, ok := r.partial[id]
+	if !ok {
+		if index != 0 {
+			return nil, false, fmt.Errorf("Got chunk %d of unknown packet %s", index, id)
+		}
+		p = &partialPacket{total: total}
+		r.partial[id] = p
+	}
+	if index != p.next || total != p.total {
+		delete(r.partial, id)
+		return nil, false, errors.New("Got chunk out of order for packet " + id.String())
+	}
+	if err := checkSize(p.buf.Len() + len(b) - chunkHeaderSize); err != nil {
+		delete(r.partial, id)
+		return nil, false, err
+	}
+	p.buf.Write(b[chunkHeaderSize:])
+	p.next++
+	if p.next < p.total {
+		return nil, false, nil
+	}
+	delete(r.partial, id)
+	return p.buf.Bytes(), true, nil
+}
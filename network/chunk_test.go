@@ -0,0 +1,101 @@
+package network
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/dedis/crypto/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitPacket(t *testing.T) {
+	defer SetChunkSize(0)
+	b := random.Bytes(1000, random.Stream)
+
+	SetChunkSize(0)
+	assert.Equal(t, [][]byte{b}, splitPacket(b))
+	SetChunkSize(1000)
+	assert.Equal(t, [][]byte{b}, splitPacket(b))
+
+	SetChunkSize(300)
+	chunks := splitPacket(b)
+	require.Equal(t, 4, len(chunks))
+	var r reassembler
+	for i, c := range chunks {
+		p, complete, err := r.add(c)
+		require.Nil(t, err)
+		assert.Equal(t, i == len(chunks)-1, complete)
+		if complete {
+			assert.True(t, bytes.Equal(b, p))
+		}
+	}
+	assert.Equal(t, 0, len(r.partial))
+
+	// Chunks out of order
+	chunks = splitPacket(b)
+	_, _, err := r.add(chunks[1])
+	assert.NotNil(t, err)
+	_, _, err = r.add(chunks[0])
+	require.Nil(t, err)
+	_, _, err = r.add(chunks[2])
+	assert.NotNil(t, err)
+	assert.Equal(t, 0, len(r.partial))
+}
+
+func TestLocalConnChunks(t *testing.T) {
+	defer SetChunkSize(0)
+	SetChunkSize(1024)
+	addr := NewLocalAddress("127.0.0.1:2000")
+	ln, err := NewLocalListener(addr)
+	require.Nil(t, err)
+	received := make(chan Packet)
+	go ln.Listen(func(c Conn) {
+		for i := 0; i < 2; i++ {
+			p, err := c.Receive()
+			require.Nil(t, err)
+			received <- p
+		}
+	})
+	defer ln.Stop()
+
+	c, err := NewLocalConn(NewLocalAddress("127.0.0.1:2001"), addr)
+	require.Nil(t, err)
+	defer c.Close()
+	big := random.Bytes(10*1024+1, random.Stream)
+	require.Nil(t, c.Send(&BigMsg{Array: big}))
+	require.Nil(t, c.Send(&BigMsg{Array: []byte{1}}))
+	p := <-received
+	assert.Equal(t, big, p.Msg.(BigMsg).Array)
+	p = <-received
+	assert.Equal(t, []byte{1}, p.Msg.(BigMsg).Array)
+}
+
+func TestTCPConnChunks(t *testing.T) {
+	defer SetChunkSize(0)
+	SetChunkSize(1024)
+	addr := NewTCPAddress("127.0.0.1:2130")
+	ln, err := NewTCPListener(addr)
+	require.Nil(t, err)
+	received := make(chan Packet)
+	go ln.Listen(func(c Conn) {
+		for i := 0; i < 2; i++ {
+			p, err := c.Receive()
+			require.Nil(t, err)
+			received <- p
+		}
+		c.Close()
+	})
+	defer ln.Stop()
+
+	c, err := NewTCPConn(addr)
+	require.Nil(t, err)
+	defer c.Close()
+	big := random.Bytes(10*1024+1, random.Stream)
+	require.Nil(t, c.Send(&BigMsg{Array: big}))
+	require.Nil(t, c.Send(&BigMsg{Array: []byte{1}}))
+	p := <-received
+	assert.Equal(t, big, p.Msg.(BigMsg).Array)
+	p = <-received
+	assert.Equal(t, []byte{1}, p.Msg.(BigMsg).Array)
+}
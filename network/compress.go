@@ -0,0 +1,110 @@
+package network
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"io"
+	"io/ioutil"
+
+	"github.com/satori/go.uuid"
+)
+
+// CompressedPacketTypeID is the type of a packet holding another packet
+// compressed with gzip. It is handled transparently by
+// UnmarshalRegisteredType and UnmarshalRegistered, so every host of this
+// version can receive compressed packets, while only Routers and connections
+// with compression turned on send them.
+var CompressedPacketTypeID = PacketTypeID(uuid.NewV5(uuid.NamespaceURL,
+	NamespaceBodyType+"compressed"))
+
+// DefaultCompressionThreshold is a reasonable threshold for SetCompression:
+// smaller packets, like the control messages of most protocols, don't gain
+// anything from being compressed.
+const DefaultCompressionThreshold = 16 * 1024
+
+// compressor is implemented by the connections that can compress the packets
+// they send.
+type compressor interface {
+	SetCompression(threshold int)
+}
+
+// SetCompression turns on the compression of packets that are at least
+// threshold bytes big, on all connections of the Router using TCP, TLS or
+// Unix. A threshold of 0 turns compression off, which is the default.
+// Compressed packets are only sent if they are smaller than the original
+// packets. As hosts of an older version can't read compressed packets, it
+// should only be turned on if all remote hosts understand them.
+func (r *Router) SetCompression(threshold int) {
+	r.connsMut.Lock()
+	defer r.connsMut.Unlock()
+	r.compression = threshold
+	for c := range r.outgoing {
+		if cc, ok := c.(compressor); ok {
+			cc.SetCompression(threshold)
+		}
+	}
+}
+
+// Compression returns the threshold set with SetCompression.
+func (r *Router) Compression() int {
+	r.connsMut.Lock()
+	defer r.connsMut.Unlock()
+	return r.compression
+}
+
+// SetCompression turns on the compression of the packets sent on this
+// connection, like Router.SetCompression.
+func (c *TCPConn) SetCompression(threshold int) {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+	c.compression = threshold
+}
+
+// compressPacket returns b compressed if b is at least threshold bytes big
+// and the result is smaller. Else, or if threshold is 0, it returns b.
+func compressPacket(b []byte, threshold int) []byte {
+	if threshold <= 0 || len(b) < threshold {
+		return b
+	}
+	var buf bytes.Buffer
+	if err := binary.Write(&buf, globalOrder, CompressedPacketTypeID); err != nil {
+		return b
+	}
+	w := gzip.NewWriter(&buf)
+	if _, err := w.Write(b); err != nil {
+		logger().Lvl2("Couldn't compress packet:", err)
+		return b
+	}
+	if err := w.Close(); err != nil {
+		logger().Lvl2("Couldn't compress packet:", err)
+		return b
+	}
+	if buf.Len() >= len(b) {
+		return b
+	}
+	return buf.Bytes()
+}
+
+// decompressPacket returns the packet compressed in b, which doesn't include
+// the CompressedPacketTypeID anymore.
+func decompressPacket(b []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	max := MaxMessageSize()
+	if max <= 0 {
+		return ioutil.ReadAll(r)
+	}
+	// Read one byte more to detect packets bigger than max.
+	b, err = ioutil.ReadAll(io.LimitReader(r, int64(max)+1))
+	if err != nil {
+		return nil, err
+	}
+	if err := checkSize(len(b)); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
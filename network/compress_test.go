@@ -0,0 +1,136 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/dedis/crypto/abstract"
+	"github.com/dedis/crypto/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type cipherVectorMsg struct {
+	K []abstract.Point
+	C []abstract.Point
+}
+
+var _ = RegisterPacketType(cipherVectorMsg{})
+
+func TestCompressPacket(t *testing.T) {
+	msg := &BigMsg{Array: make([]byte, 2*DefaultCompressionThreshold)}
+	b, err := MarshalRegisteredType(msg)
+	require.Nil(t, err)
+
+	assert.Equal(t, b, compressPacket(b, 0))
+
+	c := compressPacket(b, DefaultCompressionThreshold)
+	assert.True(t, len(c) < len(b))
+	_, body, err := UnmarshalRegistered(c)
+	require.Nil(t, err)
+	assert.Equal(t, msg, body)
+	_, body, err = UnmarshalRegisteredType(c, DefaultConstructors(Suite))
+	require.Nil(t, err)
+	assert.Equal(t, *msg, body)
+
+	// Small packets are not compressed
+	small, err := MarshalRegisteredType(&BigMsg{Array: make([]byte, 10)})
+	require.Nil(t, err)
+	assert.Equal(t, small, compressPacket(small, DefaultCompressionThreshold))
+
+	// Packets that don't get smaller are not compressed
+	noise := &BigMsg{Array: random.Bytes(2*DefaultCompressionThreshold,
+		random.Stream)}
+	b, err = MarshalRegisteredType(noise)
+	require.Nil(t, err)
+	assert.Equal(t, b, compressPacket(b, DefaultCompressionThreshold))
+}
+
+func TestTCPConnCompression(t *testing.T) {
+	addr := NewTCPAddress("127.0.0.1:2120")
+	ln, err := NewTCPListener(addr)
+	require.Nil(t, err)
+	received := make(chan Packet)
+	go ln.Listen(func(c Conn) {
+		p, err := c.Receive()
+		require.Nil(t, err)
+		received <- p
+		c.Close()
+	})
+	defer ln.Stop()
+
+	c, err := NewTCPConn(addr)
+	require.Nil(t, err)
+	defer c.Close()
+	c.SetCompression(DefaultCompressionThreshold)
+	size := 4 * DefaultCompressionThreshold
+	require.Nil(t, c.Send(&BigMsg{Array: make([]byte, size)}))
+	p := <-received
+	assert.Equal(t, size, len(p.Msg.(BigMsg).Array))
+	assert.True(t, c.Tx() < uint64(size))
+}
+
+func TestRouterCompression(t *testing.T) {
+	r1, err := NewTestRouterTCP(2121)
+	require.Nil(t, err)
+	r2, err := NewTestRouterTCP(2122)
+	require.Nil(t, err)
+	go r1.Start()
+	go r2.Start()
+	defer r1.Stop()
+	defer r2.Stop()
+	received := make(chan int, 2)
+	r2.RegisterProcessorFunc(TypeFromData(BigMsg{}), func(p *Packet) {
+		received <- len(p.Msg.(BigMsg).Array)
+	})
+
+	// Compression is only used by the router that turned it on.
+	size := 4 * DefaultCompressionThreshold
+	r1.SetCompression(DefaultCompressionThreshold)
+	assert.Equal(t, DefaultCompressionThreshold, r1.Compression())
+	assert.Equal(t, 0, r2.Compression())
+	require.Nil(t, r1.Send(r2.ServerIdentity, &BigMsg{Array: make([]byte, size)}))
+	assert.Equal(t, size, <-received)
+	assert.True(t, r1.Tx() < uint64(size))
+
+	// Turning it off applies to the open connections.
+	r1.SetCompression(0)
+	tx := r1.Tx()
+	require.Nil(t, r1.Send(r2.ServerIdentity, &BigMsg{Array: make([]byte, size)}))
+	assert.Equal(t, size, <-received)
+	assert.True(t, r1.Tx()-tx > uint64(size))
+}
+
+// BenchmarkCompress* measure the time spent to marshal, compress and
+// unmarshal a ciphervector of 1000 ElGamal-pairs as sent by the aggregation
+// protocols.
+func BenchmarkCompressOff(b *testing.B) {
+	benchmarkCompress(b, 0)
+}
+
+func BenchmarkCompressOn(b *testing.B) {
+	benchmarkCompress(b, DefaultCompressionThreshold)
+}
+
+func benchmarkCompress(b *testing.B, threshold int) {
+	msg := &cipherVectorMsg{}
+	for i := 0; i < 1000; i++ {
+		k, _ := Suite.Point().Pick(nil, random.Stream)
+		c, _ := Suite.Point().Pick(nil, random.Stream)
+		msg.K = append(msg.K, k)
+		msg.C = append(msg.C, c)
+	}
+	b.ResetTimer()
+	var size int
+	for i := 0; i < b.N; i++ {
+		buf, err := MarshalRegisteredType(msg)
+		if err != nil {
+			b.Fatal(err)
+		}
+		buf = compressPacket(buf, threshold)
+		size = len(buf)
+		if _, _, err := UnmarshalRegistered(buf); err != nil {
+			b.Fatal(err)
+		}
+	}
+	b.SetBytes(int64(size))
+}
@@ -95,7 +95,7 @@ func TypeToPacketTypeID(msg Body) PacketTypeID {
 	}
 	url := NamespaceBodyType + val.Type().String()
 	u := uuid.NewV5(uuid.NamespaceURL, url)
-	log.Lvl5("Reflecting", reflect.TypeOf(msg), "to", u)
+	logger().Lvl5("Reflecting", reflect.TypeOf(msg), "to", u)
 	return PacketTypeID(u)
 }
 
@@ -181,7 +181,8 @@ var marshalLock sync.Mutex
 
 // MarshalRegisteredType will marshal a struct with its respective type into a
 // slice of bytes. That slice of bytes can be then decoded in
-// UnmarshalRegisteredType.
+// UnmarshalRegisteredType. If a key is set with SetMACKey, the MAC of the
+// packet is appended.
 func MarshalRegisteredType(data Body) ([]byte, error) {
 	marshalLock.Lock()
 	defer marshalLock.Unlock()
@@ -196,25 +197,39 @@ func MarshalRegisteredType(data Body) ([]byte, error) {
 	var buf []byte
 	var err error
 	if buf, err = protobuf.Encode(data); err != nil {
-		log.Errorf("Error for protobuf encoding: %s %+v", err, data)
+		logger().Errorf("Error for protobuf encoding: %s %+v", err, data)
 		if log.DebugVisible() > 0 {
-			log.Error(log.Stack())
+			logger().Error(log.Stack())
 		}
 		return nil, err
 	}
 	_, err = b.Write(buf)
-	return b.Bytes(), err
+	return appendMAC(b.Bytes()), err
 }
 
 // UnmarshalRegisteredType returns the type, the data and an error trying to
 // decode a message from a buffer.
 // The type must be registered to the network library in order to be decodable.
+// Packets compressed by a connection are decompressed first. If a key is set
+// with SetMACKey, packets with a wrong MAC are refused.
 func UnmarshalRegisteredType(buf []byte, constructors protobuf.Constructors) (PacketTypeID, Body, error) {
 	b := bytes.NewBuffer(buf)
 	var tID PacketTypeID
 	if err := binary.Read(b, globalOrder, &tID); err != nil {
 		return ErrorType, nil, err
 	}
+	if tID == CompressedPacketTypeID {
+		inner, err := decompressPacket(b.Bytes())
+		if err != nil {
+			return ErrorType, nil, err
+		}
+		return UnmarshalRegisteredType(inner, constructors)
+	}
+	data, err := checkMAC(buf)
+	if err != nil {
+		return ErrorType, nil, err
+	}
+	b = bytes.NewBuffer(data[binary.Size(tID):])
 	typ, ok := registry.get(tID)
 	if !ok {
 		return ErrorType, nil, fmt.Errorf("Type %s not registered.",
@@ -236,6 +251,18 @@ func UnmarshalRegistered(buf []byte) (PacketTypeID, Body, error) {
 	if err := binary.Read(b, globalOrder, &tID); err != nil {
 		return ErrorType, nil, err
 	}
+	if tID == CompressedPacketTypeID {
+		inner, err := decompressPacket(b.Bytes())
+		if err != nil {
+			return ErrorType, nil, err
+		}
+		return UnmarshalRegistered(inner)
+	}
+	data, err := checkMAC(buf)
+	if err != nil {
+		return ErrorType, nil, err
+	}
+	b = bytes.NewBuffer(data[binary.Size(tID):])
 	typ, ok := registry.get(tID)
 	if !ok {
 		return ErrorType, nil, fmt.Errorf("Type %s not registered.",
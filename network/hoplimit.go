@@ -0,0 +1,71 @@
+package network
+
+import (
+	"errors"
+	"reflect"
+)
+
+// ErrHopLimit is returned when sending a message that already used up all
+// its hops.
+var ErrHopLimit = errors.New("Hop limit reached - message dropped")
+
+// HopLimit can be embedded into messages that are forwarded from host to
+// host, e.g. around a ring. Every time such a message is sent, Hops is
+// decremented, and once it reaches 0 the message is dropped instead of
+// being sent. This protects against messages looping forever due to a
+// routing error.
+// The creator of the message has to set Hops, a HopLimit of 0 is never sent.
+// The HopLimit has to be embedded as a value and not as a pointer, so that
+// Hop can copy it. Messages can be sent by value or by pointer, the message
+// of the caller is never changed.
+type HopLimit struct {
+	Hops int
+}
+
+// HopLimited is implemented by all messages embedding a HopLimit.
+type HopLimited interface {
+	// Hop uses up one hop and returns ErrHopLimit if there are no more hops
+	// left.
+	Hop() error
+}
+
+// Hop implements the HopLimited-interface.
+func (h *HopLimit) Hop() error {
+	if h.Hops <= 0 {
+		return ErrHopLimit
+	}
+	h.Hops--
+	return nil
+}
+
+// hopLimitedType is used to check if a message passed by value embeds a
+// HopLimit.
+var hopLimitedType = reflect.TypeOf((*HopLimited)(nil)).Elem()
+
+// Hop returns a copy of msg with one hop less if it embeds a HopLimit, or
+// ErrHopLimit if the message has to be dropped. The copy is a pointer if msg
+// is a pointer, and a value else. msg itself is not modified, so a caller
+// can reuse it. Messages without HopLimit are returned as they are.
+func Hop(msg Body) (Body, error) {
+	val := reflect.ValueOf(msg)
+	isPtr := val.Kind() == reflect.Ptr
+	if isPtr {
+		if val.IsNil() {
+			return msg, nil
+		}
+		val = val.Elem()
+	}
+	if !reflect.PtrTo(val.Type()).Implements(hopLimitedType) {
+		return msg, nil
+	}
+	cp := reflect.New(val.Type())
+	cp.Elem().Set(val)
+	if err := cp.Interface().(HopLimited).Hop(); err != nil {
+		logger().Lvlf2("Dropping message %T: %s", msg, err)
+		return nil, err
+	}
+	if isPtr {
+		return cp.Interface(), nil
+	}
+	return cp.Elem().Interface(), nil
+}
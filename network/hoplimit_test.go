@@ -0,0 +1,97 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hopMessage struct {
+	HopLimit
+	Received int
+}
+
+var hopMessageType = RegisterPacketType(hopMessage{})
+
+// loopProc forwards every hopMessage to the other router, creating a loop.
+type loopProc struct {
+	r     *Router
+	other *ServerIdentity
+	recv  chan int
+	done  chan error
+}
+
+func (lp *loopProc) Process(p *Packet) {
+	msg := p.Msg.(hopMessage)
+	msg.Received++
+	lp.recv <- msg.Received
+	if err := lp.r.Send(lp.other, &msg); err != nil {
+		lp.done <- err
+	}
+}
+
+func TestHopLimit(t *testing.T) {
+	h := &HopLimit{Hops: 2}
+	assert.Nil(t, h.Hop())
+	assert.Nil(t, h.Hop())
+	assert.Equal(t, ErrHopLimit, h.Hop())
+
+	_, err := Hop(&hopMessage{})
+	assert.Equal(t, ErrHopLimit, err)
+	_, err = Hop(hopMessage{})
+	assert.Equal(t, ErrHopLimit, err)
+	sm := &SimpleMessage{}
+	msg, err := Hop(sm)
+	assert.Nil(t, err)
+	assert.Equal(t, sm, msg)
+
+	// the message of the caller is not modified
+	ptr := &hopMessage{HopLimit{2}, 0}
+	msg, err = Hop(ptr)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, msg.(*hopMessage).Hops)
+	assert.Equal(t, 2, ptr.Hops)
+	val := hopMessage{HopLimit{2}, 0}
+	msg, err = Hop(val)
+	assert.Nil(t, err)
+	assert.Equal(t, 1, msg.(hopMessage).Hops)
+	assert.Equal(t, 2, val.Hops)
+}
+
+func TestRouterHopLimitLoop(t *testing.T) {
+	h1, err := NewTestRouterLocal(2090)
+	require.Nil(t, err)
+	h2, err := NewTestRouterLocal(2091)
+	require.Nil(t, err)
+	go h1.Start()
+	go h2.Start()
+	defer func() {
+		assert.Nil(t, h1.Stop())
+		assert.Nil(t, h2.Stop())
+	}()
+
+	recv := make(chan int, 100)
+	done := make(chan error, 2)
+	h1.RegisterProcessor(&loopProc{h1, h2.ServerIdentity, recv, done},
+		hopMessageType)
+	h2.RegisterProcessor(&loopProc{h2, h1.ServerIdentity, recv, done},
+		hopMessageType)
+
+	// messages sent by value are limited, too
+	assert.Equal(t, ErrHopLimit, h1.Send(h2.ServerIdentity, hopMessage{}))
+
+	hops := 10
+	require.Nil(t, h1.Send(h2.ServerIdentity, &hopMessage{HopLimit{hops}, 0}))
+	select {
+	case err := <-done:
+		assert.Equal(t, ErrHopLimit, err)
+	case <-time.After(2 * time.Second):
+		t.Fatal("Message didn't get dropped")
+	}
+	assert.Equal(t, hops, len(recv))
+	// Make sure the message isn't circulating anymore.
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, hops, len(recv))
+}
@@ -178,6 +178,8 @@ type LocalConn struct {
 	counterSafe
 	// the localManager responsible for that connection.
 	manager *LocalManager
+	// chunks holds the chunks of a big packet until it is complete
+	chunks reassembler
 }
 
 // newLocalConn initializes the fields of a LocalConn but does'nt
@@ -222,19 +224,34 @@ func (lc *LocalConn) Send(msg Body) error {
 	if err != nil {
 		return err
 	}
+	if err := checkSize(len(buff)); err != nil {
+		return err
+	}
 	lc.updateTx(uint64(len(buff)))
-	return lc.manager.send(lc.remote, buff)
+	for _, chunk := range splitPacket(buff) {
+		if err := lc.manager.send(lc.remote, chunk); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 // Receive takes a context (that is not used) and waits for a packet to
 // be ready. It returns the received packet.
 // In case of an error the packet is nil and the error is returned.
 func (lc *LocalConn) Receive() (Packet, error) {
-	buff, err := lc.pop()
-	if err != nil {
-		return EmptyApplicationPacket, err
+	var buff []byte
+	for complete := false; !complete; {
+		b, err := lc.pop()
+		if err != nil {
+			return EmptyApplicationPacket, err
+		}
+		lc.updateRx(uint64(len(b)))
+		buff, complete, err = lc.chunks.add(b)
+		if err != nil {
+			return EmptyApplicationPacket, err
+		}
 	}
-	lc.updateRx(uint64(len(buff)))
 
 	id, body, err := UnmarshalRegisteredType(buff, DefaultConstructors(Suite))
 	return Packet{
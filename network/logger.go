@@ -0,0 +1,32 @@
+package network
+
+import (
+	"sync/atomic"
+
+	"github.com/dedis/cothority/log"
+)
+
+// netLogger holds the *log.Logger used for the messages of the network
+// library.
+var netLogger atomic.Value
+
+func init() {
+	SetLogger(nil)
+}
+
+// SetLogger sets the Logger used for all messages of the network library.
+// This allows to set the debug-level of the network library independently
+// of the application using log.Logger.WithDebugVisible, or to mark its
+// messages using log.Logger.WithFields. A nil Logger resets to the global
+// log-functions.
+func SetLogger(l *log.Logger) {
+	if l == nil {
+		l = log.WithFields(nil)
+	}
+	netLogger.Store(l)
+}
+
+// logger returns the Logger set with SetLogger.
+func logger() *log.Logger {
+	return netLogger.Load().(*log.Logger)
+}
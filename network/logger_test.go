@@ -0,0 +1,33 @@
+package network
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/dedis/cothority/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetLogger(t *testing.T) {
+	oldOut, oldErr := log.Output()
+	defer log.SetOutput(oldOut, oldErr)
+	defer SetLogger(nil)
+	var out bytes.Buffer
+	log.SetOutput(&out, &out)
+
+	// the global debug-level doesn't show the message
+	Hop(&HopLimit{})
+	assert.Equal(t, "", out.String())
+
+	SetLogger(log.WithFields(map[string]interface{}{"layer": "network"}).
+		WithDebugVisible(2))
+	Hop(&HopLimit{})
+	assert.True(t, strings.Contains(out.String(), "layer=network Dropping message"),
+		out.String())
+
+	out.Reset()
+	SetLogger(nil)
+	Hop(&HopLimit{})
+	assert.Equal(t, "", out.String())
+}
@@ -0,0 +1,58 @@
+package network
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMAC(t *testing.T) {
+	defer SetMACKey(nil)
+	msg := &SimpleMessage{I: 42}
+	plain, err := MarshalRegisteredType(msg)
+	require.Nil(t, err)
+
+	SetMACKey([]byte("secret"))
+	buf, err := MarshalRegisteredType(msg)
+	require.Nil(t, err)
+	assert.Equal(t, len(plain)+MACSize, len(buf))
+	_, m, err := UnmarshalRegistered(buf)
+	require.Nil(t, err)
+	assert.Equal(t, 42, m.(*SimpleMessage).I)
+	_, m, err = UnmarshalRegisteredType(buf, DefaultConstructors(Suite))
+	require.Nil(t, err)
+	assert.Equal(t, 42, m.(SimpleMessage).I)
+
+	for _, i := range []int{0, len(plain) - 1, len(buf) - 1} {
+		buf[i] ^= 1
+		_, _, err = UnmarshalRegistered(buf)
+		assert.NotNil(t, err, "flipped byte %d", i)
+		_, _, err = UnmarshalRegisteredType(buf, DefaultConstructors(Suite))
+		assert.NotNil(t, err, "flipped byte %d", i)
+		buf[i] ^= 1
+	}
+	_, _, err = UnmarshalRegistered(plain)
+	assert.NotNil(t, err)
+
+	SetMACKey([]byte("other secret"))
+	_, _, err = UnmarshalRegistered(buf)
+	assert.NotNil(t, err)
+
+	SetMACKey(nil)
+	assert.Nil(t, MACKey())
+	_, _, err = UnmarshalRegistered(plain)
+	assert.Nil(t, err)
+}
+
+func TestMACCompressed(t *testing.T) {
+	defer SetMACKey(nil)
+	SetMACKey([]byte("secret"))
+	buf, err := MarshalRegisteredType(&BigMsg{Array: make([]byte, 1000)})
+	require.Nil(t, err)
+	c := compressPacket(buf, 1)
+	require.True(t, len(c) < len(buf))
+	_, m, err := UnmarshalRegistered(c)
+	require.Nil(t, err)
+	assert.Equal(t, 1000, len(m.(*BigMsg).Array))
+}
@@ -0,0 +1,43 @@
+package network
+
+import (
+	"errors"
+	"sync/atomic"
+)
+
+// ErrMessageTooBig is returned when a packet bigger than MaxMessageSize is
+// sent or received. A connection receiving such a packet is closed.
+var ErrMessageTooBig = errors.New("Message bigger than MaxMessageSize")
+
+// DefaultMaxMessageSize is the default of MaxMessageSize. It is big enough
+// for the payloads of all protocols in the cothority.
+const DefaultMaxMessageSize = 100 * 1024 * 1024
+
+// maxMessageSize is accessed atomically. 0 means no limit.
+var maxMessageSize int64 = DefaultMaxMessageSize
+
+// SetMaxMessageSize sets the size in bytes of the biggest packet a
+// connection sends or receives. It protects against a peer claiming a huge
+// size in the header of a packet, which would otherwise be allocated before
+// reading the packet. The size applies to the packet before it is
+// compressed or split in chunks. A size of 0 removes the limit.
+func SetMaxMessageSize(size int) {
+	atomic.StoreInt64(&maxMessageSize, int64(size))
+}
+
+// MaxMessageSize returns the size set with SetMaxMessageSize.
+func MaxMessageSize() int {
+	return int(atomic.LoadInt64(&maxMessageSize))
+}
+
+// checkSize returns ErrMessageTooBig if size is bigger than
+// MaxMessageSize.
+func checkSize(size int) error {
+	max := MaxMessageSize()
+	if max > 0 && size > max {
+		logger().Lvlf2("Message of %d bytes is bigger than the maximum of %d bytes",
+			size, max)
+		return ErrMessageTooBig
+	}
+	return nil
+}
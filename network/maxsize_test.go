@@ -0,0 +1,72 @@
+package network
+
+import (
+	"encoding/binary"
+	"net"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaxMessageSizeReceive(t *testing.T) {
+	assert.Equal(t, DefaultMaxMessageSize, MaxMessageSize())
+	addr := NewTCPAddress("127.0.0.1:2144")
+	ln, err := NewTCPListener(addr)
+	require.Nil(t, err)
+	errs := make(chan error)
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	go ln.Listen(func(c Conn) {
+		_, err := c.Receive()
+		runtime.ReadMemStats(&after)
+		errs <- err
+	})
+	defer ln.Stop()
+
+	conn, err := net.Dial("tcp", addr.NetworkAddress())
+	require.Nil(t, err)
+	defer conn.Close()
+	require.Nil(t, binary.Write(conn, globalOrder, Size(2*1024*1024*1024)))
+	assert.Equal(t, ErrMessageTooBig, <-errs)
+	assert.True(t, after.TotalAlloc-before.TotalAlloc < 10*1024*1024,
+		"allocated the claimed size")
+	// the connection has been closed
+	_, err = conn.Read(make([]byte, 1))
+	assert.NotNil(t, err)
+}
+
+func TestMaxMessageSizeSend(t *testing.T) {
+	defer SetMaxMessageSize(DefaultMaxMessageSize)
+	SetMaxMessageSize(1024)
+	addr := NewLocalAddress("127.0.0.1:2000")
+	ln, err := NewLocalListener(addr)
+	require.Nil(t, err)
+	received := make(chan Packet)
+	go ln.Listen(func(c Conn) {
+		p, err := c.Receive()
+		require.Nil(t, err)
+		received <- p
+	})
+	defer ln.Stop()
+
+	c, err := NewLocalConn(NewLocalAddress("127.0.0.1:2001"), addr)
+	require.Nil(t, err)
+	defer c.Close()
+	assert.Equal(t, ErrMessageTooBig, c.Send(&BigMsg{Array: make([]byte, 2048)}))
+	require.Nil(t, c.Send(&BigMsg{Array: make([]byte, 512)}))
+	p := <-received
+	assert.Equal(t, 512, len(p.Msg.(BigMsg).Array))
+}
+
+func TestMaxMessageSizeDecompress(t *testing.T) {
+	defer SetMaxMessageSize(DefaultMaxMessageSize)
+	b := compressPacket(make([]byte, 10*1024), 1)
+	inner := b[binary.Size(CompressedPacketTypeID):]
+	_, err := decompressPacket(inner)
+	require.Nil(t, err)
+	SetMaxMessageSize(1024)
+	_, err = decompressPacket(inner)
+	assert.Equal(t, ErrMessageTooBig, err)
+}
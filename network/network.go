@@ -28,6 +28,18 @@ type Conn interface {
 	Rx() uint64
 }
 
+// Flusher is implemented by connections that can buffer messages and send
+// them all at once, like TCPConn.
+type Flusher interface {
+	// SetAutoFlush turns on or off sending every message right away.
+	SetAutoFlush(auto bool)
+	// Flush sends all buffered messages.
+	Flush() error
+	// SendBatched buffers the message until the next Flush, even if
+	// auto-flush is on.
+	SendBatched(obj Body) error
+}
+
 // Listener is responsible for listening for incoming Conns on a particular
 // address. It can only accept one type of incoming Conn.
 type Listener interface {
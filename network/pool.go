@@ -0,0 +1,156 @@
+package network
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// PoolConfig defines how a Router keeps its connections. A Router always
+// reuses the connection to a remote host for all packets sent to it, the
+// PoolConfig only limits how many connections are kept open and for how
+// long.
+type PoolConfig struct {
+	// MaxConnections is the maximum number of remote hosts a Router keeps
+	// connections to. If a new host is connected, the connections to the
+	// host used the longest ago are closed. 0 means no limit.
+	MaxConnections int
+	// IdleTimeout closes the connections to a remote host if nothing has
+	// been sent to it or received from it for that long. 0 means never.
+	IdleTimeout time.Duration
+}
+
+// keepAlive is accessed atomically. 0 means the default of the system.
+var keepAlive int64
+
+// SetKeepAlive turns on TCP keep-alive messages every d for all TCP and TLS
+// connections opened or accepted afterwards, so connections to hosts that
+// disappeared are detected even if no packets are sent. A d of 0 keeps the
+// default of the system.
+func SetKeepAlive(d time.Duration) {
+	atomic.StoreInt64(&keepAlive, int64(d))
+}
+
+// KeepAlive returns the duration set with SetKeepAlive.
+func KeepAlive() time.Duration {
+	return time.Duration(atomic.LoadInt64(&keepAlive))
+}
+
+// setKeepAlive applies SetKeepAlive to conn if it is a TCP connection.
+func setKeepAlive(conn net.Conn) {
+	d := KeepAlive()
+	tc, ok := conn.(*net.TCPConn)
+	if d <= 0 || !ok {
+		return
+	}
+	if err := tc.SetKeepAlive(true); err != nil {
+		logger().Lvl2("Couldn't set keep-alive:", err)
+		return
+	}
+	if err := tc.SetKeepAlivePeriod(d); err != nil {
+		logger().Lvl2("Couldn't set keep-alive:", err)
+	}
+}
+
+// SetPool changes how the Router keeps its connections. It can be called
+// at any time, connections that are idle for too long are closed within
+// a second.
+func (r *Router) SetPool(conf PoolConfig) {
+	r.connsMut.Lock()
+	defer r.connsMut.Unlock()
+	r.pool = conf
+	r.evictLRU(ServerIdentityID{})
+}
+
+// Pool returns the configuration set with SetPool.
+func (r *Router) Pool() PoolConfig {
+	r.connsMut.Lock()
+	defer r.connsMut.Unlock()
+	return r.pool
+}
+
+// PoolStats returns how many times Send found an open connection to the
+// remote host (hits) and how many times it had to connect first (misses).
+func (r *Router) PoolStats() (hits, misses uint64) {
+	return atomic.LoadUint64(&r.poolHits), atomic.LoadUint64(&r.poolMisses)
+}
+
+// evictIdleLoop closes the idle connections until stop is closed.
+func (r *Router) evictIdleLoop(stop chan bool) {
+	for {
+		select {
+		case <-stop:
+			return
+		case <-time.After(r.idleCheckInterval()):
+			r.evictIdle()
+		}
+	}
+}
+
+// idleCheckInterval returns how often evictIdleLoop checks the connections.
+func (r *Router) idleCheckInterval() time.Duration {
+	d := r.Pool().IdleTimeout / 2
+	if d <= 0 || d > time.Second {
+		return time.Second
+	}
+	return d
+}
+
+// evictIdle closes the connections idle for longer than IdleTimeout.
+func (r *Router) evictIdle() {
+	r.connsMut.Lock()
+	defer r.connsMut.Unlock()
+	if r.pool.IdleTimeout <= 0 {
+		return
+	}
+	for id := range r.connections {
+		if time.Since(r.lastUsed[id]) > r.pool.IdleTimeout {
+			logger().Lvl3(r.address, "Closing idle connection to", id)
+			r.closeConnections(id)
+		}
+	}
+}
+
+// evictLRU closes the connections used the longest ago until at most
+// MaxConnections remote hosts are connected. The connections to keep are
+// never closed.
+// connsMut must be held by the caller.
+func (r *Router) evictLRU(keep ServerIdentityID) {
+	for r.pool.MaxConnections > 0 &&
+		len(r.connections) > r.pool.MaxConnections {
+		var oldest ServerIdentityID
+		var found bool
+		for id := range r.connections {
+			if id == keep {
+				continue
+			}
+			if !found || r.lastUsed[id].Before(r.lastUsed[oldest]) {
+				oldest, found = id, true
+			}
+		}
+		if !found {
+			return
+		}
+		logger().Lvl3(r.address, "Too many connections, closing", oldest)
+		r.closeConnections(oldest)
+	}
+}
+
+// closeConnections closes and forgets all connections to id.
+// connsMut must be held by the caller.
+func (r *Router) closeConnections(id ServerIdentityID) {
+	for _, c := range r.connections[id] {
+		if err := c.Close(); err != nil {
+			logger().Lvl5(err)
+		}
+		delete(r.outgoing, c)
+	}
+	delete(r.connections, id)
+	delete(r.lastUsed, id)
+}
+
+// touch records that the connection to id has been used.
+// connsMut must be held by the caller.
+func (r *Router) touch(id ServerIdentityID) {
+	r.lastUsed[id] = time.Now()
+}
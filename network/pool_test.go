@@ -0,0 +1,83 @@
+package network
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRouterPool(t *testing.T) {
+	var routers []*Router
+	for port := 2140; port < 2143; port++ {
+		r, err := NewTestRouterLocal(port)
+		require.Nil(t, err)
+		go r.Start()
+		defer r.Stop()
+		routers = append(routers, r)
+	}
+	h1, h2, h3 := routers[0], routers[1], routers[2]
+	proc := newSimpleMessageProc(t)
+	h2.RegisterProcessor(proc, SimpleMessageType)
+	h3.RegisterProcessor(proc, SimpleMessageType)
+	send := func(to *Router) {
+		require.Nil(t, h1.Send(to.ServerIdentity, &SimpleMessage{3}))
+		<-proc.relay
+	}
+	connected := func(to *Router) bool {
+		h1.connsMut.Lock()
+		defer h1.connsMut.Unlock()
+		return len(h1.connections[to.ServerIdentity.ID]) > 0
+	}
+
+	h1.SetPool(PoolConfig{MaxConnections: 1})
+	send(h2)
+	send(h2)
+	hits, misses := h1.PoolStats()
+	assert.Equal(t, uint64(1), hits)
+	assert.Equal(t, uint64(1), misses)
+
+	// h2 is evicted as h1 is only allowed one connection
+	send(h3)
+	assert.False(t, connected(h2))
+	assert.True(t, connected(h3))
+	send(h2)
+	assert.True(t, connected(h2))
+	assert.False(t, connected(h3))
+	hits, misses = h1.PoolStats()
+	assert.Equal(t, uint64(1), hits)
+	assert.Equal(t, uint64(3), misses)
+
+	h1.SetPool(PoolConfig{IdleTimeout: 50 * time.Millisecond})
+	assert.Equal(t, 50*time.Millisecond, h1.Pool().IdleTimeout)
+	for i := 0; connected(h2); i++ {
+		require.True(t, i < 100, "idle connection not closed")
+		time.Sleep(10 * time.Millisecond)
+	}
+	send(h2)
+}
+
+func TestKeepAlive(t *testing.T) {
+	defer SetKeepAlive(0)
+	SetKeepAlive(time.Minute)
+	assert.Equal(t, time.Minute, KeepAlive())
+
+	addr := NewTCPAddress("127.0.0.1:2143")
+	ln, err := NewTCPListener(addr)
+	require.Nil(t, err)
+	received := make(chan Packet)
+	go ln.Listen(func(c Conn) {
+		p, err := c.Receive()
+		require.Nil(t, err)
+		received <- p
+		c.Close()
+	})
+	defer ln.Stop()
+	c, err := NewTCPConn(addr)
+	require.Nil(t, err)
+	defer c.Close()
+	require.Nil(t, c.Send(&SimpleMessage{3}))
+	p := <-received
+	assert.Equal(t, 3, p.Msg.(SimpleMessage).I)
+}
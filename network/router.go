@@ -1,11 +1,12 @@
 package network
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
 	"sync"
-
-	"github.com/dedis/cothority/log"
+	"sync/atomic"
+	"time"
 )
 
 // Router handles all networking operations such as:
@@ -29,11 +30,28 @@ type Router struct {
 	Dispatcher
 	// Host listens for new connections
 	host Host
-	// connections keeps track of all active connections. Because a connection
-	// can be opened at the same time on both endpoints, there can be more
-	// than one connection per ServerIdentityID.
+	// connections keeps track of all active connections. If a connection
+	// is opened at the same time on both endpoints, only one of them is
+	// kept, see registerConnection.
 	connections map[ServerIdentityID][]Conn
-	connsMut    sync.Mutex
+	// outgoing holds all connections, including duplicates that are not
+	// used for sending anymore. It is true if the connection has been
+	// opened by this router.
+	outgoing map[Conn]bool
+	// lastUsed holds when a packet has last been sent to or received from
+	// a remote host.
+	lastUsed map[ServerIdentityID]time.Time
+	// pool limits the connections kept open, see SetPool.
+	pool PoolConfig
+	// compression is given to all connections, see SetCompression.
+	compression int
+	// poolStop is closed by Stop to end evictIdleLoop.
+	poolStop chan bool
+	connsMut sync.Mutex
+
+	// poolHits and poolMisses are accessed atomically, see PoolStats.
+	poolHits   uint64
+	poolMisses uint64
 
 	// boolean flag indicating that the router is already clos{ing,ed}.
 	isClosed bool
@@ -42,12 +60,18 @@ type Router struct {
 	wg sync.WaitGroup
 }
 
+// duplicateLinger is how long a duplicate connection is still read from
+// before it is closed, see registerConnection.
+var duplicateLinger = time.Second
+
 // NewRouter returns a new Router attached to a ServerIdentity and the host we want to
 // use.
 func NewRouter(own *ServerIdentity, h Host) *Router {
 	r := &Router{
 		ServerIdentity: own,
 		connections:    make(map[ServerIdentityID][]Conn),
+		outgoing:       make(map[Conn]bool),
+		lastUsed:       make(map[ServerIdentityID]time.Time),
 		host:           h,
 		Dispatcher:     NewBlockingDispatcher(),
 	}
@@ -58,14 +82,19 @@ func NewRouter(own *ServerIdentity, h Host) *Router {
 // Start the listening routine of the underlying Host. This is a
 // blocking call until r.Stop() is called.
 func (r *Router) Start() {
+	stop := make(chan bool)
+	r.connsMut.Lock()
+	r.poolStop = stop
+	r.connsMut.Unlock()
+	go r.evictIdleLoop(stop)
 	// Any incoming connection waits for the remote server identity
 	// and will create a new handling routine.
 	err := r.host.Listen(func(c Conn) {
 		dst, err := r.receiveServerIdentity(c)
 		if err != nil {
-			log.Error("receive server identity failed:", err)
+			logger().Error("receive server identity failed:", err)
 			if err := c.Close(); err != nil {
-				log.Error("Couldn't close secure connection:",
+				logger().Error("Couldn't close secure connection:",
 					err)
 			}
 			return
@@ -75,7 +104,7 @@ func (r *Router) Start() {
 		r.launchHandleRoutine(dst, c)
 	})
 	if err != nil {
-		log.Error("Error listening:", err)
+		logger().Error("Error listening:", err)
 	}
 }
 
@@ -91,16 +120,27 @@ func (r *Router) Stop() error {
 	r.connsMut.Lock()
 	// set the isClosed to true
 	r.isClosed = true
+	if r.poolStop != nil {
+		close(r.poolStop)
+		r.poolStop = nil
+	}
 
 	// then close all connections
 	for _, arr := range r.connections {
 		// take all connections to close
 		for _, c := range arr {
 			if err := c.Close(); err != nil {
-				log.Lvl5(err)
+				logger().Lvl5(err)
 			}
 		}
 	}
+	// and the duplicate connections still waiting to be closed by the
+	// remote side
+	for c := range r.outgoing {
+		if err := c.Close(); err != nil {
+			logger().Lvl5(err)
+		}
+	}
 	r.connsMut.Unlock()
 
 	// wait for all handleConn to finish
@@ -117,12 +157,55 @@ func (r *Router) Stop() error {
 
 // Send sends to an ServerIdentity without wrapping the msg into a SDAMessage
 func (r *Router) Send(e *ServerIdentity, msg Body) error {
+	return r.send(e, msg, false)
+}
+
+// SendBatched is like Send, but the message is only buffered in the
+// connection to e, until Flush is called for e or another message is sent to
+// e with Send. This lets a caller send many messages at once. Connections
+// that can't buffer, like the local ones, send the message right away.
+func (r *Router) SendBatched(e *ServerIdentity, msg Body) error {
+	return r.send(e, msg, true)
+}
+
+// Flush sends all messages buffered by SendBatched for e.
+func (r *Router) Flush(e *ServerIdentity) error {
+	r.connsMut.Lock()
+	conns := append([]Conn{}, r.connections[e.ID]...)
+	r.connsMut.Unlock()
+	for _, c := range conns {
+		if f, ok := c.(Flusher); ok {
+			if err := f.Flush(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// sendConn sends msg on c, buffering it if batched is true and c can buffer.
+func sendConn(c Conn, msg Body, batched bool) error {
+	if f, ok := c.(Flusher); ok && batched {
+		return f.SendBatched(msg)
+	}
+	return c.Send(msg)
+}
+
+// send does the work of Send and SendBatched.
+func (r *Router) send(e *ServerIdentity, msg Body, batched bool) error {
 	if msg == nil {
 		return errors.New("Can't send nil-packet")
 	}
+	msg, err := Hop(msg)
+	if err != nil {
+		return err
+	}
 
 	c := r.connection(e.ID)
-	if c == nil {
+	if c != nil {
+		atomic.AddUint64(&r.poolHits, 1)
+	} else {
+		atomic.AddUint64(&r.poolMisses, 1)
 		var err error
 		c, err = r.connect(e)
 		if err != nil {
@@ -130,42 +213,48 @@ func (r *Router) Send(e *ServerIdentity, msg Body) error {
 		}
 	}
 
-	log.Lvlf4("%s sends to %s msg: %+v", r.address, e, msg)
-	var err error
-	err = c.Send(msg)
+	logger().Lvlf4("%s sends to %s msg: %+v", r.address, e, msg)
+	err = sendConn(c, msg, batched)
 	if err != nil {
-		log.Lvl2(r.address, "Couldn't send to", e, ":", err, "trying again")
-		c, err := r.connect(e)
-		if err != nil {
-			return err
+		logger().Lvl2(r.address, "Couldn't send to", e, ":", err, "trying again")
+		// The connection might have been replaced by one opened by the
+		// remote side.
+		c2 := r.connection(e.ID)
+		if c2 == nil || c2 == c {
+			c2, err = r.connect(e)
+			if err != nil {
+				return err
+			}
 		}
-		err = c.Send(msg)
+		err = sendConn(c2, msg, batched)
 		if err != nil {
 			return err
 		}
 	}
-	log.Lvl5("Message sent")
+	logger().Lvl5("Message sent")
 	return nil
 }
 
 // connect starts a new connection and launches the listener for incoming
 // messages.
 func (r *Router) connect(si *ServerIdentity) (Conn, error) {
-	log.Lvl3(r.address, "Connecting to", si.Address)
+	logger().Lvl3(r.address, "Connecting to", si.Address)
 	c, err := r.host.Connect(si)
 	if err != nil {
-		log.Lvl3("Could not connect to", si.Address, err)
+		logger().Lvl3("Could not connect to", si.Address, err)
 		return nil, err
 	}
-	log.Lvl3(r.address, "Connected to", si.Address)
+	logger().Lvl3(r.address, "Connected to", si.Address)
 	if err := c.Send(r.ServerIdentity); err != nil {
 		return nil, err
 	}
 
-	r.registerConnection(si, c)
-
+	// If the remote side already connected to us, its connection might be
+	// kept instead of c. c is read from anyway until it is closed, as
+	// the remote side might send on it before it notices the duplicate.
+	kept := r.registerConnection(si, c, true)
 	r.launchHandleRoutine(si, c)
-	return c, nil
+	return kept, nil
 
 }
 
@@ -176,12 +265,15 @@ func (r *Router) handleConn(remote *ServerIdentity, c Conn) {
 	defer func() {
 		// Clean up the connection by making sure it's closed.
 		if err := c.Close(); err != nil {
-			log.Lvl5(r.address, "having error closing conn to", remote.Address, ":", err)
+			logger().Lvl5(r.address, "having error closing conn to", remote.Address, ":", err)
 		}
+		r.connsMut.Lock()
+		r.removeConnection(remote.ID, c)
+		r.connsMut.Unlock()
 		r.wg.Done()
 	}()
 	address := c.Remote()
-	log.Lvl3(r.address, "Handling new connection to", remote.Address)
+	logger().Lvl3(r.address, "Handling new connection to", remote.Address)
 	for {
 		packet, err := c.Receive()
 
@@ -190,30 +282,33 @@ func (r *Router) handleConn(remote *ServerIdentity, c Conn) {
 		}
 
 		if err != nil {
-			log.Lvlf4("%+v got error (%+s) while receiving message", r.ServerIdentity.String(), err)
+			logger().Lvlf4("%+v got error (%+s) while receiving message", r.ServerIdentity.String(), err)
 
-			if err == ErrClosed || err == ErrEOF {
+			if err == ErrClosed || err == ErrEOF || err == ErrMessageTooBig {
 				// Connection got closed.
-				log.Lvl3(r.address, "handleConn with closed connection: stop (dst=", remote.Address, ")")
+				logger().Lvl3(r.address, "handleConn with closed connection: stop (dst=", remote.Address, ")")
 				return
 			}
 			// Temporary error, continue.
-			log.Lvl3(r.ServerIdentity, "Error with connection", address, "=>", err)
+			logger().Lvl3(r.ServerIdentity, "Error with connection", address, "=>", err)
 			continue
 		}
 
 		packet.From = address
 		packet.ServerIdentity = remote
+		r.connsMut.Lock()
+		r.touch(remote.ID)
+		r.connsMut.Unlock()
 
 		if err := r.Dispatch(&packet); err != nil {
-			log.Lvl3("Error dispatching:", err)
+			logger().Lvl3("Error dispatching:", err)
 		}
 
 	}
 }
 
-// connection returns the first connection associated with this ServerIdentity.
-// If no connection is found, it returns nil.
+// connection returns the first connection associated with this ServerIdentity
+// and marks it as used. If no connection is found, it returns nil.
 func (r *Router) connection(sid ServerIdentityID) Conn {
 	r.connsMut.Lock()
 	defer r.connsMut.Unlock()
@@ -221,21 +316,78 @@ func (r *Router) connection(sid ServerIdentityID) Conn {
 	if len(arr) == 0 {
 		return nil
 	}
+	r.touch(sid)
 	return arr[0]
 }
 
+// removeConnection forgets the closed connection c to id.
+// connsMut must be held by the caller.
+func (r *Router) removeConnection(id ServerIdentityID, c Conn) {
+	delete(r.outgoing, c)
+	arr := r.connections[id]
+	for i, ci := range arr {
+		if ci == c {
+			arr = append(arr[:i:i], arr[i+1:]...)
+			break
+		}
+	}
+	if len(arr) == 0 {
+		delete(r.connections, id)
+		delete(r.lastUsed, id)
+		return
+	}
+	r.connections[id] = arr
+}
+
 // registerConnection registers a ServerIdentity for a new connection, mapped with the
 // real physical address of the connection and the connection itself.
+// outgoing is true if the connection has been opened by us.
+//
+// If both sides connected to each other at the same time, only the connection
+// opened by the side with the lower ServerIdentityID is kept, so both sides
+// agree on the same connection. The other one is not used for sending
+// anymore, but it is still read from, as the remote side might have sent
+// packets on it before it noticed the duplicate. If we opened it, we close it
+// after duplicateLinger, else the remote side closes it.
+// registerConnection returns the connection to use for sending.
 // It uses the networkLock mutex.
-func (r *Router) registerConnection(remote *ServerIdentity, c Conn) {
-	log.Lvl4(r.address, "Registers", remote.Address)
+func (r *Router) registerConnection(remote *ServerIdentity, c Conn, outgoing bool) Conn {
+	logger().Lvl4(r.address, "Registers", remote.Address)
 	r.connsMut.Lock()
 	defer r.connsMut.Unlock()
-	_, okc := r.connections[remote.ID]
-	if okc {
-		log.Lvl5("Connection already registered. Appending new connection to same identity.")
+	r.outgoing[c] = outgoing
+	if cc, ok := c.(compressor); ok {
+		cc.SetCompression(r.compression)
+	}
+	r.touch(remote.ID)
+	arr := r.connections[remote.ID]
+	if len(arr) == 0 {
+		r.connections[remote.ID] = []Conn{c}
+		r.evictLRU(remote.ID)
+		return c
+	}
+	old := arr[0]
+	if r.outgoing[old] == outgoing {
+		logger().Lvl5("Connection already registered. Appending new connection to same identity.")
+		r.connections[remote.ID] = append(arr, c)
+		return old
+	}
+	// Simultaneous connect: keep the connection opened by the lower ID.
+	keepOutgoing := bytes.Compare(r.ServerIdentity.ID[:], remote.ID[:]) < 0
+	kept, dropped := c, old
+	if outgoing != keepOutgoing {
+		kept, dropped = old, c
+	}
+	logger().Lvl3(r.address, "Dropping duplicate connection to", remote.Address)
+	r.connections[remote.ID] = append([]Conn{kept}, arr[1:]...)
+	if r.outgoing[dropped] {
+		time.AfterFunc(duplicateLinger, func() {
+			if err := dropped.Close(); err != nil {
+				logger().Lvl5(err)
+			}
+		})
 	}
-	r.connections[remote.ID] = append(r.connections[remote.ID], c)
+	return kept
 }
 
 func (r *Router) launchHandleRoutine(dst *ServerIdentity, c Conn) {
@@ -303,7 +455,7 @@ func (r *Router) receiveServerIdentity(c Conn) (*ServerIdentity, error) {
 	if err != nil {
 		return nil, err
 	}
-	log.Lvl4(r.address, "Identity received from", dst.Address)
-	r.registerConnection(&dst, c)
+	logger().Lvl4(r.address, "Identity received from", dst.Address)
+	r.registerConnection(&dst, c, false)
 	return &dst, nil
 }
@@ -1,6 +1,7 @@
 package network
 
 import (
+	"bytes"
 	"sync"
 	"testing"
 	"time"
@@ -352,3 +353,126 @@ func TestRouterExchange(t *testing.T) {
 	}
 	<-done
 }
+
+func TestRouterSimultaneousConnect(t *testing.T) {
+	h1, err1 := NewTestRouterTCP(2015)
+	h2, err2 := NewTestRouterTCP(2016)
+	if err1 != nil || err2 != nil {
+		t.Fatal("Could not setup hosts")
+	}
+	go h1.Start()
+	go h2.Start()
+	for !h1.Listening() || !h2.Listening() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer func() {
+		assert.Nil(t, h1.Stop())
+		assert.Nil(t, h2.Stop())
+	}()
+
+	// Both sides connect to each other at the same time.
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		_, err := h1.connect(h2.ServerIdentity)
+		assert.Nil(t, err)
+	}()
+	go func() {
+		defer wg.Done()
+		_, err := h2.connect(h1.ServerIdentity)
+		assert.Nil(t, err)
+	}()
+	wg.Wait()
+
+	// Wait for both sides to have handled both connections.
+	connCount := func(r *Router, id ServerIdentityID) int {
+		r.connsMut.Lock()
+		defer r.connsMut.Unlock()
+		return len(r.connections[id])
+	}
+	for i := 0; i < 10; i++ {
+		if connCount(h1, h2.ServerIdentity.ID) == 1 &&
+			connCount(h2, h1.ServerIdentity.ID) == 1 {
+			break
+		}
+		time.Sleep(WaitRetry)
+	}
+	require.Equal(t, 1, connCount(h1, h2.ServerIdentity.ID))
+	require.Equal(t, 1, connCount(h2, h1.ServerIdentity.ID))
+	c12 := h1.connection(h2.ServerIdentity.ID)
+	c21 := h2.connection(h1.ServerIdentity.ID)
+	assert.Equal(t, c12.Local(), c21.Remote())
+	assert.Equal(t, c12.Remote(), c21.Local())
+
+	proc := newSimpleMessageProc(t)
+	h1.RegisterProcessor(proc, SimpleMessageType)
+	h2.RegisterProcessor(proc, SimpleMessageType)
+	require.Nil(t, h1.Send(h2.ServerIdentity, &SimpleMessage{1}))
+	assert.Equal(t, 1, (<-proc.relay).I)
+	require.Nil(t, h2.Send(h1.ServerIdentity, &SimpleMessage{2}))
+	assert.Equal(t, 2, (<-proc.relay).I)
+	assert.Equal(t, c12, h1.connection(h2.ServerIdentity.ID))
+	assert.Equal(t, c21, h2.connection(h1.ServerIdentity.ID))
+}
+
+// Packets sent on a connection before it is found to be a duplicate are
+// still delivered, and the duplicate is forgotten once it is closed.
+func TestRouterSimultaneousConnectDrain(t *testing.T) {
+	defer func(d time.Duration) { duplicateLinger = d }(duplicateLinger)
+	duplicateLinger = 500 * time.Millisecond
+	h1, err1 := NewTestRouterTCP(2145)
+	h2, err2 := NewTestRouterTCP(2146)
+	if err1 != nil || err2 != nil {
+		t.Fatal("Could not setup hosts")
+	}
+	go h1.Start()
+	go h2.Start()
+	for !h1.Listening() || !h2.Listening() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	defer func() {
+		assert.Nil(t, h1.Stop())
+		assert.Nil(t, h2.Stop())
+	}()
+	// The connection opened by hi is dropped and closed by hi.
+	lo, hi := h1, h2
+	if bytes.Compare(hi.ServerIdentity.ID[:], lo.ServerIdentity.ID[:]) < 0 {
+		lo, hi = hi, lo
+	}
+	proc := newSimpleMessageProc(t)
+	hi.RegisterProcessor(proc, SimpleMessageType)
+
+	dup, err := hi.connect(lo.ServerIdentity)
+	require.Nil(t, err)
+	for lo.connection(hi.ServerIdentity.ID) == nil {
+		time.Sleep(10 * time.Millisecond)
+	}
+	// hi blocks in the processor on the first message, so the second one
+	// is still waiting on dup when it is dropped.
+	require.Nil(t, lo.Send(hi.ServerIdentity, &SimpleMessage{1}))
+	require.Nil(t, lo.Send(hi.ServerIdentity, &SimpleMessage{2}))
+	_, err = lo.connect(hi.ServerIdentity)
+	require.Nil(t, err)
+	for hi.connection(lo.ServerIdentity.ID) == dup {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, 1, (<-proc.relay).I)
+	select {
+	case msg := <-proc.relay:
+		assert.Equal(t, 2, msg.I)
+	case <-time.After(time.Second):
+		t.Fatal("Message on the duplicate connection got lost")
+	}
+
+	outgoing := func(r *Router) int {
+		r.connsMut.Lock()
+		defer r.connsMut.Unlock()
+		return len(r.outgoing)
+	}
+	for i := 0; i < 50 && (outgoing(hi) > 1 || outgoing(lo) > 1); i++ {
+		time.Sleep(WaitRetry)
+	}
+	assert.Equal(t, 1, outgoing(hi))
+	assert.Equal(t, 1, outgoing(lo))
+}
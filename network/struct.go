@@ -8,7 +8,6 @@ import (
 	"time"
 
 	"github.com/dedis/cothority/crypto"
-	"github.com/dedis/cothority/log"
 	"github.com/dedis/crypto/abstract"
 	"github.com/dedis/protobuf"
 	"github.com/satori/go.uuid"
@@ -119,7 +118,7 @@ func (si *ServerIdentity) Equal(e2 *ServerIdentity) bool {
 func (si *ServerIdentity) Toml(suite abstract.Suite) *ServerIdentityToml {
 	var buf bytes.Buffer
 	if err := crypto.WritePub64(suite, &buf, si.Public); err != nil {
-		log.Error("Error while writing public key:", err)
+		logger().Error("Error while writing public key:", err)
 	}
 	return &ServerIdentityToml{
 		Address: si.Address,
@@ -131,7 +130,7 @@ func (si *ServerIdentity) Toml(suite abstract.Suite) *ServerIdentityToml {
 func (si *ServerIdentityToml) ServerIdentity(suite abstract.Suite) *ServerIdentity {
 	pub, err := crypto.ReadPub64(suite, strings.NewReader(si.Public))
 	if err != nil {
-		log.Error("Error while reading public key:", err)
+		logger().Error("Error while reading public key:", err)
 	}
 	return &ServerIdentity{
 		Public:  pub,
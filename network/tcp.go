@@ -10,8 +10,6 @@ import (
 	"strings"
 	"sync"
 	"time"
-
-	"github.com/dedis/cothority/log"
 )
 
 // NewTCPRouter returns a new Router using TCPHost as the underlying Host.
@@ -39,6 +37,14 @@ type TCPConn struct {
 	receiveMutex sync.Mutex
 	// So we only handle one sending packet at a time
 	sendMutex sync.Mutex
+	// noAutoFlush is set by SetAutoFlush, then all messages are kept in
+	// sendBuffer until Flush is called. Both are protected by sendMutex.
+	noAutoFlush bool
+	sendBuffer  bytes.Buffer
+	// compression is set by SetCompression and protected by sendMutex
+	compression int
+	// chunks holds the chunks of a big packet until it is complete
+	chunks reassembler
 
 	counterSafe
 }
@@ -46,11 +52,27 @@ type TCPConn struct {
 // NewTCPConn will open a TCPConn to the given address.
 // In case of an error it returns a nil TCPConn and the error.
 func NewTCPConn(addr Address) (*TCPConn, error) {
+	return dialConn("tcp", addr)
+}
+
+// dialConn opens a connection of the given golang-network to addr and
+// retries MaxRetryConnect times before giving up.
+func dialConn(network string, addr Address) (*TCPConn, error) {
+	return dialConnWith(addr, func(netAddr string) (net.Conn, error) {
+		return net.Dial(network, netAddr)
+	})
+}
+
+// dialConnWith opens a connection to addr using dial and retries
+// MaxRetryConnect times before giving up.
+func dialConnWith(addr Address, dial func(string) (net.Conn, error)) (*TCPConn, error) {
 	netAddr := addr.NetworkAddress()
 	var err error
 	for i := 0; i < MaxRetryConnect; i++ {
-		conn, err := net.Dial("tcp", netAddr)
+		var conn net.Conn
+		conn, err = dial(netAddr)
 		if err == nil {
+			setKeepAlive(conn)
 			return &TCPConn{
 				endpoint: addr,
 				conn:     conn,
@@ -73,12 +95,19 @@ func (c *TCPConn) Receive() (nm Packet, e error) {
 	}()
 
 	var am Packet
-	buff, err := c.receiveRaw()
-	if err != nil {
-		return EmptyApplicationPacket, err
+	var buff []byte
+	for complete := false; !complete; {
+		b, err := c.receiveRaw()
+		if err != nil {
+			return EmptyApplicationPacket, err
+		}
+		buff, complete, err = c.chunks.add(b)
+		if err != nil {
+			return EmptyApplicationPacket, err
+		}
 	}
 
-	err = am.UnmarshalBinary(buff)
+	err := am.UnmarshalBinary(buff)
 	if err != nil {
 		return EmptyApplicationPacket, fmt.Errorf("Error unmarshaling message type %s: %s", am.MsgType.String(), err.Error())
 	}
@@ -99,6 +128,12 @@ func (c *TCPConn) receiveRaw() ([]byte, error) {
 	if err := binary.Read(c.conn, globalOrder, &total); err != nil {
 		return nil, handleError(err)
 	}
+	// Chunks and compressed packets are smaller than the packet itself,
+	// the header of a chunk is the only overhead.
+	if err := checkSize(int(total) - chunkHeaderSize); err != nil {
+		c.conn.Close()
+		return nil, err
+	}
 	b := make([]byte, total)
 	var read Size
 	var buffer bytes.Buffer
@@ -111,7 +146,7 @@ func (c *TCPConn) receiveRaw() ([]byte, error) {
 		}
 		// Append the read bytes into the buffer.
 		if _, err := buffer.Write(b[:n]); err != nil {
-			log.Error("Couldn't write to buffer:", err)
+			logger().Error("Couldn't write to buffer:", err)
 		}
 		read += Size(n)
 		b = b[n:]
@@ -123,36 +158,102 @@ func (c *TCPConn) receiveRaw() ([]byte, error) {
 }
 
 // Send converts the NetworkMessage into an ApplicationMessage
-// and sends it using send().
+// and sends it using send(). Big messages are compressed if turned on
+// with SetCompression, and split in chunks if turned on with SetChunkSize.
 // It returns an error if anything was wrong.
 func (c *TCPConn) Send(obj Body) error {
+	return c.send(obj, false)
+}
+
+// SendBatched is like Send, but the message is only buffered, as if
+// auto-flush was off. It is sent with the next Flush, or with the next
+// message sent by Send while auto-flush is on. Unlike SetAutoFlush, this
+// doesn't change how the other users of the connection send.
+func (c *TCPConn) SendBatched(obj Body) error {
+	return c.send(obj, true)
+}
+
+// send does the work of Send and SendBatched.
+func (c *TCPConn) send(obj Body, batched bool) error {
 	c.sendMutex.Lock()
 	defer c.sendMutex.Unlock()
 	am, err := NewNetworkPacket(obj)
 	if err != nil {
 		return fmt.Errorf("Error converting packet: %v", err)
 	}
-	log.Lvlf5("Message SEND => %+v", am)
+	logger().Lvlf5("Message SEND => %+v", am)
 	var b []byte
 	b, err = am.MarshalBinary()
 	if err != nil {
 		return fmt.Errorf("Error marshaling  message: %s", err.Error())
 	}
-	return c.sendRaw(b)
+	if err := checkSize(len(b)); err != nil {
+		return err
+	}
+	for _, chunk := range splitPacket(compressPacket(b, c.compression)) {
+		if err := c.writeRaw(chunk, batched); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetAutoFlush turns on or off sending every message right away. If it is
+// off, messages are buffered until Flush is called, so that a caller can
+// send many messages at once. When turning it on again, the buffered messages
+// are sent with the next message or by calling Flush.
+func (c *TCPConn) SetAutoFlush(auto bool) {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+	c.noAutoFlush = !auto
+}
+
+// Flush sends all buffered messages.
+func (c *TCPConn) Flush() error {
+	c.sendMutex.Lock()
+	defer c.sendMutex.Unlock()
+	return c.flush()
+}
+
+// flush writes the buffered messages to the network. It must be called
+// with the sendMutex held.
+func (c *TCPConn) flush() error {
+	if _, err := c.sendBuffer.WriteTo(c.conn); err != nil {
+		return handleError(err)
+	}
+	return nil
 }
 
 // sendRaw writes the number of bytes of the message to the network then the
 // whole message b in slices of size maxChunkSize.
 // In case of an error it aborts and returns error.
 func (c *TCPConn) sendRaw(b []byte) error {
-	// First write the size
+	return c.writeRaw(b, false)
+}
+
+// writeRaw is like sendRaw, but only buffers b if batched is true.
+func (c *TCPConn) writeRaw(b []byte, batched bool) error {
 	packetSize := Size(len(b))
+	if batched || c.noAutoFlush {
+		if err := binary.Write(&c.sendBuffer, globalOrder, packetSize); err != nil {
+			return err
+		}
+		c.sendBuffer.Write(b)
+		c.updateTx(uint64(packetSize))
+		return nil
+	}
+	if c.sendBuffer.Len() > 0 {
+		if err := c.flush(); err != nil {
+			return err
+		}
+	}
+	// First write the size
 	if err := binary.Write(c.conn, globalOrder, packetSize); err != nil {
 		return err
 	}
 	// Then send everything through the connection
 	// Send chunk by chunk
-	log.Lvl5("Sending from", c.conn.LocalAddr(), "to", c.conn.RemoteAddr())
+	logger().Lvl5("Sending from", c.conn.LocalAddr(), "to", c.conn.RemoteAddr())
 	var sent Size
 	for sent < packetSize {
 		n, err := c.conn.Write(b[sent:])
@@ -238,6 +339,10 @@ type TCPListener struct {
 	// actual listening addr which might differ from initial address in
 	// case of ":0"-address.
 	addr net.Addr
+	// connType is the type of the addresses of this listener
+	connType ConnType
+	// newConn wraps an accepted connection in a Conn
+	newConn func(net.Conn) Conn
 }
 
 // NewTCPListener returns a TCPListener. This function binds to the given
@@ -250,13 +355,26 @@ func NewTCPListener(addr Address) (*TCPListener, error) {
 	if addr.ConnType() != PlainTCP {
 		return nil, errors.New("TCPListener can't listen on non-tcp address")
 	}
+	global, _ := GlobalBind(addr.NetworkAddress())
+	return newListener("tcp", global, PlainTCP, func(conn net.Conn) Conn {
+		return &TCPConn{
+			endpoint: NewTCPAddress(conn.RemoteAddr().String()),
+			conn:     conn,
+		}
+	})
+}
+
+// newListener binds to the given golang-network and address and returns a
+// TCPListener that uses newConn to wrap the incoming connections.
+func newListener(network, bind string, ct ConnType, newConn func(net.Conn) Conn) (*TCPListener, error) {
 	t := &TCPListener{
 		quit:         make(chan bool),
 		quitListener: make(chan bool),
+		connType:     ct,
+		newConn:      newConn,
 	}
-	global, _ := GlobalBind(addr.NetworkAddress())
 	for i := 0; i < MaxRetryConnect; i++ {
-		ln, err := net.Listen("tcp", global)
+		ln, err := net.Listen(network, bind)
 		if err == nil {
 			t.listener = ln
 			break
@@ -301,11 +419,8 @@ func (t *TCPListener) listen(fn func(Conn)) error {
 			}
 			continue
 		}
-		c := TCPConn{
-			endpoint: NewTCPAddress(conn.RemoteAddr().String()),
-			conn:     conn,
-		}
-		fn(&c)
+		setKeepAlive(conn)
+		fn(t.newConn(conn))
 	}
 }
 
@@ -348,7 +463,7 @@ func (t *TCPListener) Stop() error {
 func (t *TCPListener) Address() Address {
 	t.listeningLock.Lock()
 	defer t.listeningLock.Unlock()
-	return NewAddress(PlainTCP, t.addr.String())
+	return NewAddress(t.connType, t.addr.String())
 }
 
 // Listening returns whether it's already listening.
@@ -518,3 +518,62 @@ func acceptAndClose(c Conn) {
 	c.Close()
 	return
 }
+
+func TestTCPConnFlush(t *testing.T) {
+	addr := NewTCPAddress("127.0.0.1:5679")
+	ln, err := NewTCPListener(addr)
+	require.Nil(t, err)
+	received := make(chan int, 10)
+	stop := make(chan bool)
+	go func() {
+		err := ln.Listen(func(c Conn) {
+			for {
+				p, err := c.Receive()
+				if err != nil {
+					return
+				}
+				received <- p.Msg.(SimpleMessage).I
+			}
+		})
+		require.Nil(t, err)
+		stop <- true
+	}()
+	for !ln.Listening() {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	c, err := NewTCPConn(addr)
+	require.Nil(t, err)
+	var _ Flusher = c
+	c.SetAutoFlush(false)
+	for i := 0; i < 3; i++ {
+		require.Nil(t, c.Send(&SimpleMessage{i}))
+	}
+	select {
+	case <-received:
+		t.Fatal("Message delivered before Flush")
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.Nil(t, c.Flush())
+	for i := 0; i < 3; i++ {
+		select {
+		case v := <-received:
+			require.Equal(t, i, v)
+		case <-time.After(time.Second):
+			t.Fatal("Message not delivered after Flush")
+		}
+	}
+
+	// With auto-flush on again, messages are delivered right away.
+	c.SetAutoFlush(true)
+	require.Nil(t, c.Send(&SimpleMessage{3}))
+	select {
+	case v := <-received:
+		require.Equal(t, 3, v)
+	case <-time.After(time.Second):
+		t.Fatal("Message not delivered with auto-flush")
+	}
+	require.Nil(t, c.Close())
+	require.Nil(t, ln.Stop())
+	<-stop
+}
@@ -0,0 +1,206 @@
+package network
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"time"
+)
+
+// TLSHandshakeTimeout is the maximum time to wait for the TLS handshake
+// when connecting to a remote host.
+const TLSHandshakeTimeout = 10 * time.Second
+
+// TLSConfig holds the certificates used by the TLS connections.
+type TLSConfig struct {
+	// Certificate is presented to the remote side, as a server and as a
+	// client.
+	Certificate tls.Certificate
+	// CAs is used to verify the certificates of the remote side. It is
+	// needed, as connections without verifying the remote side could be
+	// intercepted by anybody.
+	CAs *x509.CertPool
+	// VerifyClient makes the listener refuse clients without a certificate
+	// signed by one of the CAs.
+	VerifyClient bool
+}
+
+// LoadTLSConfig reads the PEM-encoded certificate and key from the given
+// files. caFile holds the PEM-encoded certificates used to verify the remote
+// side.
+func LoadTLSConfig(certFile, keyFile, caFile string, verifyClient bool) (*TLSConfig, error) {
+	if caFile == "" {
+		return nil, errors.New("Need a CA-file to verify the remote side")
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't load TLS certificate: %s", err)
+	}
+	c := &TLSConfig{
+		Certificate:  cert,
+		VerifyClient: verifyClient,
+	}
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, fmt.Errorf("Couldn't read TLS CA-file: %s", err)
+	}
+	c.CAs = x509.NewCertPool()
+	if !c.CAs.AppendCertsFromPEM(pem) {
+		return nil, errors.New("No certificate found in CA-file " + caFile)
+	}
+	return c, nil
+}
+
+// check returns an error if the remote side can't be verified with c.
+func (c *TLSConfig) check() error {
+	if c == nil || c.CAs == nil {
+		return errors.New("TLS needs CAs to verify the remote side")
+	}
+	return nil
+}
+
+// server returns the tls.Config used by the listener.
+func (c *TLSConfig) server() *tls.Config {
+	conf := &tls.Config{
+		Certificates: []tls.Certificate{c.Certificate},
+		MinVersion:   tls.VersionTLS12,
+	}
+	if c.VerifyClient {
+		conf.ClientAuth = tls.RequireAndVerifyClientCert
+		conf.ClientCAs = c.CAs
+	}
+	return conf
+}
+
+// client returns the tls.Config used to connect to addr.
+func (c *TLSConfig) client(addr Address) *tls.Config {
+	return &tls.Config{
+		Certificates: []tls.Certificate{c.Certificate},
+		RootCAs:      c.CAs,
+		ServerName:   addr.Host(),
+		MinVersion:   tls.VersionTLS12,
+	}
+}
+
+// NewTLSRouter returns a new Router using TLSHost as the underlying Host.
+func NewTLSRouter(sid *ServerIdentity, conf *TLSConfig) (*Router, error) {
+	h, err := NewTLSHost(sid.Address, conf)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRouter(sid, h)
+	return r, nil
+}
+
+// TLSConn implements the Conn interface using TLS over TCP. It uses the same
+// framing as TCPConn.
+type TLSConn struct {
+	*TCPConn
+}
+
+// NewTLSConn will open a TLSConn to the given address and do the TLS
+// handshake. In case of an error, including a failed handshake, it returns a
+// nil TLSConn and the error.
+func NewTLSConn(addr Address, conf *TLSConfig) (*TLSConn, error) {
+	if addr.ConnType() != TLS {
+		return nil, errors.New("TLSConn can't connect to non-tls address")
+	}
+	if err := conf.check(); err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: TLSHandshakeTimeout, KeepAlive: KeepAlive()}
+	c, err := dialConnWith(addr, func(netAddr string) (net.Conn, error) {
+		return tls.DialWithDialer(dialer, "tcp", netAddr, conf.client(addr))
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &TLSConn{c}, nil
+}
+
+// Local returns the local address and port.
+func (c *TLSConn) Local() Address {
+	return NewTLSAddress(c.conn.LocalAddr().String())
+}
+
+// Type returns TLS.
+func (c *TLSConn) Type() ConnType {
+	return TLS
+}
+
+// TLSListener implements the Listener-interface using TLS over TCP.
+type TLSListener struct {
+	*TCPListener
+}
+
+// NewTLSListener returns a TLSListener bound to the given address. The
+// handshake with a new client is done when the first message is received.
+func NewTLSListener(addr Address, conf *TLSConfig) (*TLSListener, error) {
+	if addr.ConnType() != TLS {
+		return nil, errors.New("TLSListener can't listen on non-tls address")
+	}
+	if err := conf.check(); err != nil {
+		return nil, err
+	}
+	global, _ := GlobalBind(addr.NetworkAddress())
+	l, err := newListener("tcp", global, TLS, func(conn net.Conn) Conn {
+		return &TLSConn{&TCPConn{
+			endpoint: NewTLSAddress(conn.RemoteAddr().String()),
+			conn:     conn,
+		}}
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.listener = tls.NewListener(l.listener, conf.server())
+	return &TLSListener{l}, nil
+}
+
+// TLSHost implements the Host interface using TLS connections.
+type TLSHost struct {
+	addr Address
+	conf *TLSConfig
+	*TLSListener
+}
+
+// NewTLSHost returns a new Host using TLS connections with the given
+// certificates.
+func NewTLSHost(addr Address, conf *TLSConfig) (*TLSHost, error) {
+	h := &TLSHost{
+		addr: addr,
+		conf: conf,
+	}
+	var err error
+	h.TLSListener, err = NewTLSListener(addr, conf)
+	return h, err
+}
+
+// Connect can only connect to TLS connections.
+// It will return an error if it is not a TLS-connection-type.
+func (t *TLSHost) Connect(si *ServerIdentity) (Conn, error) {
+	addr := si.Address
+	switch addr.ConnType() {
+	case TLS:
+		c, err := NewTLSConn(addr, t.conf)
+		return c, err
+	}
+	return nil, fmt.Errorf("TLSHost %s can't handle this type of connection: %s", addr, addr.ConnType())
+}
+
+// NewTLSClient returns a new client using TLS connections with the given
+// certificates.
+func NewTLSClient(conf *TLSConfig) *Client {
+	fn := func(own, remote *ServerIdentity) (Conn, error) {
+		return NewTLSConn(remote.Address, conf)
+	}
+	return newClient(fn)
+}
+
+// NewTLSAddress returns a new Address that has type TLS with the given
+// address addr.
+func NewTLSAddress(addr string) Address {
+	return NewAddress(TLS, addr)
+}
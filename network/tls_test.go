@@ -0,0 +1,172 @@
+package network
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var testTLSConfig *TLSConfig
+
+func NewTestRouterTLS(port int) (*Router, error) {
+	h, err := NewTestTLSHost(port)
+	if err != nil {
+		return nil, err
+	}
+	id := NewTestServerIdentity(h.addr)
+	return NewRouter(id, h), nil
+}
+
+func NewTestTLSHost(port int) (*TLSHost, error) {
+	if testTLSConfig == nil {
+		certPEM, keyPEM, err := newTestCertificate()
+		if err != nil {
+			return nil, err
+		}
+		testTLSConfig, err = newTestTLSConfig(certPEM, keyPEM)
+		if err != nil {
+			return nil, err
+		}
+	}
+	addr := NewTLSAddress("127.0.0.1:" + strconv.Itoa(port))
+	return NewTLSHost(addr, testTLSConfig)
+}
+
+func TestRouterTLS(t *testing.T) {
+	testRouter(t, NewTestRouterTLS)
+}
+
+func TestRouterAutoConnectionTLS(t *testing.T) {
+	testRouterAutoConnection(t, NewTestRouterTLS)
+}
+
+func TestRouterSendMsgDuplexTLS(t *testing.T) {
+	testRouterSendMsgDuplex(t, NewTestRouterTLS)
+}
+
+func TestTLSWrongCertificate(t *testing.T) {
+	h, err := NewTestTLSHost(2110)
+	require.Nil(t, err)
+	go h.Listen(func(c Conn) {})
+	defer h.Stop()
+
+	// A client with a certificate from another CA must not be able to
+	// connect, and must not hang.
+	certPEM, keyPEM, err := newTestCertificate()
+	require.Nil(t, err)
+	other, err := newTestTLSConfig(certPEM, keyPEM)
+	require.Nil(t, err)
+	done := make(chan error)
+	go func() {
+		_, err := NewTLSConn(h.Address(), other)
+		done <- err
+	}()
+	select {
+	case err := <-done:
+		assert.NotNil(t, err)
+	case <-time.After(TLSHandshakeTimeout):
+		t.Fatal("Connection with wrong certificate hangs")
+	}
+
+	_, err = NewTLSConn(NewTCPAddress("127.0.0.1:2110"), testTLSConfig)
+	assert.NotNil(t, err)
+}
+
+func TestLoadTLSConfig(t *testing.T) {
+	certPEM, keyPEM, err := newTestCertificate()
+	require.Nil(t, err)
+	dir, err := ioutil.TempDir("", "cothority-tls")
+	require.Nil(t, err)
+	defer os.RemoveAll(dir)
+	cert := path.Join(dir, "cert.pem")
+	key := path.Join(dir, "key.pem")
+	require.Nil(t, ioutil.WriteFile(cert, certPEM, 0600))
+	require.Nil(t, ioutil.WriteFile(key, keyPEM, 0600))
+
+	_, err = LoadTLSConfig(cert, key, "", false)
+	assert.NotNil(t, err)
+	_, err = LoadTLSConfig(cert, key, "", true)
+	assert.NotNil(t, err)
+	conf, err := LoadTLSConfig(cert, key, cert, true)
+	require.Nil(t, err)
+	assert.NotNil(t, conf.CAs)
+	assert.True(t, conf.VerifyClient)
+	_, err = LoadTLSConfig(cert, key, key, false)
+	assert.NotNil(t, err)
+	_, err = LoadTLSConfig(key, key, cert, false)
+	assert.NotNil(t, err)
+}
+
+func TestTLSNoCA(t *testing.T) {
+	certPEM, keyPEM, err := newTestCertificate()
+	require.Nil(t, err)
+	conf, err := newTestTLSConfig(certPEM, keyPEM)
+	require.Nil(t, err)
+	conf.CAs = nil
+	addr := NewTLSAddress("127.0.0.1:2111")
+	_, err = NewTLSHost(addr, conf)
+	assert.NotNil(t, err, "Listening without CAs should fail")
+	_, err = NewTLSConn(addr, conf)
+	assert.NotNil(t, err, "Connecting without CAs should fail")
+}
+
+// newTestTLSConfig returns a config using the given self-signed certificate
+// as CA, and verifying the clients.
+func newTestTLSConfig(certPEM, keyPEM []byte) (*TLSConfig, error) {
+	cert, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, err
+	}
+	pool := x509.NewCertPool()
+	pool.AppendCertsFromPEM(certPEM)
+	return &TLSConfig{
+		Certificate:  cert,
+		CAs:          pool,
+		VerifyClient: true,
+	}, nil
+}
+
+// newTestCertificate returns a PEM-encoded self-signed certificate for
+// 127.0.0.1 and its key.
+func newTestCertificate() ([]byte, []byte, error) {
+	priv, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{Organization: []string{"cothority"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		IPAddresses:           []net.IP{net.ParseIP("127.0.0.1")},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &priv.PublicKey, priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	keyDER, err := x509.MarshalECPrivateKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), nil
+}
@@ -0,0 +1,122 @@
+package network
+
+import (
+	"errors"
+	"fmt"
+	"net"
+)
+
+// NewUnixRouter returns a new Router using UnixHost as the underlying Host.
+func NewUnixRouter(sid *ServerIdentity) (*Router, error) {
+	h, err := NewUnixHost(sid.Address)
+	if err != nil {
+		return nil, err
+	}
+	r := NewRouter(sid, h)
+	return r, nil
+}
+
+// UnixConn implements the Conn interface using Unix domain sockets. It is
+// meant for hosts running on the same machine and uses the same framing
+// as TCPConn.
+type UnixConn struct {
+	*TCPConn
+}
+
+// NewUnixConn will open a UnixConn to the given address.
+// In case of an error it returns a nil UnixConn and the error.
+func NewUnixConn(addr Address) (*UnixConn, error) {
+	if addr.ConnType() != Unix {
+		return nil, errors.New("UnixConn can't connect to non-unix address")
+	}
+	c, err := dialConn("unix", addr)
+	if err != nil {
+		return nil, err
+	}
+	return &UnixConn{c}, nil
+}
+
+// Local returns the local address of the socket.
+func (c *UnixConn) Local() Address {
+	return NewUnixAddress(unixPath(c.conn.LocalAddr()))
+}
+
+// Type returns Unix.
+func (c *UnixConn) Type() ConnType {
+	return Unix
+}
+
+// UnixListener implements the Listener-interface using Unix domain sockets.
+type UnixListener struct {
+	*TCPListener
+}
+
+// NewUnixListener returns a UnixListener bound to the socket-path given in
+// addr. The socket-file is removed once the listener is stopped.
+func NewUnixListener(addr Address) (*UnixListener, error) {
+	if addr.ConnType() != Unix {
+		return nil, errors.New("UnixListener can't listen on non-unix address")
+	}
+	l, err := newListener("unix", addr.NetworkAddress(), Unix,
+		func(conn net.Conn) Conn {
+			return &UnixConn{&TCPConn{
+				endpoint: NewUnixAddress(unixPath(conn.RemoteAddr())),
+				conn:     conn,
+			}}
+		})
+	if err != nil {
+		return nil, err
+	}
+	return &UnixListener{l}, nil
+}
+
+// UnixHost implements the Host interface using Unix domain sockets.
+type UnixHost struct {
+	addr Address
+	*UnixListener
+}
+
+// NewUnixHost returns a new Host using Unix domain sockets.
+func NewUnixHost(addr Address) (*UnixHost, error) {
+	h := &UnixHost{
+		addr: addr,
+	}
+	var err error
+	h.UnixListener, err = NewUnixListener(addr)
+	return h, err
+}
+
+// Connect can only connect to Unix connections.
+// It will return an error if it is not a Unix-connection-type.
+func (u *UnixHost) Connect(si *ServerIdentity) (Conn, error) {
+	addr := si.Address
+	switch addr.ConnType() {
+	case Unix:
+		c, err := NewUnixConn(addr)
+		return c, err
+	}
+	return nil, fmt.Errorf("UnixHost %s can't handle this type of connection: %s", addr, addr.ConnType())
+}
+
+// NewUnixClient returns a new client using Unix domain sockets.
+func NewUnixClient() *Client {
+	fn := func(own, remote *ServerIdentity) (Conn, error) {
+		return NewUnixConn(remote.Address)
+	}
+	return newClient(fn)
+}
+
+// unixPath returns the path of a socket-address which might be nil for
+// unnamed sockets.
+func unixPath(a net.Addr) string {
+	if a == nil {
+		return ""
+	}
+	return a.String()
+}
+
+// NewUnixAddress returns a new Address that has type Unix with the given
+// socket-path.
+func NewUnixAddress(path string) Address {
+	return NewAddress(Unix, path)
+}
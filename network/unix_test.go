@@ -0,0 +1,84 @@
+package network
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strconv"
+	"testing"
+
+	"github.com/dedis/cothority/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var unixTestDir string
+
+func NewTestRouterUnix(port int) (*Router, error) {
+	h, err := NewTestUnixHost(port)
+	if err != nil {
+		return nil, err
+	}
+	id := NewTestServerIdentity(h.addr)
+	return NewRouter(id, h), nil
+}
+
+func NewTestUnixHost(port int) (*UnixHost, error) {
+	if unixTestDir == "" {
+		var err error
+		unixTestDir, err = ioutil.TempDir("", "cothority-unix")
+		if err != nil {
+			return nil, err
+		}
+	}
+	sock := path.Join(unixTestDir, strconv.Itoa(port)+".sock")
+	return NewUnixHost(NewUnixAddress(sock))
+}
+
+// removeUnixTestDir removes the sockets created by NewTestUnixHost, it is
+// deferred by every test using them.
+func removeUnixTestDir() {
+	if unixTestDir == "" {
+		return
+	}
+	if err := os.RemoveAll(unixTestDir); err != nil {
+		log.Error("Couldn't remove", unixTestDir, err)
+	}
+	unixTestDir = ""
+}
+
+func TestUnixAddress(t *testing.T) {
+	addr := Address("unix:///tmp/conode.sock")
+	assert.True(t, addr.Valid())
+	assert.Equal(t, ConnType(Unix), addr.ConnType())
+	assert.Equal(t, "/tmp/conode.sock", addr.NetworkAddress())
+	assert.False(t, addr.Public())
+	assert.False(t, Address("unix://").Valid())
+}
+
+func TestRouterUnix(t *testing.T) {
+	defer removeUnixTestDir()
+	testRouter(t, NewTestRouterUnix)
+}
+
+func TestRouterAutoConnectionUnix(t *testing.T) {
+	defer removeUnixTestDir()
+	testRouterAutoConnection(t, NewTestRouterUnix)
+}
+
+func TestRouterSendMsgDuplexUnix(t *testing.T) {
+	defer removeUnixTestDir()
+	testRouterSendMsgDuplex(t, NewTestRouterUnix)
+}
+
+func TestUnixListenerRemovesSocket(t *testing.T) {
+	defer removeUnixTestDir()
+	h, err := NewTestUnixHost(2100)
+	require.Nil(t, err)
+	sock := h.Address().NetworkAddress()
+	_, err = os.Stat(sock)
+	require.Nil(t, err)
+	require.Nil(t, h.Stop())
+	_, err = os.Stat(sock)
+	assert.True(t, os.IsNotExist(err))
+}
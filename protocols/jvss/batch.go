@@ -0,0 +1,92 @@
+package jvss
+
+import (
+	"crypto/sha512"
+	"errors"
+
+	"github.com/dedis/cothority/network"
+	"github.com/dedis/crypto/abstract"
+	"github.com/dedis/crypto/random"
+)
+
+// BatchVerify verifies many signatures on msgs against the same public key,
+// e.g. the one returned by LongtermPublic. Instead of checking
+// S*G == R + c*pub for every signature, it checks a random linear
+// combination of all equations at once, which needs only one
+// multiplication with pub. If the combination fails, the signatures are
+// verified one by one to find the invalid ones.
+// It returns the validity of every signature, or an error if msgs and sigs
+// don't have the same length. The signatures have to use network.Suite.
+func BatchVerify(pub abstract.Point, msgs [][]byte, sigs []*Sig) ([]bool, error) {
+	suite := network.Suite
+	if len(msgs) != len(sigs) {
+		return nil, errors.New("Need as many messages as signatures")
+	}
+	valid := make([]bool, len(sigs))
+	sumS := suite.Scalar().Zero()
+	sumC := suite.Scalar().Zero()
+	sumR := suite.Point().Null()
+	for i, sig := range sigs {
+		if sig == nil || sig.R == nil || sig.S == nil {
+			return verifyEach(suite, pub, msgs, sigs)
+		}
+		c, err := challenge(suite, pub, sig.R, msgs[i])
+		if err != nil {
+			return verifyEach(suite, pub, msgs, sigs)
+		}
+		z := suite.Scalar().Pick(random.Stream)
+		sumS.Add(sumS, suite.Scalar().Mul(z, sig.S))
+		sumC.Add(sumC, suite.Scalar().Mul(z, c))
+		sumR.Add(sumR, suite.Point().Mul(sig.R, z))
+	}
+	left := suite.Point().Mul(nil, sumS)
+	right := suite.Point().Add(sumR, suite.Point().Mul(pub, sumC))
+	if !left.Equal(right) {
+		return verifyEach(suite, pub, msgs, sigs)
+	}
+	for i := range valid {
+		valid[i] = true
+	}
+	return valid, nil
+}
+
+// verifyEach returns the validity of every signature checked on its own.
+func verifyEach(suite abstract.Suite, pub abstract.Point, msgs [][]byte, sigs []*Sig) ([]bool, error) {
+	valid := make([]bool, len(sigs))
+	for i, sig := range sigs {
+		valid[i] = verifySig(suite, pub, msgs[i], sig) == nil
+	}
+	return valid, nil
+}
+
+// verifySig checks S*G == R + c*pub for a single signature.
+func verifySig(suite abstract.Suite, pub abstract.Point, msg []byte, sig *Sig) error {
+	if sig == nil || sig.R == nil || sig.S == nil {
+		return errors.New("Incomplete signature")
+	}
+	c, err := challenge(suite, pub, sig.R, msg)
+	if err != nil {
+		return err
+	}
+	left := suite.Point().Mul(nil, sig.S)
+	right := suite.Point().Add(sig.R, suite.Point().Mul(pub, c))
+	if !left.Equal(right) {
+		return errors.New("Invalid signature")
+	}
+	return nil
+}
+
+// challenge returns the hash H(R||pub||msg) used by the Schnorr-signatures
+// of JVSS, which are compatible with ed25519.
+func challenge(suite abstract.Suite, pub, r abstract.Point, msg []byte) (abstract.Scalar, error) {
+	h := sha512.New()
+	for _, p := range []abstract.Point{r, pub} {
+		b, err := p.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		h.Write(b)
+	}
+	h.Write(msg)
+	return suite.Scalar().SetBytes(h.Sum(nil)), nil
+}
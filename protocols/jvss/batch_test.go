@@ -0,0 +1,78 @@
+package jvss
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/network"
+	"github.com/dedis/crypto/config"
+	"github.com/dedis/crypto/random"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchVerify(t *testing.T) {
+	kp := config.NewKeyPair(network.Suite)
+	msgs, sigs := testSigs(kp, 10)
+	valid, err := BatchVerify(kp.Public, msgs, sigs)
+	log.ErrFatal(err)
+	assert.Equal(t, []bool{true, true, true, true, true, true, true, true,
+		true, true}, valid)
+
+	// One wrong message and one missing signature
+	msgs[3] = []byte("forged")
+	sigs[7] = nil
+	valid, err = BatchVerify(kp.Public, msgs, sigs)
+	log.ErrFatal(err)
+	for i, v := range valid {
+		assert.Equal(t, i != 3 && i != 7, v, "signature %d", i)
+	}
+
+	_, err = BatchVerify(kp.Public, msgs[1:], sigs)
+	assert.NotNil(t, err)
+	valid, err = BatchVerify(kp.Public, nil, nil)
+	require.Nil(t, err)
+	assert.Equal(t, 0, len(valid))
+}
+
+func BenchmarkBatchVerify(b *testing.B) {
+	kp := config.NewKeyPair(network.Suite)
+	msgs, sigs := testSigs(kp, 1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := BatchVerify(kp.Public, msgs, sigs); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkSequentialVerify(b *testing.B) {
+	kp := config.NewKeyPair(network.Suite)
+	msgs, sigs := testSigs(kp, 1000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for j := range sigs {
+			if err := verifySig(network.Suite, kp.Public, msgs[j], sigs[j]); err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+// testSigs returns n messages with their Schnorr-signatures by kp.
+func testSigs(kp *config.KeyPair, n int) ([][]byte, []*Sig) {
+	suite := network.Suite
+	msgs := make([][]byte, n)
+	sigs := make([]*Sig, n)
+	for i := range msgs {
+		msgs[i] = []byte("message " + strconv.Itoa(i))
+		k := suite.Scalar().Pick(random.Stream)
+		r := suite.Point().Mul(nil, k)
+		c, err := challenge(suite, kp.Public, r, msgs[i])
+		log.ErrFatal(err)
+		s := suite.Scalar().Add(k, suite.Scalar().Mul(c, kp.Secret))
+		sigs[i] = &Sig{R: r, S: s}
+	}
+	return msgs, sigs
+}
@@ -9,11 +9,14 @@ import (
 )
 
 // SecInitMsg are used to initialise new shared secrets both long- and
-// short-term.
+// short-term. T is the threshold of the group and Participants holds the
+// indexes of the nodes taking part in the secret.
 type SecInitMsg struct {
-	Src  int
-	SID  SID
-	Deal []byte
+	Src          int
+	SID          SID
+	Deal         []byte
+	T            int
+	Participants []int
 }
 
 // SecConfMsg are used to confirm to other peers that we have finished setting
@@ -37,6 +40,13 @@ type SigRespMsg struct {
 	Sig *poly.SchnorrPartialSig
 }
 
+// SecDropMsg is sent by the initiator of an abandoned signature to the other
+// participants, which then drop the short-term shared secret.
+type SecDropMsg struct {
+	Src int
+	SID SID
+}
+
 // WSecInitMsg is a SDA-wrapper around SecInitMsg.
 type WSecInitMsg struct {
 	*sda.TreeNode
@@ -61,13 +71,29 @@ type WSigRespMsg struct {
 	SigRespMsg
 }
 
+// WSecDropMsg is a SDA-wrapper around SecDropMsg.
+type WSecDropMsg struct {
+	*sda.TreeNode
+	SecDropMsg
+}
+
 func (jv *JVSS) handleSecInit(m WSecInitMsg) error {
 	msg := m.SecInitMsg
 
 	log.Lvl4(jv.Name(), jv.Index(), "Received SecInit from", m.TreeNode.Name())
+	if jv.abandoned.exists(msg.SID) {
+		log.Lvl2(jv.Name(), "ignores deal for abandoned", msg.SID)
+		return nil
+	}
+
+	// The threshold is given by the root during the setup
+	if msg.SID.IsLTSS() && !jv.ltssInit && msg.T > 0 {
+		jv.info.T = msg.T
+		jv.info.R = msg.T
+	}
 
 	// Initialise shared secret
-	if err := jv.initSecret(msg.SID); err != nil {
+	if err := jv.initSecret(msg.SID, msg.Participants); err != nil {
 		return err
 	}
 
@@ -95,6 +121,10 @@ func (jv *JVSS) handleSecInit(m WSecInitMsg) error {
 
 func (jv *JVSS) handleSecConf(m WSecConfMsg) error {
 	msg := m.SecConfMsg
+	if jv.abandoned.exists(msg.SID) {
+		log.Lvl2(jv.Name(), "ignores confirmation for abandoned", msg.SID)
+		return nil
+	}
 	secret, err := jv.secrets.secret(msg.SID)
 	if err != nil {
 		log.Lvl2(jv.Index(), err, "for sid=", msg.SID)
@@ -113,11 +143,12 @@ func (jv *JVSS) handleSecConf(m WSecConfMsg) error {
 	}
 
 	// Check if we are the initiator node and have enough confirmations to proceed
-	if msg.SID.IsLTSS() && secret.numLongtermConfs == len(jv.List()) && jv.sidStore.exists(msg.SID) {
+	nbrParticipants := len(secret.participants)
+	if msg.SID.IsLTSS() && secret.numLongtermConfs == nbrParticipants && jv.sidStore.exists(msg.SID) {
 		log.Lvl4("Writing to longTermSecDone")
 		jv.longTermSecDone <- true
 		secret.numLongtermConfs = 0
-	} else if msg.SID.IsSTSS() && secret.numShortConfs == len(jv.List()) && jv.sidStore.exists(msg.SID) {
+	} else if msg.SID.IsSTSS() && secret.numShortConfs == nbrParticipants && jv.sidStore.exists(msg.SID) {
 		log.Lvl4("Writing to shortTermSecDone")
 		jv.shortTermSecDone <- true
 		secret.numShortConfs = 0
@@ -127,7 +158,7 @@ func (jv *JVSS) handleSecConf(m WSecConfMsg) error {
 			n = secret.numShortConfs
 		}
 		log.Lvl4("Node %d: %s confirmations %d/%d", jv.Index(), msg.SID,
-			n, len(jv.List()))
+			n, nbrParticipants)
 	}
 
 	return nil
@@ -135,6 +166,10 @@ func (jv *JVSS) handleSecConf(m WSecConfMsg) error {
 
 func (jv *JVSS) handleSigReq(m WSigReqMsg) error {
 	msg := m.SigReqMsg
+	if jv.abandoned.exists(msg.SID) {
+		log.Lvl2(jv.Name(), "ignores request for abandoned", msg.SID)
+		return nil
+	}
 
 	// Create partial signature
 	ps, err := jv.sigPartial(msg.SID, msg.Msg)
@@ -162,7 +197,11 @@ func (jv *JVSS) handleSigReq(m WSigReqMsg) error {
 func (jv *JVSS) handleSigResp(m WSigRespMsg) error {
 	msg := m.SigRespMsg
 
-	// Collect partial signatures
+	// Collect partial signatures, unless the signature has been abandoned
+	if !jv.sidStore.exists(msg.SID) || jv.abandoned.exists(msg.SID) {
+		log.Lvl2(jv.Name(), "ignores signature for abandoned", msg.SID)
+		return nil
+	}
 	secret, err := jv.secrets.secret(msg.SID)
 	if err != nil {
 		return err
@@ -186,7 +225,12 @@ func (jv *JVSS) handleSigResp(m WSigRespMsg) error {
 		if err != nil {
 			return err
 		}
-		jv.sigChan <- sig
+		// Don't block if the signature has been abandoned meanwhile
+		select {
+		case jv.sigChan <- sig:
+		default:
+			log.Lvl2(jv.Name(), "nobody waits for signature", msg.SID)
+		}
 
 		// Cleanup short-term shared secret
 		jv.secrets.remove(msg.SID)
@@ -194,3 +238,11 @@ func (jv *JVSS) handleSigResp(m WSigRespMsg) error {
 
 	return nil
 }
+
+func (jv *JVSS) handleSecDrop(m WSecDropMsg) error {
+	msg := m.SecDropMsg
+	log.Lvl3(jv.Name(), "drops abandoned", msg.SID)
+	jv.abandoned.insert(msg.SID)
+	jv.secrets.remove(msg.SID)
+	return nil
+}
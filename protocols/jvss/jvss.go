@@ -7,9 +7,14 @@
 // puts everything together to get the final Schnorr signature. To verify a
 // given Schnorr signature a member still has to be able to access the
 // long-term shared secret from which that particular signature was created.
+//
+// By default all members have to take part in a signature. With SetThreshold
+// a (t, n)-threshold can be set before the setup, so that a signature
+// succeeds as long as t members are reachable.
 package jvss
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/sha512"
 	"encoding/base64"
@@ -17,8 +22,10 @@ import (
 	"fmt"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/network"
 	"github.com/dedis/cothority/sda"
 	"github.com/dedis/crypto/abstract"
 	"github.com/dedis/crypto/config"
@@ -26,7 +33,7 @@ import (
 )
 
 func init() {
-	sda.ProtocolRegisterName("JVSS", NewJVSS)
+	sda.GlobalProtocolRegister("JVSS", NewJVSS)
 }
 
 // SID is the type of shared secret identifiers
@@ -42,6 +49,10 @@ const (
 // make them unique per signing requests
 const randomLength = 32
 
+// DefaultReachTimeout is how long Sign waits by default for the other members
+// to answer a ping before leaving them out of the signature.
+const DefaultReachTimeout = time.Second
+
 // JVSS is the main protocol struct and implements the sda.ProtocolInstance
 // interface.
 type JVSS struct {
@@ -53,14 +64,20 @@ type JVSS struct {
 	schnorr               *poly.Schnorr    // Long-term Schnorr struct to compute distributed signatures
 	secrets               *sharedSecrets   // Shared secrets (long- and short-term ones)
 	ltssInit              bool             // Indicator whether shared secret has been already initialised or not
+	ltssSID               SID              // SID of the long-term shared secret
 
 	longTermSecDone  chan bool // Channel to indicate when long-term shared secrets of all peers are ready
 	shortTermSecDone chan bool // Channel to indicate when short-term shared secrets of all peers are ready
 
 	sigChan chan *poly.SchnorrSig // Channel for JVSS signature
 
+	reachTimeout time.Duration // How long Sign waits for a ping answer
+
 	// keeps the set of SID this node has started/initiated
 	sidStore *sidStore
+	// keeps the set of SID of abandoned signatures, whose late messages
+	// are ignored
+	abandoned *sidStore
 }
 
 // NewJVSS creates a new JVSS protocol instance and returns it.
@@ -85,8 +102,10 @@ func NewJVSS(node *sda.TreeNodeInstance) (sda.ProtocolInstance, error) {
 		ltssInit:         false,
 		longTermSecDone:  make(chan bool, 1),
 		shortTermSecDone: make(chan bool, 1),
-		sigChan:          make(chan *poly.SchnorrSig),
+		sigChan:          make(chan *poly.SchnorrSig, 1),
+		reachTimeout:     DefaultReachTimeout,
 		sidStore:         newSidStore(),
+		abandoned:        newSidStore(),
 	}
 
 	// Setup message handlers
@@ -95,6 +114,7 @@ func NewJVSS(node *sda.TreeNodeInstance) (sda.ProtocolInstance, error) {
 		jv.handleSecConf,
 		jv.handleSigReq,
 		jv.handleSigResp,
+		jv.handleSecDrop,
 	}
 	err := jv.RegisterHandlers(h...)
 	if err != nil {
@@ -103,13 +123,51 @@ func NewJVSS(node *sda.TreeNodeInstance) (sda.ProtocolInstance, error) {
 	return jv, err
 }
 
+// SetThreshold sets the number of members needed to create a signature. It
+// has to be called on the root before Start and is passed to the other
+// members during the setup. The default is to need all members.
+func (jv *JVSS) SetThreshold(t int) error {
+	if jv.ltssInit {
+		return errors.New("Threshold has to be set before the setup")
+	}
+	if t < 1 || t > jv.info.N {
+		return fmt.Errorf("Threshold must be between 1 and %d, not %d",
+			jv.info.N, t)
+	}
+	jv.info.T = t
+	jv.info.R = t
+	return nil
+}
+
+// Threshold returns the number of members needed to create a signature.
+func (jv *JVSS) Threshold() int {
+	return jv.info.T
+}
+
+// SetReachTimeout sets how long Sign waits for the other members to answer a
+// ping before leaving them out of the signature. It is only used if the
+// threshold is smaller than the number of members.
+func (jv *JVSS) SetReachTimeout(d time.Duration) {
+	jv.reachTimeout = d
+}
+
 // Start initiates the JVSS protocol by setting up a long-term shared secret
 // which can be used later on by the JVSS group to sign and verify messages.
+// All members have to take part in the setup. If the long-term secret has
+// been imported with ImportLongterm, Start returns directly.
 func (jv *JVSS) Start() error {
 	log.Lvl2(jv.Name(), "index", jv.Index(), " Starts()")
+	if jv.ltssInit {
+		log.Lvl2(jv.Name(), "uses existing long-term secret")
+		return nil
+	}
 	sid := newSID(LTSS)
 	jv.sidStore.insert(sid)
-	err := jv.initSecret(sid)
+	all := make([]int, len(jv.List()))
+	for i := range all {
+		all[i] = i
+	}
+	err := jv.initSecret(sid, all)
 	if err != nil {
 		log.Error(err)
 		return err
@@ -135,9 +193,41 @@ func (jv *JVSS) Verify(msg []byte, sig *poly.SchnorrSig) error {
 	return jv.schnorr.VerifySchnorrSig(sig, h, msg)
 }
 
-// Sign starts a new signing request amongst the JVSS group and returns a
-// Schnorr signature on success.
+// LongtermPublic returns the public key of the long-term shared secret. This
+// is the key the signatures of the JVSS group are verified against.
+func (jv *JVSS) LongtermPublic() (abstract.Point, error) {
+	if !jv.ltssInit {
+		return nil, fmt.Errorf("Error, long-term shared secret has not been initialised")
+	}
+	secret, err := jv.secrets.secret(jv.ltssSID)
+	if err != nil {
+		return nil, err
+	}
+	return secret.secret.Pub.SecretCommit(), nil
+}
+
+// Sign starts a new signing request amongst the reachable members of the
+// JVSS group and returns a Schnorr signature on success. If less members than
+// the threshold are reachable, an error is returned.
 func (jv *JVSS) Sign(msg []byte) (*poly.SchnorrSig, error) {
+	return jv.sign(context.Background(), msg)
+}
+
+// SignContext is like Sign, but returns ctx.Err() if ctx is cancelled or
+// times out before the signature is assembled, so a hanging member can't
+// block the caller forever. The short-term shared secret of an abandoned
+// signature is removed on all participants, and its late messages are
+// ignored. The signature is returned in its canonical form.
+func (jv *JVSS) SignContext(ctx context.Context, msg []byte) (*Sig, error) {
+	sig, err := jv.sign(ctx, msg)
+	if err != nil {
+		return nil, err
+	}
+	return NewSig(sig), nil
+}
+
+// sign does the work of Sign and SignContext.
+func (jv *JVSS) sign(ctx context.Context, msg []byte) (*poly.SchnorrSig, error) {
 
 	if !jv.ltssInit {
 		return nil, fmt.Errorf("Error, long-term shared secret has not been initialised")
@@ -145,16 +235,42 @@ func (jv *JVSS) Sign(msg []byte) (*poly.SchnorrSig, error) {
 
 	log.Lvl3(jv.Name(), "index", jv.Index(), " => Sign starting")
 
-	// Initialise short-term shared secret only used for this signing request
+	participants := jv.reachable()
+	if len(participants) < jv.info.T {
+		return nil, fmt.Errorf("Only %d of %d members reachable, need %d",
+			len(participants), jv.info.N, jv.info.T)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Drop a signature of an earlier request that has been abandoned
+	// while it was sent
+	select {
+	case <-jv.sigChan:
+	default:
+	}
+
+	// Initialise short-term shared secret only used for this signing
+	// request, it is removed once the signature is done or abandoned
 	sid := newSID(STSS)
 	jv.sidStore.insert(sid)
-	if err := jv.initSecret(sid); err != nil {
+	defer func() {
+		jv.sidStore.remove(sid)
+		jv.secrets.remove(sid)
+	}()
+	if err := jv.initSecret(sid, participants); err != nil {
 		return nil, err
 	}
 
 	// Wait for setup of shared secrets to finish
 	log.Lvl2("Waiting on short-term secrets:", jv.Name())
-	<-jv.shortTermSecDone
+	select {
+	case <-jv.shortTermSecDone:
+	case <-ctx.Done():
+		jv.abandon(sid, participants, ctx.Err())
+		return nil, ctx.Err()
+	}
 	// Create partial signature ...
 	ps, err := jv.sigPartial(sid, msg)
 	if err != nil {
@@ -169,23 +285,74 @@ func (jv *JVSS) Sign(msg []byte) (*poly.SchnorrSig, error) {
 
 	secret.sigs[jv.Index()] = ps
 
-	// Broadcast signing request
+	// Send signing request to the other participants
 	req := &SigReqMsg{
 		Src: jv.Index(),
 		SID: sid,
 		Msg: msg,
 	}
-	if err := jv.Broadcast(req); err != nil {
+	if err := jv.sendParticipants(participants, req); err != nil {
 		return nil, err
 	}
 
 	// Wait for complete signature
-	sig := <-jv.sigChan
+	select {
+	case sig := <-jv.sigChan:
+		return sig, nil
+	case <-ctx.Done():
+		jv.abandon(sid, participants, ctx.Err())
+		return nil, ctx.Err()
+	}
+}
 
-	return sig, nil
+// abandon marks sid as abandoned and tells the other participants to drop
+// their short-term shared secret.
+func (jv *JVSS) abandon(sid SID, participants []int, reason error) {
+	log.Lvl2(jv.Name(), "abandons signature:", reason)
+	jv.abandoned.insert(sid)
+	drop := &SecDropMsg{
+		Src: jv.Index(),
+		SID: sid,
+	}
+	if err := jv.sendParticipants(participants, drop); err != nil {
+		log.Error(jv.Name(), "couldn't drop", sid, ":", err)
+	}
+}
+
+// reachable returns the indexes of the members that answer a ping. If all
+// members are needed anyway, they are returned without pinging them.
+func (jv *JVSS) reachable() []int {
+	down := make(map[network.ServerIdentityID]bool)
+	if jv.info.T < len(jv.List()) {
+		for _, si := range jv.Tree().CheckReachable(jv.Host(), jv.reachTimeout) {
+			down[si.ID] = true
+		}
+	}
+	var participants []int
+	for i, tn := range jv.List() {
+		if !down[tn.ServerIdentity.ID] {
+			participants = append(participants, i)
+		}
+	}
+	return participants
+}
+
+// sendParticipants sends msg to all participants but ourselves.
+func (jv *JVSS) sendParticipants(participants []int, msg interface{}) error {
+	for _, i := range participants {
+		if i == jv.Index() {
+			continue
+		}
+		if err := jv.SendTo(jv.List()[i], msg); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (jv *JVSS) initSecret(sid SID) error {
+// initSecret sets up the shared secret sid between the given participants,
+// which are indexes in jv.List().
+func (jv *JVSS) initSecret(sid SID, participants []int) error {
 	if sid.IsLTSS() && jv.ltssInit {
 		return errors.New("Only one longterm secret allowed per JVSS instance")
 	}
@@ -198,6 +365,7 @@ func (jv *JVSS) initSecret(sid SID) error {
 			receiver:         poly.NewReceiver(jv.keyPair.Suite, jv.info, jv.keyPair),
 			deals:            make(map[int]*poly.Deal),
 			sigs:             make(map[int]*poly.SchnorrPartialSig),
+			participants:     participants,
 			numLongtermConfs: 0,
 		}
 		jv.secrets.addSecret(sid, sec)
@@ -216,11 +384,13 @@ func (jv *JVSS) initSecret(sid SID) error {
 		secret.deals[jv.Index()] = deal
 		db, _ := deal.MarshalBinary()
 		msg := &SecInitMsg{
-			Src:  jv.Index(),
-			SID:  sid,
-			Deal: db,
+			Src:          jv.Index(),
+			SID:          sid,
+			Deal:         db,
+			T:            jv.info.T,
+			Participants: secret.participants,
 		}
-		if err := jv.Broadcast(msg); err != nil {
+		if err := jv.sendParticipants(secret.participants, msg); err != nil {
 			return err
 		}
 	}
@@ -234,9 +404,9 @@ func (jv *JVSS) finaliseSecret(sid SID) error {
 	}
 
 	log.Lvlf4("Node %d: %s deals %d/%d", jv.Index(), sid, len(secret.deals),
-		len(jv.List()))
+		len(secret.participants))
 
-	if len(secret.deals) == jv.info.T {
+	if len(secret.deals) == len(secret.participants) {
 
 		for _, deal := range secret.deals {
 			if _, err := secret.receiver.AddDeal(jv.Index(), deal); err != nil {
@@ -265,17 +435,19 @@ func (jv *JVSS) finaliseSecret(sid SID) error {
 		// Initialise Schnorr struct for long-term shared secret if not done so before
 		if sid.IsLTSS() && !jv.ltssInit {
 			jv.ltssInit = true
+			jv.ltssSID = sid
 			jv.schnorr.Init(jv.keyPair.Suite, jv.info, secret.secret)
 			log.Lvlf4("Node %d: %v Schnorr struct initialised",
 				jv.Index(), sid)
 		}
 
-		// Broadcast that we have finished setting up our shared secret
+		// Tell the participants that we have finished setting up our shared
+		// secret
 		msg := &SecConfMsg{
 			Src: jv.Index(),
 			SID: sid,
 		}
-		if err := jv.Broadcast(msg); err != nil {
+		if err := jv.sendParticipants(secret.participants, msg); err != nil {
 			return err
 		}
 	}
@@ -344,6 +516,8 @@ type secret struct {
 	deals map[int]*poly.Deal // Buffer for deals
 	// XXX potentially get rid of sig buffer later:
 	sigs map[int]*poly.SchnorrPartialSig // Buffer for partial signatures
+	// Indexes of the nodes taking part in this secret
+	participants []int
 
 	// Number of collected confirmations that shared secrets are ready
 	numLongtermConfs int
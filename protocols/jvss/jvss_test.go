@@ -1,11 +1,14 @@
 package jvss
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/dedis/cothority/log"
 	"github.com/dedis/cothority/sda"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestMain(m *testing.M) {
@@ -24,6 +27,110 @@ func TestSID(t *testing.T) {
 	assert.False(t, ss.IsLTSS())
 }
 
+func TestJVSSLongtermPublic(t *testing.T) {
+	local := sda.NewLocalTest()
+	_, _, tree := local.GenTree(5, true)
+	defer local.CloseAll()
+
+	leader, err := local.CreateProtocol("JVSS", tree)
+	if err != nil {
+		t.Fatal("Couldn't initialise protocol tree:", err)
+	}
+	jv := leader.(*JVSS)
+	_, err = jv.LongtermPublic()
+	assert.NotNil(t, err)
+	leader.Start()
+
+	pub, err := jv.LongtermPublic()
+	if err != nil {
+		t.Fatal("Couldn't get longterm public key:", err)
+	}
+	sec, err := jv.secrets.secret(jv.ltssSID)
+	if err != nil {
+		t.Fatal("Couldn't get longterm secret:", err)
+	}
+	assert.True(t, pub.Equal(sec.secret.Pub.SecretCommit()))
+
+	msg := []byte("Hello world")
+	sig, err := jv.Sign(msg)
+	if err != nil {
+		t.Fatal("Error signature failed", err)
+	}
+	assert.Nil(t, jv.Verify(msg, sig))
+	valid, err := BatchVerify(pub, [][]byte{msg}, []*Sig{NewSig(sig)})
+	log.ErrFatal(err)
+	assert.Equal(t, []bool{true}, valid)
+}
+
+func TestSigMarshalBinary(t *testing.T) {
+	local := sda.NewLocalTest()
+	_, _, tree := local.GenTree(5, true)
+	defer local.CloseAll()
+
+	leader, err := local.CreateProtocol("JVSS", tree)
+	if err != nil {
+		t.Fatal("Couldn't initialise protocol tree:", err)
+	}
+	jv := leader.(*JVSS)
+	leader.Start()
+
+	msg := []byte("Hello world")
+	sig, err := jv.Sign(msg)
+	if err != nil {
+		t.Fatal("Error signature failed", err)
+	}
+	buf, err := NewSig(sig).MarshalBinary()
+	if err != nil {
+		t.Fatal("Couldn't marshal signature:", err)
+	}
+	assert.Equal(t, 64, len(buf))
+
+	s := NewEmptySig(jv.Suite())
+	assert.Nil(t, s.UnmarshalBinary(buf))
+	assert.True(t, s.R.Equal(sig.Random.SecretCommit()))
+	assert.True(t, s.S.Equal(*sig.Signature))
+	assert.NotNil(t, s.UnmarshalBinary(buf[1:]))
+
+	assert.Nil(t, jv.VerifyBytes(msg, buf))
+	assert.NotNil(t, jv.VerifyBytes([]byte("Hello moon"), buf))
+	buf[40] ^= 1
+	assert.NotNil(t, jv.VerifyBytes(msg, buf))
+}
+
+func TestJVSSThreshold(t *testing.T) {
+	local := sda.NewLocalTest()
+	conodes, _, tree := local.GenTree(5, true)
+	defer local.CloseAll()
+
+	leader, err := local.CreateProtocol("JVSS", tree)
+	if err != nil {
+		t.Fatal("Couldn't initialise protocol tree:", err)
+	}
+	jv := leader.(*JVSS)
+	assert.NotNil(t, jv.SetThreshold(0))
+	assert.NotNil(t, jv.SetThreshold(6))
+	assert.Nil(t, jv.SetThreshold(3))
+	assert.Equal(t, 3, jv.Threshold())
+	leader.Start()
+	assert.NotNil(t, jv.SetThreshold(4))
+	jv.SetReachTimeout(200 * time.Millisecond)
+
+	// two members are offline
+	msg := []byte("Hello world")
+	log.ErrFatal(conodes[3].Close())
+	log.ErrFatal(conodes[4].Close())
+	sig, err := jv.Sign(msg)
+	if err != nil {
+		t.Fatal("Signature with 3 of 5 members failed:", err)
+	}
+	assert.Nil(t, jv.Verify(msg, sig))
+
+	// three members are offline
+	log.ErrFatal(conodes[2].Close())
+	_, err = jv.Sign(msg)
+	assert.NotNil(t, err)
+}
+
 func TestJVSS(t *testing.T) {
 	// Setup parameters
 	var name string = "JVSS" // Protocol name
@@ -33,7 +140,7 @@ func TestJVSS(t *testing.T) {
 	msg := []byte("Hello world")
 
 	local := sda.NewLocalTest()
-	_, _, tree := local.GenTree(int(nodes), false, true, true)
+	_, _, tree := local.GenTree(int(nodes), true)
 	defer local.CloseAll()
 
 	log.Lvl1("JVSS - starting")
@@ -60,3 +167,70 @@ func TestJVSS(t *testing.T) {
 		log.Lvl1("JVSS - signature verification succeded")
 	}
 }
+
+func TestJVSSSignContext(t *testing.T) {
+	local := sda.NewLocalTest()
+	_, _, tree := local.GenTree(5, true)
+	defer local.CloseAll()
+
+	leader, err := local.CreateProtocol("JVSS", tree)
+	if err != nil {
+		t.Fatal("Couldn't initialise protocol tree:", err)
+	}
+	jv := leader.(*JVSS)
+	leader.Start()
+
+	msg := []byte("Hello world")
+	sig, err := jv.SignContext(context.Background(), msg)
+	log.ErrFatal(err)
+	buf, err := sig.MarshalBinary()
+	log.ErrFatal(err)
+	assert.Nil(t, jv.VerifyBytes(msg, buf))
+
+	// a member hangs while setting up the short-term secret
+	child := local.GetNodes(tree.List()[1])[0].ProtocolInstance().(*JVSS)
+	child.secrets.Lock()
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		time.Sleep(100 * time.Millisecond)
+		cancel()
+	}()
+	_, err = jv.SignContext(ctx, msg)
+	child.secrets.Unlock()
+	assert.Equal(t, context.Canceled, err)
+	jv.abandoned.mutex.Lock()
+	require.Equal(t, 1, len(jv.abandoned.store))
+	var abandoned SID
+	for sid := range jv.abandoned.store {
+		abandoned = sid
+	}
+	jv.abandoned.mutex.Unlock()
+
+	// The delayed child sends its deal to the others before it handles the
+	// request to drop the secret.
+	for i := 0; !child.abandoned.exists(abandoned); i++ {
+		require.True(t, i < 500, "Child didn't drop the abandoned secret")
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	_, err = jv.SignContext(ctx, msg)
+	assert.Equal(t, context.Canceled, err)
+	// The messages of the child arrive in order, so its late deal has been
+	// handled once the next signature is done.
+	sig, err = jv.SignContext(context.Background(), msg)
+	log.ErrFatal(err)
+	buf, err = sig.MarshalBinary()
+	log.ErrFatal(err)
+	assert.Nil(t, jv.VerifyBytes(msg, buf))
+
+	jv.sidStore.mutex.Lock()
+	for sid := range jv.sidStore.store {
+		assert.False(t, sid.IsSTSS(), "STSS left in sidStore")
+	}
+	jv.sidStore.mutex.Unlock()
+	for _, tn := range tree.List() {
+		n := local.GetNodes(tn)[0].ProtocolInstance().(*JVSS)
+		_, err := n.secrets.secret(abandoned)
+		assert.NotNil(t, err, "Abandoned secret left on", tn.Name())
+	}
+}
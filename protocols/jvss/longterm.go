@@ -0,0 +1,143 @@
+package jvss
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+
+	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/network"
+	"github.com/dedis/crypto/abstract"
+	"github.com/sriak/crypto/poly"
+)
+
+func init() {
+	network.RegisterPacketType(&longterm{})
+}
+
+// longterm is the share of a node in the long-term shared secret, as
+// returned by ExportLongterm.
+type longterm struct {
+	SID SID
+	// Public key of the node holding the share
+	Public abstract.Point
+	// Group identifies the members of the group and their order, see
+	// groupID
+	Group []byte
+	T     int
+	N     int
+	Index int
+	Share abstract.Scalar
+	// Marshalled public polynomial of the shared secret
+	Pub []byte
+}
+
+// ExportLongterm returns the share of this node in the long-term shared
+// secret. It is secret and has to be stored safely. A relaunched node can
+// pass it to ImportLongterm instead of setting up a new long-term secret.
+func (jv *JVSS) ExportLongterm() ([]byte, error) {
+	if !jv.ltssInit {
+		return nil, errors.New("Error, long-term shared secret has not been initialised")
+	}
+	sec, err := jv.secrets.secret(jv.ltssSID)
+	if err != nil {
+		return nil, err
+	}
+	pub, err := sec.secret.Pub.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	group, err := jv.groupID()
+	if err != nil {
+		return nil, err
+	}
+	return network.MarshalRegisteredType(&longterm{
+		SID:    jv.ltssSID,
+		Public: jv.Public(),
+		Group:  group,
+		T:      jv.info.T,
+		N:      jv.info.N,
+		Index:  sec.secret.Index,
+		Share:  *sec.secret.Share,
+		Pub:    pub,
+	})
+}
+
+// ImportLongterm sets up the long-term shared secret of jv with a share
+// returned by ExportLongterm, so Start returns without a new setup. The share
+// has to be exported by the same node in a group with the same members in the
+// same order. It has to be called before Start on the root, and by the
+// creator of the protocol instance on the other members, before it handles
+// any message, e.g. in the NewProtocol method of a service.
+func (jv *JVSS) ImportLongterm(buf []byte) error {
+	if jv.ltssInit {
+		return errors.New("Long-term shared secret is already set up")
+	}
+	_, msg, err := network.UnmarshalRegistered(buf)
+	if err != nil {
+		return err
+	}
+	lt, ok := msg.(*longterm)
+	if !ok {
+		return errors.New("Didn't get a long-term secret")
+	}
+	if !lt.SID.IsLTSS() {
+		return fmt.Errorf("%s is not a long-term secret", lt.SID)
+	}
+	if !lt.Public.Equal(jv.Public()) {
+		return errors.New("Long-term secret has been exported by another node")
+	}
+	group, err := jv.groupID()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(lt.Group, group) || lt.N != jv.info.N ||
+		lt.Index != jv.Index() {
+		return errors.New("Long-term secret belongs to another group")
+	}
+	pub := new(poly.PubPoly).Init(jv.keyPair.Suite, lt.T, nil)
+	if err := pub.UnmarshalBinary(lt.Pub); err != nil {
+		return err
+	}
+	if !pub.Check(lt.Index, lt.Share) {
+		return errors.New("Share doesn't match the long-term public polynomial")
+	}
+	share := jv.keyPair.Suite.Scalar().Set(lt.Share)
+	all := make([]int, lt.N)
+	for i := range all {
+		all[i] = i
+	}
+	sec := &secret{
+		secret: &poly.SharedSecret{
+			Index: lt.Index,
+			Share: &share,
+			Pub:   pub,
+		},
+		deals:        make(map[int]*poly.Deal),
+		sigs:         make(map[int]*poly.SchnorrPartialSig),
+		participants: all,
+	}
+	jv.secrets.addSecret(lt.SID, sec)
+	jv.info.T = lt.T
+	jv.info.R = lt.T
+	jv.ltssInit = true
+	jv.ltssSID = lt.SID
+	jv.schnorr.Init(jv.keyPair.Suite, jv.info, sec.secret)
+	log.Lvl3(jv.Name(), "uses imported long-term secret", lt.SID)
+	return nil
+}
+
+// groupID returns the hash of the public keys of all members, in the order
+// of the tree.
+func (jv *JVSS) groupID() ([]byte, error) {
+	h := sha256.New()
+	for _, p := range jv.pubKeys {
+		b, err := p.MarshalBinary()
+		if err != nil {
+			return nil, err
+		}
+		h.Write(b)
+	}
+	return h.Sum(nil), nil
+}
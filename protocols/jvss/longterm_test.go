@@ -0,0 +1,103 @@
+package jvss
+
+import (
+	"testing"
+
+	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/network"
+	"github.com/dedis/cothority/sda"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func init() {
+	sda.GlobalProtocolRegister("JVSSImport", newImportJVSS)
+}
+
+// importShares holds the exported shares imported by newImportJVSS, indexed
+// by the index of the node.
+var importShares map[int][]byte
+
+// newImportJVSS imports the share of the node like a service would do when
+// creating the protocol instance.
+func newImportJVSS(n *sda.TreeNodeInstance) (sda.ProtocolInstance, error) {
+	pi, err := NewJVSS(n)
+	if err != nil {
+		return nil, err
+	}
+	jv := pi.(*JVSS)
+	if buf, ok := importShares[n.Index()]; ok {
+		if err := jv.ImportLongterm(buf); err != nil {
+			return nil, err
+		}
+	}
+	return jv, nil
+}
+
+func TestJVSSImportLongterm(t *testing.T) {
+	local := sda.NewLocalTest()
+	_, _, tree := local.GenTree(5, true)
+	defer local.CloseAll()
+	defer func() { importShares = nil }()
+
+	leader, err := local.CreateProtocol("JVSS", tree)
+	require.Nil(t, err)
+	jv := leader.(*JVSS)
+	_, err = jv.ExportLongterm()
+	assert.NotNil(t, err)
+	log.ErrFatal(leader.Start())
+	pub, err := jv.LongtermPublic()
+	log.ErrFatal(err)
+
+	exports := make(map[int][]byte)
+	for _, tn := range tree.List() {
+		nodes := local.GetNodes(tn)
+		require.Equal(t, 1, len(nodes))
+		buf, err := nodes[0].ProtocolInstance().(*JVSS).ExportLongterm()
+		log.ErrFatal(err)
+		exports[tn.RosterIndex] = buf
+	}
+	assert.NotNil(t, jv.ImportLongterm(exports[0]), "Secret is already set up")
+
+	// Only the share of the same node in the same group is accepted.
+	leader, err = local.CreateProtocol("JVSS", tree)
+	require.Nil(t, err)
+	jv = leader.(*JVSS)
+	assert.NotNil(t, jv.ImportLongterm([]byte{1, 2, 3}))
+	assert.NotNil(t, jv.ImportLongterm(exports[1]), "Share of another node")
+	tamper := func(f func(lt *longterm)) []byte {
+		_, msg, err := network.UnmarshalRegistered(exports[0])
+		log.ErrFatal(err)
+		lt := msg.(*longterm)
+		f(lt)
+		buf, err := network.MarshalRegisteredType(lt)
+		log.ErrFatal(err)
+		return buf
+	}
+	assert.NotNil(t, jv.ImportLongterm(tamper(func(lt *longterm) {
+		lt.Group = []byte("another group")
+	})), "Share of another group")
+	assert.NotNil(t, jv.ImportLongterm(tamper(func(lt *longterm) {
+		lt.Share = network.Suite.Scalar().Add(lt.Share,
+			network.Suite.Scalar().One())
+	})), "Share not matching the public polynomial")
+	assert.False(t, jv.ltssInit)
+
+	// All members import their share when their instance is created.
+	importShares = exports
+	leader, err = local.CreateProtocol("JVSSImport", tree)
+	require.Nil(t, err)
+	jv = leader.(*JVSS)
+	log.ErrFatal(leader.Start())
+	pub2, err := jv.LongtermPublic()
+	log.ErrFatal(err)
+	assert.True(t, pub.Equal(pub2))
+
+	msg := []byte("Hello world")
+	sig, err := jv.Sign(msg)
+	log.ErrFatal(err)
+	assert.Nil(t, jv.Verify(msg, sig))
+	valid, err := BatchVerify(pub, [][]byte{msg}, []*Sig{NewSig(sig)})
+	log.ErrFatal(err)
+	assert.Equal(t, []bool{true}, valid)
+}
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"crypto/sha1"
 	"crypto/sha256"
+	"crypto/sha512"
 	"hash"
 	"io"
 	"time"
+
+	"golang.org/x/crypto/openpgp/armor"
 )
 
 const PubKeyAlgoEDDSA = 22
@@ -14,12 +17,53 @@ const maxOIDLength = 9
 const packetTypeUserID = 13
 const packetTypePublicKey = 6
 const packetTypeSignature = 2
+const sigTypePositiveCert = 0x13
+const subpacketCreationTime = 2
+const subpacketKeyExpiration = 9
+
+// Hash algorithms cf. RFC 4880 section 9.4
+const HashAlgoSHA256 = 8
+const HashAlgoSHA512 = 10
 
 // Taken from https://tools.ietf.org/html/draft-ietf-openpgp-rfc4880bis-00#section-9.2
 var oid = []byte{0x2B, 0x06, 0x01, 0x04, 0x01, 0xDA, 0x47, 0x0F, 0x01}
 
+// HashConfig defines how the message to be signed is hashed.
+type HashConfig struct {
+	// New returns the hash to use
+	New func() hash.Hash
+	// Algo is the ID of the hash in the signature-packet, e.g.
+	// HashAlgoSHA512
+	Algo byte
+	// Prefix is hashed before the message for domain separation. OpenPGP
+	// verifiers only accept signatures without a prefix.
+	Prefix []byte
+}
+
+// DefaultHashConfig uses SHA-256 without a prefix, as HashMessage and
+// SerializeSignature do.
+var DefaultHashConfig = HashConfig{New: sha256.New, Algo: HashAlgoSHA256}
+
+// SHA512HashConfig uses SHA-512 without a prefix, for verifiers expecting
+// SHA-512 signatures.
+var SHA512HashConfig = HashConfig{New: sha512.New, Algo: HashAlgoSHA512}
+
+// HashMessage creates the hash of the message to be signed using the hash
+// and the prefix of hc.
+func (hc HashConfig) HashMessage(msg []byte) []byte {
+	h := hc.New()
+	h.Write(hc.Prefix)
+	return hashMessage(h, hc.Algo, msg)
+}
+
 // Creates the hash of the message to be signed cf. 4880 section 5.2.4
 func HashMessage(h hash.Hash, msg []byte) []byte {
+	return hashMessage(h, HashAlgoSHA256, msg)
+}
+
+// hashMessage is like HashMessage, but with algo as the hash algorithm in the
+// trailer.
+func hashMessage(h hash.Hash, algo byte, msg []byte) []byte {
 	h.Write(msg)
 	var buf []byte
 	buf = append(buf, byte(4))
@@ -27,8 +71,8 @@ func HashMessage(h hash.Hash, msg []byte) []byte {
 	buf = append(buf, byte(0))
 	// pub key algo
 	buf = append(buf, byte(PubKeyAlgoEDDSA))
-	// hash algo sha256
-	buf = append(buf, byte(8))
+	// hash algo
+	buf = append(buf, algo)
 
 	// scalar octect count for hashed subpacket data
 	hashSubacketLength := 0
@@ -50,7 +94,13 @@ func HashMessage(h hash.Hash, msg []byte) []byte {
 
 // Serializes into the given writer the signature from the pubkey, the input
 // data and the point R and the integer S cf. RFC 4880 section 5.2
-func SerializeSignature(w io.Writer, data, pubKey, r, s []byte) (err error) {
+func SerializeSignature(w io.Writer, data, pubKey, r, s []byte) error {
+	return SerializeSignatureHash(w, DefaultHashConfig, data, pubKey, r, s)
+}
+
+// SerializeSignatureHash is like SerializeSignature, but for a signature
+// on the data hashed with hc.
+func SerializeSignatureHash(w io.Writer, hc HashConfig, data, pubKey, r, s []byte) (err error) {
 	// We prepend the pubKey with 0x40 to indicate that it is compressed cf.
 	// https://tools.ietf.org/html/draft-ietf-openpgp-rfc4880bis-00#section-13.3
 	pubKey = append([]byte{0x40}, pubKey...)
@@ -58,11 +108,9 @@ func SerializeSignature(w io.Writer, data, pubKey, r, s []byte) (err error) {
 	// Get the key id cf. https://tools.ietf.org/html/rfc4880#section-12.2
 	keyID := keyID(pubKey)
 
-	// Header length
-	length := 2
-
-	dataSig := signaturePacket(data, r, s, keyID)
-	length += len(dataSig)
+	dataSig := signaturePacket(hc, data, r, s, keyID)
+	// the length of a packet doesn't include its header
+	length := len(dataSig)
 
 	err = serializeHeader(w, packetTypeSignature, length)
 	if err != nil {
@@ -78,7 +126,7 @@ func SerializePubKey(w io.Writer, pubKey []byte, userID string) (err error) {
 	// We prepend the pubKey with 0x40 to indicate that it is compressed cf.
 	// https://tools.ietf.org/html/draft-ietf-openpgp-rfc4880bis-00#section-13.3
 	pubKey = append([]byte{0x40}, pubKey...)
-	// Packet header (1 octet of size since size < 192)
+	// MPI bit length
 	length := 2
 	// Version number = 4
 	length += 1
@@ -106,14 +154,132 @@ func SerializePubKey(w io.Writer, pubKey []byte, userID string) (err error) {
 
 }
 
+// SerializePubKeyArmored is like SerializePubKey, but writes an ASCII-armored
+// public key block cf. RFC 4880 section 6.2
+func SerializePubKeyArmored(w io.Writer, pubKey []byte, userID string) error {
+	return writeArmored(w, "PGP PUBLIC KEY BLOCK", func(aw io.Writer) error {
+		return SerializePubKey(aw, pubKey, userID)
+	})
+}
+
+// SerializeSignatureArmored is like SerializeSignature, but writes an
+// ASCII-armored signature cf. RFC 4880 section 6.2
+func SerializeSignatureArmored(w io.Writer, data, pubKey, r, s []byte) error {
+	return writeArmored(w, "PGP SIGNATURE", func(aw io.Writer) error {
+		return SerializeSignature(aw, data, pubKey, r, s)
+	})
+}
+
+// writeArmored wraps the output of serialize in an armor of the given type,
+// including the CRC-24 checksum.
+func writeArmored(w io.Writer, blockType string, serialize func(io.Writer) error) error {
+	aw, err := armor.Encode(w, blockType, nil)
+	if err != nil {
+		return err
+	}
+	if err := serialize(aw); err != nil {
+		return err
+	}
+	if err := aw.Close(); err != nil {
+		return err
+	}
+	// armor.Encode doesn't end the last line
+	_, err = w.Write([]byte("\n"))
+	return err
+}
+
+// CertificationHash returns the hash to be signed by the key itself to
+// certify that userID belongs to pubKey, cf. RFC 4880 section 5.2.4. The
+// key is created at created, and expires at expiry, if it is not zero.
+// The hash has to be signed by the JVSS-group and given to
+// SerializeCertifiedPubKey with the same hc.
+func CertificationHash(hc HashConfig, pubKey []byte, userID string, created, expiry time.Time) []byte {
+	h := hc.New()
+	h.Write(hc.Prefix)
+	pubKey = append([]byte{0x40}, pubKey...)
+	body := bytes.NewBuffer(nil)
+	serializePubKeyBody(body, pubKey, created)
+	h.Write([]byte{0x99, byte(body.Len() >> 8), byte(body.Len())})
+	h.Write(body.Bytes())
+	l := len(userID)
+	h.Write([]byte{0xb4, byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l)})
+	h.Write([]byte(userID))
+	hashed := certificationHashedPart(hc.Algo, created, expiry)
+	h.Write(hashed)
+	l = len(hashed)
+	h.Write([]byte{0x04, 0xff, byte(l >> 24), byte(l >> 16), byte(l >> 8), byte(l)})
+	return h.Sum(nil)
+}
+
+// SerializeCertifiedPubKey serializes the public key and the user id like
+// SerializePubKey, followed by the positive self-certification of the user
+// id cf. RFC 4880 section 5.2.1. r and s are the signature of the hash
+// returned by CertificationHash with the same arguments. Contrary to the
+// bare key, such a key is accepted by GnuPG.
+func SerializeCertifiedPubKey(w io.Writer, hc HashConfig, pubKey []byte, userID string, created, expiry time.Time, r, s []byte) error {
+	hash := CertificationHash(hc, pubKey, userID, created, expiry)
+	pubKey = append([]byte{0x40}, pubKey...)
+	body := bytes.NewBuffer(nil)
+	serializePubKeyBody(body, pubKey, created)
+	if err := serializeHeader(w, packetTypePublicKey, body.Len()); err != nil {
+		return err
+	}
+	if _, err := w.Write(body.Bytes()); err != nil {
+		return err
+	}
+	if err := serializeUserID(w, userID); err != nil {
+		return err
+	}
+
+	sig := certificationHashedPart(hc.Algo, created, expiry)
+	// unhashed subpacket with the issuer key ID
+	sig = append(sig, 0, 10, 9, 16)
+	sig = append(sig, keyIDAt(pubKey, created)...)
+	sig = append(sig, hash[:2]...)
+	sig = appendMPI(sig, r)
+	sig = appendMPI(sig, s)
+	if err := serializeHeader(w, packetTypeSignature, len(sig)); err != nil {
+		return err
+	}
+	_, err := w.Write(sig)
+	return err
+}
+
+// certificationHashedPart returns the hashed part of a positive
+// certification using the hash algo, including the creation time and the
+// expiry of the key.
+func certificationHashedPart(algo byte, created, expiry time.Time) []byte {
+	var sub []byte
+	sub = append(sub, 5, subpacketCreationTime)
+	sub = appendUint32(sub, uint32(created.Unix()))
+	if !expiry.IsZero() {
+		// the expiration is given in seconds after the creation
+		sub = append(sub, 5, subpacketKeyExpiration)
+		sub = appendUint32(sub, uint32(expiry.Sub(created).Seconds()))
+	}
+	buf := []byte{4, sigTypePositiveCert, PubKeyAlgoEDDSA, algo}
+	buf = append(buf, byte(len(sub)>>8), byte(len(sub)))
+	return append(buf, sub...)
+}
+
+func appendUint32(buf []byte, i uint32) []byte {
+	return append(buf, byte(i>>24), byte(i>>16), byte(i>>8), byte(i))
+}
+
+func appendMPI(buf, mpi []byte) []byte {
+	length := uint16(8 * len(mpi))
+	buf = append(buf, byte(length>>8), byte(length))
+	return append(buf, mpi...)
+}
+
 // Writes an user id. cf. RFC 4880 section 5.11
 func serializeUserID(w io.Writer, userId string) (err error) {
 	bytesId := []byte(userId)
-	// Packet header + size
-	length := 2
-	// userId lendth
-	length += len(bytesId)
-	serializeHeader(w, packetTypeUserID, length)
+	// the length of a packet doesn't include its header
+	length := len(bytesId)
+	if err = serializeHeader(w, packetTypeUserID, length); err != nil {
+		return
+	}
 	_, err = w.Write(bytesId)
 	return
 }
@@ -146,11 +312,17 @@ func serializeHeader(w io.Writer, ptype int, length int) (err error) {
 }
 
 func serializePubKeyWithoutHeader(w io.Writer, pubKey []byte) (err error) {
+	return serializePubKeyBody(w, pubKey, time.Now())
+}
+
+// serializePubKeyBody writes the public key packet without header for a key
+// created at the given time.
+func serializePubKeyBody(w io.Writer, pubKey []byte, created time.Time) (err error) {
 	var buf []byte
 	// Version number 4
 	buf = append(buf, byte(4))
 
-	t := uint32(time.Now().Unix())
+	t := uint32(created.Unix())
 	buf = append(buf, byte(t>>24))
 	buf = append(buf, byte(t>>16))
 	buf = append(buf, byte(t>>8))
@@ -175,7 +347,7 @@ func serializePubKeyWithoutHeader(w io.Writer, pubKey []byte) (err error) {
 	return
 }
 
-func signaturePacket(data, r, s, keyID []byte) (sig []byte) {
+func signaturePacket(hc HashConfig, data, r, s, keyID []byte) (sig []byte) {
 	var buf []byte
 	// Version 4
 	buf = append(buf, byte(4))
@@ -183,17 +355,15 @@ func signaturePacket(data, r, s, keyID []byte) (sig []byte) {
 	buf = append(buf, byte(0))
 	// pub key algo
 	buf = append(buf, byte(PubKeyAlgoEDDSA))
-	// hash algo sha256
-	buf = append(buf, byte(8))
+	// hash algo
+	buf = append(buf, hc.Algo)
 
 	// scalar octect count for hashed subpacket data
 	hashSubpacketLength := 0
 	buf = append(buf, byte(hashSubpacketLength>>8))
 	buf = append(buf, byte(hashSubpacketLength))
 
-	hasher := sha256.New()
-
-	signedHashValue := HashMessage(hasher, data)
+	signedHashValue := hc.HashMessage(data)
 
 	// scalar octet count for unashed subpacket data
 	buf = append(buf, byte(0))
@@ -221,11 +391,16 @@ func signaturePacket(data, r, s, keyID []byte) (sig []byte) {
 
 // Gets the ID of the given public key cf. RFC 4880 section 12.2
 func keyID(pubKey []byte) (id []byte) {
+	return keyIDAt(pubKey, time.Now())
+}
+
+// keyIDAt returns the ID of the given public key created at the given time.
+func keyIDAt(pubKey []byte, created time.Time) []byte {
 	serializeBuf := bytes.NewBuffer(nil)
-	serializePubKeyWithoutHeader(serializeBuf, pubKey)
+	serializePubKeyBody(serializeBuf, pubKey, created)
 	length := len(serializeBuf.Bytes())
 	fingerPrint := sha1.New()
 	fingerPrint.Write([]byte{0x99, byte(length >> 8), byte(length)})
-	serializePubKeyWithoutHeader(fingerPrint, pubKey)
+	fingerPrint.Write(serializeBuf.Bytes())
 	return fingerPrint.Sum(nil)[12:20]
 }
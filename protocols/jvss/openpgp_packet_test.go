@@ -2,14 +2,18 @@ package jvss
 
 import (
 	"bytes"
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"io/ioutil"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/dedis/cothority/log"
 	"github.com/dedis/cothority/sda"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp/armor"
 )
 
 var pubKey, _ = hex.DecodeString("d2a4f14e5d960f25117b36fb566254ab6a0371369de59e0b57bbbb62d6205cd8")
@@ -49,6 +53,126 @@ func TestSignature(t *testing.T) {
 	log.Lvl1("Wrote text file")
 }
 
+func TestCertifiedPubKey(t *testing.T) {
+	created := time.Unix(1480000000, 0)
+	expiry := created.Add(365 * 24 * time.Hour)
+	hash := CertificationHash(DefaultHashConfig, pubKey, "raph@raph.com", created, expiry)
+	assert.NotEqual(t, hash, CertificationHash(DefaultHashConfig, pubKey,
+		"raph@raph.com", created, time.Time{}))
+
+	buffer := bytes.NewBuffer(nil)
+	err := SerializeCertifiedPubKey(buffer, DefaultHashConfig, pubKey,
+		"raph@raph.com", created, expiry, R, S)
+	if err != nil {
+		t.Fatal("Couldn't serialize public key:", err)
+	}
+	var types []int
+	var bodies [][]byte
+	buf := buffer.Bytes()
+	for len(buf) > 0 {
+		tag, body, rest := readPacket(t, buf)
+		types = append(types, tag)
+		bodies = append(bodies, body)
+		buf = rest
+	}
+	assert.Equal(t, []int{packetTypePublicKey, packetTypeUserID,
+		packetTypeSignature}, types)
+	assert.Equal(t, []byte{0x58, 0x37, 0x02, 0x00}, bodies[0][1:5])
+	assert.Equal(t, "raph@raph.com", string(bodies[1]))
+
+	sig := bodies[2]
+	assert.Equal(t, byte(sigTypePositiveCert), sig[1])
+	assert.Equal(t, byte(HashAlgoSHA256), sig[3])
+	// creation time and key expiration
+	hashedLen := int(sig[4])<<8 | int(sig[5])
+	assert.Equal(t, 12, hashedLen)
+	assert.Equal(t, []byte{5, subpacketCreationTime, 0x58, 0x37, 0x02, 0x00},
+		sig[6:12])
+	assert.Equal(t, []byte{5, subpacketKeyExpiration, 0x01, 0xe1, 0x33, 0x80},
+		sig[12:18])
+	// unhashed issuer key ID, then the start of the signed hash
+	assert.Equal(t, []byte{0, 10, 9, 16}, sig[18:22])
+	assert.Equal(t, hash[:2], sig[30:32])
+	assert.Equal(t, R, sig[34:66])
+	assert.Equal(t, S, sig[68:])
+
+	// another hash has to be announced and used for the certification
+	hash512 := CertificationHash(SHA512HashConfig, pubKey, "raph@raph.com",
+		created, expiry)
+	buffer.Reset()
+	log.ErrFatal(SerializeCertifiedPubKey(buffer, SHA512HashConfig, pubKey,
+		"raph@raph.com", created, expiry, R, S))
+	_, _, rest := readPacket(t, buffer.Bytes())
+	_, _, rest = readPacket(t, rest)
+	_, sig, _ = readPacket(t, rest)
+	assert.Equal(t, byte(HashAlgoSHA512), sig[3])
+	assert.Equal(t, hash512[:2], sig[30:32])
+}
+
+// readPacket returns the tag, the body and the rest of the buffer for a
+// packet with a new-format header.
+func readPacket(t *testing.T, buf []byte) (int, []byte, []byte) {
+	tag := int(buf[0] & 0x3f)
+	var length, n int
+	switch {
+	case buf[1] < 192:
+		length, n = int(buf[1]), 2
+	case buf[1] < 224:
+		length, n = (int(buf[1])-192)<<8+int(buf[2])+192, 3
+	default:
+		t.Fatal("Unsupported packet length")
+	}
+	return tag, buf[n : n+length], buf[n+length:]
+}
+
+func TestArmored(t *testing.T) {
+	var binary, armored bytes.Buffer
+	// the creation time is in the key, so both have to be created in the
+	// same second
+	for {
+		binary.Reset()
+		armored.Reset()
+		start := time.Now().Unix()
+		if err := SerializePubKey(&binary, pubKey, "raph@raph.com"); err != nil {
+			t.Fatal("Couldn't serialize public key:", err)
+		}
+		if err := SerializePubKeyArmored(&armored, pubKey, "raph@raph.com"); err != nil {
+			t.Fatal("Couldn't serialize armored public key:", err)
+		}
+		if time.Now().Unix() == start {
+			break
+		}
+	}
+	assert.True(t, strings.HasPrefix(armored.String(),
+		"-----BEGIN PGP PUBLIC KEY BLOCK-----"))
+	block, err := armor.Decode(&armored)
+	if err != nil {
+		t.Fatal("Couldn't decode armor:", err)
+	}
+	assert.Equal(t, "PGP PUBLIC KEY BLOCK", block.Type)
+	// reading everything also checks the CRC-24
+	decoded, err := ioutil.ReadAll(block.Body)
+	if err != nil {
+		t.Fatal("Couldn't read armored key:", err)
+	}
+	assert.Equal(t, binary.Bytes(), decoded)
+
+	binary.Reset()
+	armored.Reset()
+	log.ErrFatal(SerializeSignature(&binary, data, pubKey, R, S))
+	log.ErrFatal(SerializeSignatureArmored(&armored, data, pubKey, R, S))
+	block, err = armor.Decode(&armored)
+	if err != nil {
+		t.Fatal("Couldn't decode armor:", err)
+	}
+	assert.Equal(t, "PGP SIGNATURE", block.Type)
+	decoded, err = ioutil.ReadAll(block.Body)
+	if err != nil {
+		t.Fatal("Couldn't read armored signature:", err)
+	}
+	assert.Equal(t, binary.Bytes(), decoded)
+}
+
 func TestJVSSPubKeyAndSignature(t *testing.T) {
 	var name string = "JVSS" // Protocol name
 	var nodes uint32 = 5     // Number of nodes
@@ -58,7 +182,7 @@ func TestJVSSPubKeyAndSignature(t *testing.T) {
 	msg = HashMessage(hasher, msg)
 
 	local := sda.NewLocalTest()
-	_, _, tree := local.GenTree(int(nodes), false, true, true)
+	_, _, tree := local.GenTree(int(nodes), true)
 	defer local.CloseAll()
 
 	log.Lvl1("JVSS - starting")
@@ -77,21 +201,24 @@ func TestJVSSPubKeyAndSignature(t *testing.T) {
 		t.Fatal("Error signature failed", err)
 	}
 
-	// Dirty "trick" to get longterm public secret
-	var sidLTSS SID
-	for k := range jv.sidStore.store {
-		if strings.Contains(string(k), "LTSS") {
-			sidLTSS = k
-		}
+	secPub, err := jv.LongtermPublic()
+	if err != nil {
+		t.Fatal("Couldn't get longterm public key:", err)
 	}
-
-	sec, err := jv.secrets.secret(sidLTSS)
+	secPubB, err := secPub.MarshalBinary()
+	// the group certifies its own key
+	created := time.Now()
+	expiry := created.Add(365 * 24 * time.Hour)
+	certHash := CertificationHash(DefaultHashConfig, secPubB, "raph@raph.com",
+		created, expiry)
+	certSig, err := jv.Sign(certHash)
 	if err != nil {
-		t.Fatal("Couldn't get longterm secret :", err)
+		t.Fatal("Couldn't certify public key:", err)
 	}
-	secPubB, err := sec.secret.Pub.SecretCommit().MarshalBinary()
+	certRS, _ := NewSig(certSig).MarshalBinary()
 	buffer := bytes.NewBuffer(nil)
-	err = SerializePubKey(buffer, secPubB, "raph@raph.com")
+	err = SerializeCertifiedPubKey(buffer, DefaultHashConfig, secPubB,
+		"raph@raph.com", created, expiry, certRS[:32], certRS[32:])
 	if err != nil {
 		t.Fatal("Couldn't serialize public key: ", err)
 	}
@@ -101,8 +228,8 @@ func TestJVSSPubKeyAndSignature(t *testing.T) {
 	}
 	log.Lvl1("Wrote public key file")
 
-	r, _ := sig.Random.SecretCommit().MarshalBinary()
-	s, _ := (*sig.Signature).MarshalBinary()
+	rs, _ := NewSig(sig).MarshalBinary()
+	r, s := rs[:32], rs[32:]
 
 	buffer.Reset()
 	err = SerializeSignature(buffer, msg, secPubB, r, s)
@@ -122,3 +249,60 @@ func TestJVSSPubKeyAndSignature(t *testing.T) {
 
 	log.Lvl1("JVSS - signature received")
 }
+
+func TestHashConfig(t *testing.T) {
+	assert.Equal(t, HashMessage(sha256.New(), data),
+		DefaultHashConfig.HashMessage(data))
+	h512 := SHA512HashConfig.HashMessage(data)
+	assert.Equal(t, 64, len(h512))
+	prefixed := SHA512HashConfig
+	prefixed.Prefix = []byte("cothority")
+	assert.NotEqual(t, h512, prefixed.HashMessage(data))
+
+	buffer := bytes.NewBuffer(nil)
+	err := SerializeSignatureHash(buffer, SHA512HashConfig, data, pubKey, R, S)
+	if err != nil {
+		t.Fatal("Couldn't serialize signature:", err)
+	}
+	tag, sig, _ := readPacket(t, buffer.Bytes())
+	assert.Equal(t, packetTypeSignature, tag)
+	assert.Equal(t, byte(HashAlgoSHA512), sig[3])
+	assert.Equal(t, h512[:2], sig[18:20])
+}
+
+func TestJVSSHashConfig(t *testing.T) {
+	local := sda.NewLocalTest()
+	_, _, tree := local.GenTree(5, true)
+	defer local.CloseAll()
+
+	leader, err := local.CreateProtocol("JVSS", tree)
+	if err != nil {
+		t.Fatal("Couldn't initialise protocol tree:", err)
+	}
+	jv := leader.(*JVSS)
+	leader.Start()
+
+	prefixed := SHA512HashConfig
+	prefixed.Prefix = []byte("cothority")
+	var hashes [][]byte
+	var rs [][]byte
+	for _, hc := range []HashConfig{DefaultHashConfig, SHA512HashConfig, prefixed} {
+		msg := hc.HashMessage(data)
+		sig, err := jv.SignContext(context.Background(), msg)
+		log.ErrFatal(err)
+		buf, err := sig.MarshalBinary()
+		log.ErrFatal(err)
+		hashes = append(hashes, msg)
+		rs = append(rs, buf)
+	}
+	for i := range rs {
+		for j := range hashes {
+			if i == j {
+				assert.Nil(t, jv.VerifyBytes(hashes[j], rs[i]))
+			} else {
+				assert.NotEqual(t, rs[i], rs[j])
+				assert.NotNil(t, jv.VerifyBytes(hashes[j], rs[i]))
+			}
+		}
+	}
+}
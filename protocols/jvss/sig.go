@@ -0,0 +1,92 @@
+package jvss
+
+import (
+	"errors"
+
+	"github.com/dedis/crypto/abstract"
+	"github.com/sriak/crypto/poly"
+)
+
+// Sig is a JVSS signature in its canonical form: the random commitment R
+// and the response S. It is marshalled as R||S, which for ed25519 is the
+// usual 64-byte Schnorr-signature expected by external verifiers.
+type Sig struct {
+	R abstract.Point
+	S abstract.Scalar
+}
+
+// NewSig returns the canonical form of a signature returned by JVSS.Sign.
+func NewSig(sig *poly.SchnorrSig) *Sig {
+	return &Sig{
+		R: sig.Random.SecretCommit(),
+		S: *sig.Signature,
+	}
+}
+
+// NewEmptySig returns a Sig that can be used to unmarshal a signature of the
+// given suite.
+func NewEmptySig(suite abstract.Suite) *Sig {
+	return &Sig{
+		R: suite.Point(),
+		S: suite.Scalar(),
+	}
+}
+
+// MarshalBinary returns R||S.
+func (s *Sig) MarshalBinary() ([]byte, error) {
+	r, err := s.R.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	sb, err := s.S.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	return append(r, sb...), nil
+}
+
+// UnmarshalBinary reads R||S. R and S must be initialised, e.g. by
+// NewEmptySig.
+func (s *Sig) UnmarshalBinary(buf []byte) error {
+	rLen := s.R.MarshalSize()
+	if len(buf) != rLen+s.S.MarshalSize() {
+		return errors.New("Wrong length of signature")
+	}
+	if err := s.R.UnmarshalBinary(buf[:rLen]); err != nil {
+		return err
+	}
+	return s.S.UnmarshalBinary(buf[rLen:])
+}
+
+// schnorrSig returns the signature in the form used by poly.
+func (s *Sig) schnorrSig(suite abstract.Suite) (*poly.SchnorrSig, error) {
+	r, err := s.R.MarshalBinary()
+	if err != nil {
+		return nil, err
+	}
+	// only the commitment of the secret is used to verify the signature
+	random := new(poly.PubPoly).Init(suite, 1, nil)
+	if err := random.UnmarshalBinary(r); err != nil {
+		return nil, err
+	}
+	sig := suite.Scalar().Set(s.S)
+	return &poly.SchnorrSig{
+		Random:    random,
+		Signature: &sig,
+	}, nil
+}
+
+// VerifyBytes verifies a signature marshalled by Sig.MarshalBinary on the
+// given message. It returns nil if the signature is valid and an error
+// otherwise.
+func (jv *JVSS) VerifyBytes(msg, sig []byte) error {
+	s := NewEmptySig(jv.Suite())
+	if err := s.UnmarshalBinary(sig); err != nil {
+		return err
+	}
+	ss, err := s.schnorrSig(jv.Suite())
+	if err != nil {
+		return err
+	}
+	return jv.Verify(msg, ss)
+}
@@ -0,0 +1,140 @@
+package sda
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dedis/cothority/log"
+)
+
+// Barrier synchronises all nodes of a tree: a node calling Wait only
+// continues once every node of the tree called Wait. This is useful for
+// phased protocols where no node may start the next phase before all nodes
+// finished the current one.
+//
+// Every node sends a BarrierReady to its parent once itself and all its
+// children arrived at the barrier. Once the root knows that everybody is
+// ready, it sends a BarrierProceed down the tree which releases the nodes.
+// The same Barrier can be used for multiple phases, one after the other.
+type Barrier struct {
+	tni *TreeNodeInstance
+	// round is the number of the current phase
+	round int
+	// arrived is true if this node called Wait in the current round
+	arrived bool
+	// ready counts the children that are ready, per round
+	ready   map[int]int
+	proceed chan bool
+	sync.Mutex
+}
+
+// BarrierReady is sent to the parent once a subtree arrived at the barrier.
+type BarrierReady struct {
+	Round int
+}
+
+// BarrierProceed is sent from the root down the tree once all nodes arrived
+// at the barrier.
+type BarrierProceed struct {
+	Round int
+}
+
+// NewBarrier returns a Barrier for the given TreeNodeInstance and registers
+// the handlers for the barrier-messages. It has to be called on every node of
+// the tree, usually in the constructor of the protocol.
+func NewBarrier(tni *TreeNodeInstance) (*Barrier, error) {
+	b := &Barrier{
+		tni:     tni,
+		ready:   make(map[int]int),
+		proceed: make(chan bool, 1),
+	}
+	if err := tni.RegisterHandlers(b.handleReady, b.handleProceed); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
+
+// Wait marks this node as arrived at the barrier and blocks until all nodes
+// of the tree arrived. As the release of the barrier is done by a
+// message-handler, Wait must not be called from within a handler of the same
+// protocol.
+func (b *Barrier) Wait() error {
+	b.Lock()
+	if b.arrived {
+		b.Unlock()
+		return errors.New("Already waiting on this barrier")
+	}
+	b.arrived = true
+	err := b.checkReady()
+	b.Unlock()
+	if err != nil {
+		return err
+	}
+	<-b.proceed
+	return nil
+}
+
+// Round returns the number of phases that have passed the barrier.
+func (b *Barrier) Round() int {
+	b.Lock()
+	defer b.Unlock()
+	return b.round
+}
+
+// checkReady verifies if this node and all its children arrived and informs
+// the parent, or releases the tree if we're the root. It must be called with
+// the lock held.
+func (b *Barrier) checkReady() error {
+	if !b.arrived || b.ready[b.round] < len(b.tni.Children()) {
+		return nil
+	}
+	if b.tni.IsRoot() {
+		log.Lvl3(b.tni.Name(), "everybody arrived at barrier", b.round)
+		return b.release()
+	}
+	log.Lvl3(b.tni.Name(), "subtree arrived at barrier", b.round)
+	return b.tni.SendToParent(&BarrierReady{Round: b.round})
+}
+
+// release passes to the next round, lets the children proceed and
+// unblocks Wait. It must be called with the lock held.
+func (b *Barrier) release() error {
+	round := b.round
+	delete(b.ready, round)
+	b.round++
+	b.arrived = false
+	err := b.tni.SendToChildren(&BarrierProceed{Round: round})
+	b.proceed <- true
+	return err
+}
+
+func (b *Barrier) handleReady(msg struct {
+	*TreeNode
+	BarrierReady
+}) {
+	b.Lock()
+	defer b.Unlock()
+	b.ready[msg.Round]++
+	if msg.Round != b.round {
+		return
+	}
+	if err := b.checkReady(); err != nil {
+		log.Error(b.tni.Name(), "couldn't pass barrier:", err)
+	}
+}
+
+func (b *Barrier) handleProceed(msg struct {
+	*TreeNode
+	BarrierProceed
+}) {
+	b.Lock()
+	defer b.Unlock()
+	if msg.Round != b.round {
+		log.Error(b.tni.Name(), "got proceed for round", msg.Round,
+			"while in round", b.round)
+		return
+	}
+	if err := b.release(); err != nil {
+		log.Error(b.tni.Name(), "couldn't release children:", err)
+	}
+}
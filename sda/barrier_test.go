@@ -0,0 +1,109 @@
+package sda
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dedis/cothority/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const barrierTestName = "BarrierTest"
+
+func init() {
+	GlobalProtocolRegister(barrierTestName, newBarrierProto)
+}
+
+func TestBarrier(t *testing.T) {
+	local := NewLocalTest()
+	defer local.CloseAll()
+	nbrNodes := 7
+	_, _, tree := local.GenTree(nbrNodes, true)
+
+	barrierEvents.Lock()
+	barrierEvents.arrived = make(map[int]time.Time)
+	barrierEvents.proceeded = make(map[int]time.Time)
+	barrierEvents.Unlock()
+	barrierDone = make(chan bool, nbrNodes)
+	_, err := local.StartProtocol(barrierTestName, tree)
+	log.ErrFatal(err)
+	for i := 0; i < nbrNodes; i++ {
+		select {
+		case <-barrierDone:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Barrier didn't release all nodes")
+		}
+	}
+
+	barrierEvents.Lock()
+	defer barrierEvents.Unlock()
+	assert.Equal(t, nbrNodes, len(barrierEvents.arrived))
+	assert.Equal(t, nbrNodes, len(barrierEvents.proceeded))
+	var lastArrival time.Time
+	for _, a := range barrierEvents.arrived {
+		if a.After(lastArrival) {
+			lastArrival = a
+		}
+	}
+	for i, p := range barrierEvents.proceeded {
+		assert.False(t, p.Before(lastArrival),
+			"node", i, "proceeded before the last node arrived")
+	}
+}
+
+var barrierEvents struct {
+	arrived   map[int]time.Time
+	proceeded map[int]time.Time
+	sync.Mutex
+}
+var barrierDone chan bool
+
+type barrierStart struct{}
+
+// barrierProto starts on all nodes and waits a different time on every
+// node before arriving at the barrier.
+type barrierProto struct {
+	*TreeNodeInstance
+	barrier *Barrier
+}
+
+func newBarrierProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	b, err := NewBarrier(n)
+	if err != nil {
+		return nil, err
+	}
+	p := &barrierProto{TreeNodeInstance: n, barrier: b}
+	return p, n.RegisterHandler(p.handleStart)
+}
+
+func (p *barrierProto) Start() error {
+	return p.handleStart(struct {
+		*TreeNode
+		barrierStart
+	}{})
+}
+
+func (p *barrierProto) handleStart(msg struct {
+	*TreeNode
+	barrierStart
+}) error {
+	if err := p.SendToChildren(&barrierStart{}); err != nil {
+		return err
+	}
+	go p.run()
+	return nil
+}
+
+func (p *barrierProto) run() {
+	idx := p.Index()
+	time.Sleep(time.Duration(idx*20) * time.Millisecond)
+	barrierEvents.Lock()
+	barrierEvents.arrived[idx] = time.Now()
+	barrierEvents.Unlock()
+	log.ErrFatal(p.barrier.Wait())
+	barrierEvents.Lock()
+	barrierEvents.proceeded[idx] = time.Now()
+	barrierEvents.Unlock()
+	barrierDone <- true
+}
@@ -0,0 +1,108 @@
+package sda
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+	"time"
+
+	"github.com/dedis/cothority/network"
+)
+
+// ResultCache stores the results of protocols, so that a service can answer
+// a repeated request without running the protocol again. The results are
+// stored under a key computed by ResultKey from the inputs of the protocol.
+// Results expire after the ttl given to NewResultCache, and if the cache is
+// full, the oldest result is removed.
+type ResultCache struct {
+	size    int
+	ttl     time.Duration
+	results map[string]*cachedResult
+	// order holds the keys from the oldest to the newest result
+	order []string
+	sync.Mutex
+}
+
+type cachedResult struct {
+	result  interface{}
+	expires time.Time
+}
+
+// NewResultCache returns a cache holding at most size results. If ttl is 0,
+// the results never expire.
+func NewResultCache(size int, ttl time.Duration) *ResultCache {
+	return &ResultCache{
+		size:    size,
+		ttl:     ttl,
+		results: make(map[string]*cachedResult),
+	}
+}
+
+// ResultKey returns the key for the given inputs of a protocol: the hash of
+// their encoding. The inputs must be registered to the network library.
+func ResultKey(inputs ...network.Body) (string, error) {
+	h := sha256.New()
+	for _, in := range inputs {
+		buf, err := network.MarshalRegisteredType(in)
+		if err != nil {
+			return "", err
+		}
+		h.Write(buf)
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Get returns the result stored under key and true, or nil and false if
+// there is no such result or it expired.
+func (c *ResultCache) Get(key string) (interface{}, bool) {
+	c.Lock()
+	defer c.Unlock()
+	r, ok := c.results[key]
+	if !ok {
+		return nil, false
+	}
+	if c.ttl > 0 && time.Now().After(r.expires) {
+		c.remove(key)
+		return nil, false
+	}
+	return r.result, true
+}
+
+// Put stores the result under key, replacing a result with the same key.
+func (c *ResultCache) Put(key string, result interface{}) {
+	c.Lock()
+	defer c.Unlock()
+	if c.size <= 0 {
+		return
+	}
+	if _, ok := c.results[key]; ok {
+		c.remove(key)
+	}
+	for len(c.order) >= c.size {
+		c.remove(c.order[0])
+	}
+	c.results[key] = &cachedResult{
+		result:  result,
+		expires: time.Now().Add(c.ttl),
+	}
+	c.order = append(c.order, key)
+}
+
+// Len returns the number of results in the cache, including the expired
+// results that have not been removed yet.
+func (c *ResultCache) Len() int {
+	c.Lock()
+	defer c.Unlock()
+	return len(c.results)
+}
+
+// remove deletes the result of key. It must be called with the lock held.
+func (c *ResultCache) remove(key string) {
+	delete(c.results, key)
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			return
+		}
+	}
+}
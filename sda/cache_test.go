@@ -0,0 +1,62 @@
+package sda
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/network"
+	"github.com/stretchr/testify/assert"
+)
+
+type cacheInput struct {
+	Query string
+}
+
+func init() {
+	network.RegisterPacketType(cacheInput{})
+}
+
+func TestResultKey(t *testing.T) {
+	k1, err := ResultKey(&cacheInput{"a"})
+	log.ErrFatal(err)
+	k2, err := ResultKey(&cacheInput{"a"})
+	log.ErrFatal(err)
+	k3, err := ResultKey(&cacheInput{"b"})
+	log.ErrFatal(err)
+	assert.Equal(t, k1, k2)
+	assert.NotEqual(t, k1, k3)
+	_, err = ResultKey(struct{ Unregistered int }{})
+	assert.NotNil(t, err)
+}
+
+func TestResultCache(t *testing.T) {
+	c := NewResultCache(2, 0)
+	_, ok := c.Get("a")
+	assert.False(t, ok)
+	c.Put("a", 1)
+	c.Put("b", 2)
+	r, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 1, r)
+
+	// the oldest result is removed
+	c.Put("c", 3)
+	assert.Equal(t, 2, c.Len())
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	r, ok = c.Get("c")
+	assert.True(t, ok)
+	assert.Equal(t, 3, r)
+}
+
+func TestResultCacheTTL(t *testing.T) {
+	c := NewResultCache(10, 50*time.Millisecond)
+	c.Put("a", 1)
+	_, ok := c.Get("a")
+	assert.True(t, ok)
+	time.Sleep(100 * time.Millisecond)
+	_, ok = c.Get("a")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
@@ -6,8 +6,12 @@ import (
 	"strings"
 
 	"sort"
+	"strconv"
 
 	"errors"
+	"fmt"
+
+	"time"
 
 	"github.com/dedis/cothority/log"
 	"github.com/dedis/cothority/network"
@@ -30,6 +34,12 @@ type Conode struct {
 	// protocols holds a map of all available protocols and how to create an
 	// instance of it
 	protocols *protocolStorage
+	// storage is where the services persist their data
+	storage StorageBackend
+	pinger  *pinger
+	// closed is closed once Close has been called
+	closed    chan bool
+	closeOnce sync.Once
 }
 
 // NewConode returns a fresh Host with a given Router.
@@ -39,14 +49,23 @@ func NewConode(r *network.Router, pkey abstract.Scalar) *Conode {
 		statusReporterStruct: newStatusReporterStruct(),
 		Router:               r,
 		protocols:            newProtocolStorage(),
+		storage:              defaultStorage,
+		closed:               make(chan bool),
+	}
+	if c.storage == nil {
+		c.storage = NewFileStorage(configFolder)
 	}
 	c.overlay = NewOverlay(c)
+	c.pinger = newPinger(c)
 	c.serviceManager = newServiceManager(c, c.overlay)
 	c.statusReporterStruct.RegisterStatusReporter("Status", c)
 	for name, inst := range protocols.instantiators {
 		log.Lvl4("Registering global protocol", name)
 		c.ProtocolRegister(name, inst)
 	}
+	for name, v := range protocols.validators {
+		c.ProtocolValidatorRegister(name, v)
+	}
 	return c
 }
 
@@ -58,6 +77,26 @@ func NewConodeTCP(e *network.ServerIdentity, pkey abstract.Scalar) *Conode {
 	return NewConode(r, pkey)
 }
 
+// NewConodeTLS returns a new Host like NewConodeTCP, but using a TLSRouter
+// with the given certificates as Router.
+func NewConodeTLS(e *network.ServerIdentity, pkey abstract.Scalar, conf *network.TLSConfig) (*Conode, error) {
+	r, err := network.NewTLSRouter(e, conf)
+	if err != nil {
+		return nil, err
+	}
+	return NewConode(r, pkey), nil
+}
+
+// NewConodeUnix returns a new Host like NewConodeTCP, but listening on the
+// Unix domain socket given in the address of e.
+func NewConodeUnix(e *network.ServerIdentity, pkey abstract.Scalar) (*Conode, error) {
+	r, err := network.NewUnixRouter(e)
+	if err != nil {
+		return nil, err
+	}
+	return NewConode(r, pkey), nil
+}
+
 // Suite can (and should) be used to get the underlying abstract.Suite.
 // Currently the suite is hardcoded into the network library.
 // Don't use network.Suite but Host's Suite function instead if possible.
@@ -71,6 +110,9 @@ func (c *Conode) GetStatus() Status {
 	a := ServiceFactory.RegisteredServiceNames()
 	sort.Strings(a)
 	m["Available_Services"] = strings.Join(a, ",")
+	hits, misses := c.PoolStats()
+	m["Connection_Hits"] = strconv.FormatUint(hits, 10)
+	m["Connection_Misses"] = strconv.FormatUint(misses, 10)
 	return m
 }
 
@@ -79,10 +121,31 @@ func (c *Conode) Close() error {
 	c.overlay.Close()
 	err := c.Router.Stop()
 	log.Lvl3("Host Close ", c.ServerIdentity.Address, "listening?", c.Router.Listening())
+	c.closeOnce.Do(func() { close(c.closed) })
 	return err
 
 }
 
+// WaitForClose blocks until Close is called.
+func (c *Conode) WaitForClose() {
+	c.WaitForCloseTimeout(0)
+}
+
+// WaitForCloseTimeout blocks until Close is called or the duration d
+// elapsed, in which case it returns an error. If d is 0, it waits forever.
+func (c *Conode) WaitForCloseTimeout(d time.Duration) error {
+	if d <= 0 {
+		<-c.closed
+		return nil
+	}
+	select {
+	case <-c.closed:
+		return nil
+	case <-time.After(d):
+		return errors.New("Timeout while waiting for conode to close")
+	}
+}
+
 // Address returns the address used by the Router.
 func (c *Conode) Address() network.Address {
 	return c.ServerIdentity.Address
@@ -93,12 +156,60 @@ func (c *Conode) GetService(name string) Service {
 	return c.serviceManager.Service(name)
 }
 
+// GetServiceChecked is like GetService, but returns an error if no service
+// with that name is running, so the caller can fail gracefully instead of
+// doing a type-assertion on nil.
+func (c *Conode) GetServiceChecked(name string) (Service, error) {
+	s := c.serviceManager.Service(name)
+	if s == nil {
+		return nil, fmt.Errorf("service %s not available; is it compiled in?", name)
+	}
+	return s, nil
+}
+
+// Storage returns the StorageBackend used by the services of this Conode.
+func (c *Conode) Storage() StorageBackend {
+	return c.storage
+}
+
+// Ping sends a ping to si and returns nil if it answered within the
+// timeout.
+func (c *Conode) Ping(si *network.ServerIdentity, timeout time.Duration) error {
+	return c.pinger.ping(si, timeout)
+}
+
+// RegisteredServices returns the sorted names of the services running on
+// this Conode.
+func (c *Conode) RegisteredServices() []string {
+	names := c.serviceManager.AvailableServices()
+	sort.Strings(names)
+	return names
+}
+
+// RegisteredProtocols returns the sorted names of the protocols this Conode
+// can instantiate, including the ones registered by services.
+func (c *Conode) RegisteredProtocols() []string {
+	return c.protocols.names()
+}
+
 // ProtocolRegister will sign up a new protocol to this Conode.
 // It returns the ID of the protocol.
 func (c *Conode) ProtocolRegister(name string, protocol NewProtocol) (ProtocolID, error) {
 	return c.protocols.Register(name, protocol)
 }
 
+// ProtocolValidatorRegister registers a ConfigValidator for the protocol
+// with the given name on this Conode.
+func (c *Conode) ProtocolValidatorRegister(name string, v ConfigValidator) {
+	c.protocols.RegisterValidator(name, v)
+}
+
+// ValidateConfig checks the config for the protocol with the given name
+// using the registered ConfigValidator, if any.
+func (c *Conode) ValidateConfig(name string, conf *GenericConfig) error {
+	return c.protocols.ValidateConfig(ProtocolNameToID(name), conf)
+}
+
 // ProtocolInstantiate instantiate a protocol from its ID
 func (c *Conode) ProtocolInstantiate(protoID ProtocolID, tni *TreeNodeInstance) (ProtocolInstance, error) {
 	fn, ok := c.protocols.instantiators[c.protocols.ProtocolIDToName(protoID)]
@@ -1,7 +1,9 @@
 package sda
 
 import (
+	"sort"
 	"testing"
+	"time"
 
 	"github.com/dedis/cothority/log"
 	"github.com/satori/go.uuid"
@@ -29,6 +31,24 @@ func TestConode_GetService(t *testing.T) {
 	defer c.Close()
 	s := c.GetService("nil")
 	require.Nil(t, s)
+	s, err := c.GetServiceChecked("nil")
+	require.Nil(t, s)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "service nil not available")
+}
+
+func TestConode_Registered(t *testing.T) {
+	c := NewLocalConode(0)
+	defer c.Close()
+	_, err := c.ProtocolRegister("ConodeRegistered", NewConodeProtocol)
+	log.ErrFatal(err)
+	protos := c.RegisteredProtocols()
+	require.True(t, sort.StringsAreSorted(protos))
+	require.Contains(t, protos, "ConodeRegistered")
+	require.NotContains(t, RegisteredProtocols(), "ConodeRegistered")
+	services := c.RegisteredServices()
+	require.True(t, sort.StringsAreSorted(services))
+	require.Equal(t, len(ServiceFactory.RegisteredServiceNames()), len(services))
 }
 
 type ConodeProtocol struct {
@@ -48,3 +68,17 @@ func NewConodeProtocol2(n *TreeNodeInstance) (ProtocolInstance, error) {
 func (cp *ConodeProtocol) Start() error {
 	return nil
 }
+
+func TestConode_WaitForClose(t *testing.T) {
+	c := NewLocalConode(0)
+	require.NotNil(t, c.WaitForCloseTimeout(50*time.Millisecond))
+
+	go func() {
+		time.Sleep(50 * time.Millisecond)
+		log.ErrFatal(c.Close())
+	}()
+	require.Nil(t, c.WaitForCloseTimeout(time.Second))
+	// returns right away once closed
+	c.WaitForClose()
+	require.Nil(t, c.WaitForCloseTimeout(time.Millisecond))
+}
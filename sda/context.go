@@ -1,6 +1,10 @@
 package sda
 
-import "github.com/dedis/cothority/network"
+import (
+	"time"
+
+	"github.com/dedis/cothority/network"
+)
 
 // Context represents the methods that are available to a service.
 type Context struct {
@@ -50,6 +54,12 @@ func (c *Context) CreateProtocolService(name string, t *Tree) (ProtocolInstance,
 	return pi, err
 }
 
+// CreateProtocolServiceWithConfig is like CreateProtocolService, but the
+// NewProtocol-method of the service gets conf on every node.
+func (c *Context) CreateProtocolServiceWithConfig(name string, t *Tree, conf *GenericConfig) (ProtocolInstance, error) {
+	return c.overlay.CreateProtocolWithConfig(name, t, c.servID, conf)
+}
+
 // CreateProtocolSDA is like CreateProtocolService but doesn't bind it to a
 // service, so it will be handled automatically by the SDA.
 func (c *Context) CreateProtocolSDA(name string, t *Tree) (ProtocolInstance, error) {
@@ -57,6 +67,28 @@ func (c *Context) CreateProtocolSDA(name string, t *Tree) (ProtocolInstance, err
 	return pi, err
 }
 
+// ValidateConfig checks the config for the protocol with the given name, so
+// that a service can refuse a request before setting up a tree.
+func (c *Context) ValidateConfig(name string, conf *GenericConfig) error {
+	return c.conode.ValidateConfig(name, conf)
+}
+
+// Storage returns the StorageBackend the service should use to persist
+// its data. All keys are relative to the service, so different services
+// can use the same keys.
+func (c *Context) Storage() StorageBackend {
+	return &serviceStorage{
+		backend: c.conode.Storage(),
+		prefix:  ServiceFactory.Name(c.servID),
+	}
+}
+
+// Ping sends a ping to si and returns nil if it answered within the
+// timeout.
+func (c *Context) Ping(si *network.ServerIdentity, timeout time.Duration) error {
+	return c.conode.Ping(si, timeout)
+}
+
 // RegisterProtocolInstance registers a new instance of a protocol using overlay.
 func (c *Context) RegisterProtocolInstance(pi ProtocolInstance) error {
 	return c.overlay.RegisterProtocolInstance(pi)
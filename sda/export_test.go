@@ -5,7 +5,7 @@ import "github.com/dedis/cothority/network"
 // Export some private functions of Host for testing
 
 func (c *Conode) SendSDAData(id *network.ServerIdentity, msg *ProtocolMsg) error {
-	return c.overlay.sendSDAData(id, msg)
+	return c.overlay.sendSDAData(id, msg, false)
 }
 
 func (c *Conode) CreateProtocol(name string, t *Tree) (ProtocolInstance, error) {
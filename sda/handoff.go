@@ -0,0 +1,243 @@
+package sda
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dedis/cothority/log"
+)
+
+// Handoff lets the leader of a protocol, which is the root at the start,
+// hand its role over to another node of the tree without restarting the
+// protocol. The leader either transfers its state to a successor that takes
+// over right away, or replicates it regularly to a successor that takes over
+// once the leader failed.
+//
+// A new leader announces itself to all other nodes of the tree with a higher
+// epoch and waits for their acknowledgements. Nodes that can't be reached,
+// like a failed leader, are skipped. If two nodes take over with the same
+// epoch, all nodes follow the one with the smaller TreeNodeID and the other
+// one steps down.
+type Handoff struct {
+	tni *TreeNodeInstance
+	// onLeader is called on every node that accepted a new leader
+	onLeader func(leader *TreeNode, state []byte)
+	leader   *TreeNode
+	epoch    int
+	state    []byte
+	// pending is the number of nodes that didn't acknowledge the new
+	// leader yet
+	pending int
+	// ackErr is the first error seen in the acknowledgements, or nil
+	ackErr error
+	done   chan error
+	sync.Mutex
+}
+
+// HandoffState is sent by the leader to its successor with the state of the
+// protocol. If Promote is true, the successor takes over right away.
+type HandoffState struct {
+	Epoch   int
+	State   []byte
+	Promote bool
+}
+
+// HandoffLeader is sent by a new leader to all other nodes of the tree.
+type HandoffLeader struct {
+	Epoch int
+}
+
+// HandoffAck is sent back to the new leader with the epoch and the ID of the
+// leader the node follows.
+type HandoffAck struct {
+	Epoch  int
+	Leader TreeNodeID
+}
+
+// NewHandoff returns a Handoff for the given TreeNodeInstance and registers
+// its handlers. It has to be called on every node of the tree, usually in the
+// constructor of the protocol. onLeader may be nil, else it is called on every
+// node once it follows a new leader, with the state on the new leader and nil
+// on the other nodes.
+func NewHandoff(tni *TreeNodeInstance, onLeader func(*TreeNode, []byte)) (*Handoff, error) {
+	h := &Handoff{
+		tni:      tni,
+		onLeader: onLeader,
+		leader:   tni.Root(),
+		done:     make(chan error, 1),
+	}
+	err := tni.RegisterHandlers(h.handleState, h.handleLeader, h.handleAck)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+// Leader returns the current leader as seen by this node.
+func (h *Handoff) Leader() *TreeNode {
+	h.Lock()
+	defer h.Unlock()
+	return h.leader
+}
+
+// IsLeader returns true if this node is the current leader.
+func (h *Handoff) IsLeader() bool {
+	return h.Leader().ID.Equal(h.tni.TreeNode().ID)
+}
+
+// State returns the last state this node received as a successor, or
+// transferred as a leader.
+func (h *Handoff) State() []byte {
+	h.Lock()
+	defer h.Unlock()
+	return h.state
+}
+
+// Replicate sends the state to the successor, which keeps it to take over
+// if the leader fails. It can only be called by the leader.
+func (h *Handoff) Replicate(successor *TreeNode, state []byte) error {
+	return h.send(successor, state, false)
+}
+
+// Transfer sends the state to the successor, which then announces itself as
+// the new leader. It can only be called by the leader and returns once the
+// state is sent.
+func (h *Handoff) Transfer(successor *TreeNode, state []byte) error {
+	return h.send(successor, state, true)
+}
+
+// TakeOver makes this node the new leader, using the last state replicated
+// to it. It is called by the successor once it detects that the leader
+// failed, and returns once all reachable nodes follow it. As the
+// acknowledgements are received by message-handlers, it must not be called
+// from within a handler of the same protocol.
+func (h *Handoff) TakeOver() error {
+	h.Lock()
+	h.announce()
+	h.Unlock()
+	return <-h.done
+}
+
+func (h *Handoff) send(successor *TreeNode, state []byte, promote bool) error {
+	if !h.IsLeader() {
+		return errors.New("Only the leader can hand off its state")
+	}
+	if successor == nil || successor.ID.Equal(h.tni.TreeNode().ID) {
+		return errors.New("Need another node as successor")
+	}
+	h.Lock()
+	h.state = state
+	epoch := h.epoch
+	h.Unlock()
+	return h.tni.SendTo(successor, &HandoffState{epoch, state, promote})
+}
+
+func (h *Handoff) handleState(msg struct {
+	*TreeNode
+	HandoffState
+}) {
+	h.Lock()
+	defer h.Unlock()
+	if msg.Epoch < h.epoch {
+		log.Lvl2(h.tni.Name(), "ignores state of old leader", msg.ServerIdentity)
+		return
+	}
+	h.epoch = msg.Epoch
+	h.state = msg.State
+	if !msg.Promote {
+		return
+	}
+	go func() {
+		if err := h.TakeOver(); err != nil {
+			log.Error(h.tni.Name(), "couldn't take over:", err)
+		}
+	}()
+}
+
+// announce sends the new epoch to all other nodes. It must be called with
+// the lock held.
+func (h *Handoff) announce() {
+	h.epoch++
+	h.leader = h.tni.TreeNode()
+	h.pending = 0
+	h.ackErr = nil
+	for _, tn := range h.tni.List() {
+		if tn.ID.Equal(h.leader.ID) {
+			continue
+		}
+		if err := h.tni.SendTo(tn, &HandoffLeader{h.epoch}); err != nil {
+			log.Lvl2(h.tni.Name(), "couldn't reach", tn.ServerIdentity, err)
+			continue
+		}
+		h.pending++
+	}
+	if h.pending == 0 {
+		h.finish()
+	}
+}
+
+func (h *Handoff) handleLeader(msg struct {
+	*TreeNode
+	HandoffLeader
+}) {
+	h.Lock()
+	accepted := msg.Epoch > h.epoch ||
+		msg.Epoch == h.epoch && before(msg.TreeNode.ID, h.leader.ID)
+	if accepted {
+		h.epoch = msg.Epoch
+		h.leader = msg.TreeNode
+		if h.pending > 0 {
+			// this node was taking over itself and steps down
+			h.ackErr = fmt.Errorf("%s took over with epoch %d",
+				msg.ServerIdentity, msg.Epoch)
+			h.pending = 0
+			h.finish()
+		}
+	}
+	ack := &HandoffAck{h.epoch, h.leader.ID}
+	h.Unlock()
+	if accepted && h.onLeader != nil {
+		h.onLeader(msg.TreeNode, nil)
+	}
+	if err := h.tni.SendTo(msg.TreeNode, ack); err != nil {
+		log.Error(h.tni.Name(), "couldn't acknowledge leader:", err)
+	}
+}
+
+func (h *Handoff) handleAck(msg struct {
+	*TreeNode
+	HandoffAck
+}) {
+	h.Lock()
+	defer h.Unlock()
+	if msg.Epoch < h.epoch || h.pending == 0 {
+		// acknowledgement of an earlier announcement
+		return
+	}
+	if !msg.Leader.Equal(h.tni.TreeNode().ID) && h.ackErr == nil {
+		h.ackErr = fmt.Errorf("%s follows another leader of epoch %d",
+			msg.ServerIdentity, msg.Epoch)
+	}
+	h.pending--
+	if h.pending == 0 {
+		h.finish()
+	}
+}
+
+// finish is called once all reachable nodes acknowledged the new leader. It
+// must be called with the lock held.
+func (h *Handoff) finish() {
+	if h.ackErr == nil && h.onLeader != nil {
+		leader, state := h.leader, h.state
+		go h.onLeader(leader, state)
+	}
+	h.done <- h.ackErr
+}
+
+// before returns true if a wins a tie against b, which is the case if it is
+// the smaller ID.
+func before(a, b TreeNodeID) bool {
+	return bytes.Compare(a[:], b[:]) < 0
+}
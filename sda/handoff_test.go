@@ -0,0 +1,173 @@
+package sda
+
+import (
+	"testing"
+	"time"
+
+	"github.com/dedis/cothority/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const handoffTestName = "HandoffTest"
+
+func init() {
+	GlobalProtocolRegister(handoffTestName, newHandoffProto)
+}
+
+// handoffEvent is sent every time a node follows a new leader.
+type handoffEvent struct {
+	idx    int
+	leader int
+	state  []byte
+}
+
+var handoffEvents chan handoffEvent
+var handoffProtos chan *handoffProto
+
+func TestHandoffLeaderFailure(t *testing.T) {
+	local := NewLocalTest()
+	defer local.CloseAll()
+	nbrNodes := 4
+	conodes, _, tree := local.GenTree(nbrNodes, true)
+	handoffEvents = make(chan handoffEvent, 3*nbrNodes)
+	handoffProtos = make(chan *handoffProto, nbrNodes)
+	p, err := local.CreateProtocol(handoffTestName, tree)
+	log.ErrFatal(err)
+	root := p.(*handoffProto)
+	<-handoffProtos
+	require.True(t, root.handoff.IsLeader())
+
+	// The leader keeps a copy of its state on the successor and fails.
+	list := tree.List()
+	log.ErrFatal(root.handoff.Replicate(list[1], []byte("state1")))
+	var succ *handoffProto
+	select {
+	case succ = <-handoffProtos:
+	case <-time.After(time.Second):
+		t.Fatal("Successor didn't get the state")
+	}
+	require.Equal(t, list[1].RosterIndex, succ.Index())
+	for i := 0; string(succ.handoff.State()) != "state1"; i++ {
+		require.True(t, i < 100, "Successor didn't get the state")
+		time.Sleep(10 * time.Millisecond)
+	}
+	log.ErrFatal(local.CloseConode(conodes[tree.Root.RosterIndex]))
+
+	// The successor takes over and all remaining nodes follow it.
+	log.ErrFatal(succ.handoff.TakeOver())
+	assert.True(t, succ.handoff.IsLeader())
+	followers := func() map[int]bool {
+		return map[int]bool{list[1].RosterIndex: true,
+			list[2].RosterIndex: true, list[3].RosterIndex: true}
+	}
+	checkHandoff(t, followers(), list[1].RosterIndex, "state1")
+
+	// The protocol continues with the new leader, which can hand off again.
+	log.ErrFatal(succ.handoff.Transfer(list[2], []byte("state2")))
+	checkHandoff(t, followers(), list[2].RosterIndex, "state2")
+	assert.False(t, succ.handoff.IsLeader())
+	assert.Equal(t, list[2].ID, succ.handoff.Leader().ID)
+}
+
+func TestHandoffSameEpoch(t *testing.T) {
+	local := NewLocalTest()
+	defer local.CloseAll()
+	_, _, tree := local.GenTree(3, true)
+	handoffEvents = make(chan handoffEvent, 20)
+	handoffProtos = make(chan *handoffProto, 3)
+	p, err := local.CreateProtocol(handoffTestName, tree)
+	log.ErrFatal(err)
+	root := p.(*handoffProto)
+	<-handoffProtos
+
+	// Two successors take over at the same time with the same epoch.
+	list := tree.List()
+	succs := make(map[int]*handoffProto)
+	for _, tn := range list[1:] {
+		log.ErrFatal(root.handoff.Replicate(tn, []byte("state")))
+		select {
+		case s := <-handoffProtos:
+			succs[s.Index()] = s
+		case <-time.After(time.Second):
+			t.Fatal("Successor didn't get the state")
+		}
+	}
+	winner, loser := succs[list[1].RosterIndex], succs[list[2].RosterIndex]
+	if before(list[2].ID, list[1].ID) {
+		winner, loser = loser, winner
+	}
+	winnerErr, loserErr := make(chan error, 1), make(chan error, 1)
+	go func() { winnerErr <- winner.handoff.TakeOver() }()
+	go func() { loserErr <- loser.handoff.TakeOver() }()
+	for _, c := range []chan error{winnerErr, loserErr} {
+		select {
+		case err := <-c:
+			if c == winnerErr {
+				assert.Nil(t, err)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Successors didn't finish taking over")
+		}
+	}
+
+	// All nodes follow the successor with the smaller ID.
+	for _, n := range []*handoffProto{root, winner, loser} {
+		assert.Equal(t, winner.TreeNode().ID, n.handoff.Leader().ID)
+	}
+	assert.True(t, winner.handoff.IsLeader())
+	assert.False(t, loser.handoff.IsLeader())
+}
+
+func TestHandoffErrors(t *testing.T) {
+	local := NewLocalTest()
+	defer local.CloseAll()
+	_, _, tree := local.GenTree(2, true)
+	handoffEvents = make(chan handoffEvent, 2)
+	handoffProtos = make(chan *handoffProto, 2)
+	p, err := local.CreateProtocol(handoffTestName, tree)
+	log.ErrFatal(err)
+	root := p.(*handoffProto)
+	assert.NotNil(t, root.handoff.Transfer(nil, nil))
+	assert.NotNil(t, root.handoff.Transfer(tree.Root, nil))
+}
+
+// checkHandoff waits for the events of all nodes in idx and checks that they
+// follow the leader, which has to hold the state.
+func checkHandoff(t *testing.T, idx map[int]bool, leader int, state string) {
+	for len(idx) > 0 {
+		select {
+		case e := <-handoffEvents:
+			require.True(t, idx[e.idx], "Unexpected event from", e.idx)
+			delete(idx, e.idx)
+			assert.Equal(t, leader, e.leader)
+			if e.idx == leader {
+				assert.Equal(t, state, string(e.state))
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("Nodes didn't follow the new leader")
+		}
+	}
+}
+
+// handoffProto only hands off its leadership when the test asks for it.
+type handoffProto struct {
+	*TreeNodeInstance
+	handoff *Handoff
+}
+
+func newHandoffProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	h, err := NewHandoff(n, func(leader *TreeNode, state []byte) {
+		handoffEvents <- handoffEvent{n.Index(), leader.RosterIndex, state}
+	})
+	if err != nil {
+		return nil, err
+	}
+	p := &handoffProto{n, h}
+	handoffProtos <- p
+	return p, nil
+}
+
+func (p *handoffProto) Start() error {
+	return nil
+}
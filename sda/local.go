@@ -91,6 +91,20 @@ func (l *LocalTest) CreateProtocol(name string, t *Tree) (ProtocolInstance, erro
 	return nil, errors.New("Didn't find conode for tree-root")
 }
 
+// CreateProtocolWithConfig is like CreateProtocol, but conf is passed to all
+// nodes when their protocol is instantiated. It is available through
+// TreeNodeInstance.Config.
+func (l *LocalTest) CreateProtocolWithConfig(name string, t *Tree, conf *GenericConfig) (ProtocolInstance, error) {
+	rootServerIdentityID := t.Root.ServerIdentity.ID
+	for _, h := range l.Conodes {
+		if h.ServerIdentity.ID.Equal(rootServerIdentityID) {
+			return l.Overlays[h.ServerIdentity.ID].CreateProtocolWithConfig(name,
+				t, ServiceID(uuid.Nil), conf)
+		}
+	}
+	return nil, errors.New("Didn't find conode for tree-root")
+}
+
 // GenConodes returns n Hosts with a localRouter
 func (l *LocalTest) GenConodes(n int) []*Conode {
 	conodes := l.genLocalHosts(n)
@@ -106,17 +120,43 @@ func (l *LocalTest) GenConodes(n int) []*Conode {
 // GenTree will create a tree of n conodes with a localRouter, and returns the
 // list of conodes and the associated roster / tree.
 func (l *LocalTest) GenTree(n int, register bool) ([]*Conode, *Roster, *Tree) {
+	return l.genTree(n, register, func(list *Roster) *Tree {
+		return list.GenerateBinaryTree()
+	})
+}
+
+// GenStarTree works like GenTree, but the root of the returned tree has all
+// other n-1 conodes as direct children.
+func (l *LocalTest) GenStarTree(n int, register bool) ([]*Conode, *Roster, *Tree) {
+	return l.genTree(n, register, func(list *Roster) *Tree {
+		if n < 2 {
+			return list.GenerateNaryTree(1)
+		}
+		return list.GenerateNaryTree(n - 1)
+	})
+}
+
+// GenLineTree works like GenTree, but the returned tree is a chain of depth
+// n-1 where every node has at most one child.
+func (l *LocalTest) GenLineTree(n int, register bool) ([]*Conode, *Roster, *Tree) {
+	return l.genTree(n, register, func(list *Roster) *Tree {
+		return list.GenerateNaryTree(1)
+	})
+}
+
+// genTree creates n conodes and a roster, and uses gen to create the tree
+// out of the roster.
+func (l *LocalTest) genTree(n int, register bool, gen func(*Roster) *Tree) ([]*Conode, *Roster, *Tree) {
 	conodes := l.GenConodes(n)
 
 	list := l.GenRosterFromHost(conodes...)
-	tree := list.GenerateBinaryTree()
+	tree := gen(list)
 	l.Trees[tree.ID] = tree
 	if register {
 		conodes[0].overlay.RegisterRoster(list)
 		conodes[0].overlay.RegisterTree(tree)
 	}
 	return conodes, list, tree
-
 }
 
 // GenBigTree will create a tree of n conodes.
@@ -175,6 +215,26 @@ func (l *LocalTest) CloseAll() {
 	//time.Sleep(time.Millisecond * 500)
 }
 
+// CloseConode closes a single conode and removes it from the LocalTest,
+// while the other conodes keep running. This simulates the failure of a
+// node, for example in the middle of a protocol: messages sent to it will
+// fail with an error.
+func (l *LocalTest) CloseConode(c *Conode) error {
+	id := c.ServerIdentity.ID
+	if _, ok := l.Conodes[id]; !ok {
+		return errors.New("Conode " + c.ServerIdentity.Address.String() +
+			" is not part of this LocalTest")
+	}
+	err := c.Close()
+	for c.Listening() {
+		time.Sleep(10 * time.Millisecond)
+	}
+	delete(l.Conodes, id)
+	delete(l.Overlays, id)
+	delete(l.Services, id)
+	return err
+}
+
 // GetTree returns the tree of the given TreeNode
 func (l *LocalTest) GetTree(tn *TreeNode) *Tree {
 	var tree *Tree
@@ -1,9 +1,16 @@
 package sda
 
 import (
+	"fmt"
 	"testing"
+	"time"
 
 	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/network"
+	"github.com/dedis/crypto/random"
+	"github.com/satori/go.uuid"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestGenLocalHost(t *testing.T) {
@@ -16,3 +23,242 @@ func TestGenLocalHost(t *testing.T) {
 		t.Fatal("Both addresses are equal")
 	}
 }
+
+func TestGenStarTree(t *testing.T) {
+	l := NewLocalTest()
+	defer l.CloseAll()
+	n := 5
+	conodes, roster, tree := l.GenStarTree(n, true)
+	assert.Equal(t, n, len(conodes))
+	assert.Equal(t, n, len(roster.List))
+	assert.Equal(t, n, tree.Size())
+	assert.Equal(t, 1, treeDepth(tree))
+	assert.Equal(t, n-1, len(tree.Root.Children))
+	for _, c := range tree.Root.Children {
+		assert.True(t, c.IsLeaf())
+	}
+}
+
+func TestGenLineTree(t *testing.T) {
+	l := NewLocalTest()
+	defer l.CloseAll()
+	n := 5
+	conodes, roster, tree := l.GenLineTree(n, true)
+	assert.Equal(t, n, len(conodes))
+	assert.Equal(t, n, len(roster.List))
+	assert.Equal(t, n, tree.Size())
+	assert.Equal(t, n-1, treeDepth(tree))
+	tree.Root.Visit(0, func(depth int, tn *TreeNode) {
+		if depth < n-1 {
+			assert.Equal(t, 1, len(tn.Children))
+		} else {
+			assert.True(t, tn.IsLeaf())
+		}
+	})
+}
+
+func TestCloseConode(t *testing.T) {
+	GlobalProtocolRegister(waitName, newWaitProto)
+	l := NewLocalTest()
+	defer l.CloseAll()
+	conodes, _, tree := l.GenTree(2, true)
+	p, err := l.CreateProtocol(waitName, tree)
+	log.ErrFatal(err)
+	done := make(chan error)
+	go func() { done <- p.Start() }()
+	select {
+	case <-waitRequested:
+	case <-time.After(time.Second):
+		t.Fatal("Child didn't get the request")
+	}
+	// The root waits as long as the child is up.
+	select {
+	case err := <-done:
+		t.Fatal("Root returned while the child is up:", err)
+	case <-time.After(300 * time.Millisecond):
+	}
+
+	// Closing the child in the middle of the protocol makes the root fail.
+	require.Nil(t, l.CloseConode(conodes[1]))
+	assert.Equal(t, 1, len(l.Conodes))
+	assert.False(t, conodes[1].Listening())
+	assert.NotNil(t, l.CloseConode(conodes[1]))
+	select {
+	case err := <-done:
+		assert.NotNil(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("Root hangs on closed conode")
+	}
+}
+
+func TestCreateProtocolWithConfig(t *testing.T) {
+	GlobalProtocolRegister(configName, newConfigProto)
+	l := NewLocalTest()
+	defer l.CloseAll()
+	n := 3
+	_, _, tree := l.GenLineTree(n, true)
+	conf := &GenericConfig{Type: uuid.NewV4(), Data: []byte("threshold=2")}
+	p, err := l.CreateProtocolWithConfig(configName, tree, conf)
+	log.ErrFatal(err)
+	log.ErrFatal(p.Start())
+	for i := 0; i < n; i++ {
+		select {
+		case c := <-configReceived:
+			assert.Equal(t, conf, c)
+		case <-time.After(time.Second):
+			t.Fatal("Not all nodes have been instantiated")
+		}
+	}
+}
+
+const configName = "ConfigTest"
+
+var configReceived = make(chan *GenericConfig, 10)
+
+// configProto sends the message down the tree, and reports the config of
+// every node.
+type configProto struct {
+	*TreeNodeInstance
+}
+
+func newConfigProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	configReceived <- n.Config()
+	p := &configProto{n}
+	return p, p.RegisterHandler(p.handleMsg)
+}
+
+func (p *configProto) Start() error {
+	defer p.Done()
+	return p.SendToChildren(&broadcastMsg{})
+}
+
+func (p *configProto) handleMsg(msg struct {
+	*TreeNode
+	broadcastMsg
+}) {
+	defer p.Done()
+	if err := p.SendToChildren(&broadcastMsg{}); err != nil {
+		log.Error(err)
+	}
+}
+
+const waitName = "WaitTest"
+
+var waitRequested = make(chan bool, 10)
+
+type waitRequest struct{}
+
+type waitReply struct{}
+
+// waitProto has the root wait for the reply of its children, which never
+// answer. While waiting, the root pings its children and fails if one of
+// them doesn't answer anymore.
+type waitProto struct {
+	*TreeNodeInstance
+	reply chan bool
+}
+
+func newWaitProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	p := &waitProto{n, make(chan bool, 1)}
+	return p, p.RegisterHandlers(p.handleRequest, p.handleReply)
+}
+
+func (p *waitProto) Start() error {
+	defer p.Done()
+	if err := p.SendToChildren(&waitRequest{}); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-p.reply:
+			return nil
+		case <-time.After(50 * time.Millisecond):
+			for _, c := range p.Children() {
+				err := p.Host().Ping(c.ServerIdentity, 100*time.Millisecond)
+				if err != nil {
+					return fmt.Errorf("Lost %s: %s", c.ServerIdentity, err)
+				}
+			}
+		}
+	}
+}
+
+func (p *waitProto) handleRequest(msg struct {
+	*TreeNode
+	waitRequest
+}) error {
+	waitRequested <- true
+	return nil
+}
+
+func (p *waitProto) handleReply(msg struct {
+	*TreeNode
+	waitReply
+}) error {
+	p.reply <- true
+	return nil
+}
+
+func TestLocalTestChunks(t *testing.T) {
+	defer network.SetChunkSize(0)
+	network.SetChunkSize(network.DefaultChunkSize)
+	GlobalProtocolRegister(chunkName, newChunkProto)
+	// 10MB are sent in 10 chunks
+	chunkData = random.Bytes(10*1024*1024, random.Stream)
+	l := NewLocalTest()
+	defer l.CloseAll()
+	_, _, tree := l.GenTree(2, true)
+	_, err := l.StartProtocol(chunkName, tree)
+	log.ErrFatal(err)
+	select {
+	case data := <-chunkReceived:
+		assert.Equal(t, chunkData, data)
+	case <-time.After(10 * time.Second):
+		t.Fatal("Didn't receive the big message")
+	}
+}
+
+const chunkName = "ChunkTest"
+
+var chunkReceived = make(chan []byte, 1)
+
+// chunkData is sent by the root, it is set by TestLocalTestChunks.
+var chunkData []byte
+
+type chunkMsg struct {
+	Data []byte
+}
+
+// chunkProto sends chunkData from the root to its children.
+type chunkProto struct {
+	*TreeNodeInstance
+}
+
+func newChunkProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	p := &chunkProto{n}
+	return p, p.RegisterHandler(p.handleMsg)
+}
+
+func (p *chunkProto) Start() error {
+	defer p.Done()
+	return p.SendToChildren(&chunkMsg{chunkData})
+}
+
+func (p *chunkProto) handleMsg(msg struct {
+	*TreeNode
+	chunkMsg
+}) {
+	chunkReceived <- msg.Data
+	p.Done()
+}
+
+// treeDepth returns the depth of the deepest leaf, the root having depth 0.
+func treeDepth(tree *Tree) int {
+	max := 0
+	tree.Root.Visit(0, func(depth int, tn *TreeNode) {
+		if depth > max {
+			max = depth
+		}
+	})
+	return max
+}
@@ -0,0 +1,191 @@
+package sda
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/dedis/cothority/log"
+)
+
+// Negotiation lets all nodes of a tree agree on the parameters of a protocol
+// before running it. The root proposes the parameters, every node checks
+// them and can veto. The protocol should only proceed if nobody vetoed.
+//
+// The proposal is sent down the tree, and every node answers its parent once
+// itself and all its children answered, passing up the first veto. Once
+// the root has all answers, it sends the result down the tree.
+type Negotiation struct {
+	tni *TreeNodeInstance
+	// check returns an error if the parameters are not acceptable
+	check  func(map[string]string) error
+	params map[string]string
+	// pending is the number of children that didn't answer yet
+	pending int
+	// veto is the first veto seen, or nil
+	veto   *NegotiationReply
+	result chan error
+	sync.Mutex
+}
+
+// NegotiationPropose is sent down the tree with the parameters.
+type NegotiationPropose struct {
+	Params map[string]string
+}
+
+// NegotiationReply is sent up the tree once a subtree checked the
+// parameters. If Vetoer is not empty, the parameters have been vetoed.
+type NegotiationReply struct {
+	Vetoer string
+	Reason string
+}
+
+// NegotiationResult is sent down the tree by the root with the outcome of
+// the negotiation.
+type NegotiationResult struct {
+	Vetoer string
+	Reason string
+}
+
+// NewNegotiation returns a Negotiation for the given TreeNodeInstance and
+// registers its handlers. It has to be called on every node of the tree,
+// usually in the constructor of the protocol. check is called with the
+// proposed parameters on every node but the root and returns an error to
+// veto them.
+func NewNegotiation(tni *TreeNodeInstance, check func(map[string]string) error) (*Negotiation, error) {
+	n := &Negotiation{
+		tni:    tni,
+		check:  check,
+		result: make(chan error, 1),
+	}
+	err := tni.RegisterHandlers(n.handlePropose, n.handleReply, n.handleResult)
+	if err != nil {
+		return nil, err
+	}
+	return n, nil
+}
+
+// Propose sends the parameters to all nodes and waits for their answers. It
+// returns an error naming the vetoing node if the parameters are refused.
+// It can only be called by the root and, as the answers are received by
+// message-handlers, not from within a handler of the same protocol.
+func (n *Negotiation) Propose(params map[string]string) error {
+	if !n.tni.IsRoot() {
+		return errors.New("Only the root can propose parameters")
+	}
+	n.Lock()
+	n.params = params
+	n.pending = len(n.tni.Children())
+	n.Unlock()
+	if n.tni.IsLeaf() {
+		n.Lock()
+		n.finish()
+		n.Unlock()
+	} else if err := n.tni.SendToChildren(&NegotiationPropose{params}); err != nil {
+		return err
+	}
+	return <-n.result
+}
+
+// Wait blocks until the negotiation is over and returns the parameters
+// proposed by the root, or an error naming the vetoing node. It is used by
+// all nodes but the root.
+func (n *Negotiation) Wait() (map[string]string, error) {
+	err := <-n.result
+	n.Lock()
+	defer n.Unlock()
+	return n.params, err
+}
+
+func (n *Negotiation) handlePropose(msg struct {
+	*TreeNode
+	NegotiationPropose
+}) {
+	n.Lock()
+	defer n.Unlock()
+	n.params = msg.Params
+	if n.check != nil {
+		if err := n.check(msg.Params); err != nil {
+			log.Lvl2(n.tni.Name(), "vetoes parameters:", err)
+			n.vetoWith(err)
+		}
+	}
+	n.pending = len(n.tni.Children())
+	if err := n.tni.SendToChildren(&msg.NegotiationPropose); err != nil {
+		// The children can't answer, so this node vetoes in their place.
+		log.Error(n.tni.Name(), "couldn't forward proposal:", err)
+		n.vetoWith(err)
+		n.pending = 0
+	}
+	if n.pending == 0 {
+		n.finish()
+	}
+}
+
+func (n *Negotiation) handleReply(msg struct {
+	*TreeNode
+	NegotiationReply
+}) {
+	n.Lock()
+	defer n.Unlock()
+	if msg.Vetoer != "" && n.veto == nil {
+		reply := msg.NegotiationReply
+		n.veto = &reply
+	}
+	n.pending--
+	if n.pending == 0 {
+		n.finish()
+	}
+}
+
+func (n *Negotiation) handleResult(msg struct {
+	*TreeNode
+	NegotiationResult
+}) {
+	if err := n.tni.SendToChildren(&msg.NegotiationResult); err != nil {
+		log.Error(n.tni.Name(), "couldn't forward result:", err)
+	}
+	n.result <- msg.err()
+}
+
+// vetoWith records err as the veto of this node, unless there is already a
+// veto. It must be called with the lock held.
+func (n *Negotiation) vetoWith(err error) {
+	if n.veto != nil {
+		return
+	}
+	n.veto = &NegotiationReply{
+		Vetoer: n.tni.ServerIdentity().String(),
+		Reason: err.Error(),
+	}
+}
+
+// finish is called once this node and all its children answered. If the
+// answer can't be sent to the parent, the error is returned by Wait, as the
+// result will never arrive. It must be called with the lock held.
+func (n *Negotiation) finish() {
+	reply := NegotiationReply{}
+	if n.veto != nil {
+		reply = *n.veto
+	}
+	if !n.tni.IsRoot() {
+		if err := n.tni.SendToParent(&reply); err != nil {
+			log.Error(n.tni.Name(), "couldn't answer proposal:", err)
+			n.result <- err
+		}
+		return
+	}
+	res := &NegotiationResult{reply.Vetoer, reply.Reason}
+	if err := n.tni.SendToChildren(res); err != nil {
+		log.Error(n.tni.Name(), "couldn't send result:", err)
+	}
+	n.result <- res.err()
+}
+
+// err returns the error corresponding to the result.
+func (r *NegotiationResult) err() error {
+	if r.Vetoer == "" {
+		return nil
+	}
+	return fmt.Errorf("Parameters vetoed by %s: %s", r.Vetoer, r.Reason)
+}
@@ -0,0 +1,138 @@
+package sda
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/dedis/cothority/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const negotiationTestName = "NegotiationTest"
+
+func init() {
+	GlobalProtocolRegister(negotiationTestName, newNegotiationProto)
+}
+
+func TestNegotiationAccept(t *testing.T) {
+	outcomes := runNegotiation(t, -1)
+	for _, o := range outcomes {
+		assert.Nil(t, o.err)
+		assert.Equal(t, "16", o.params["chunk"])
+	}
+}
+
+func TestNegotiationVeto(t *testing.T) {
+	var vetoer string
+	outcomes := runNegotiation(t, 3)
+	for _, o := range outcomes {
+		require.NotNil(t, o.err)
+		if o.idx == 3 {
+			vetoer = o.name
+		}
+	}
+	require.NotEqual(t, "", vetoer)
+	for _, o := range outcomes {
+		assert.Contains(t, o.err.Error(), vetoer)
+		assert.Contains(t, o.err.Error(), "chunk too big")
+	}
+}
+
+// A node that can't forward the proposal vetoes in place of its children,
+// so that nobody blocks.
+func TestNegotiationUnreachable(t *testing.T) {
+	local := NewLocalTest()
+	defer local.CloseAll()
+	conodes, _, tree := local.GenLineTree(3, true)
+	negotiationVetoer = -1
+	negotiationDone = make(chan negotiationOutcome, 3)
+	leaf := tree.Root.Children[0].Children[0]
+	log.ErrFatal(local.CloseConode(conodes[leaf.RosterIndex]))
+	_, err := local.StartProtocol(negotiationTestName, tree)
+	log.ErrFatal(err)
+	middle := tree.Root.Children[0].ServerIdentity.String()
+	for i := 0; i < 2; i++ {
+		select {
+		case o := <-negotiationDone:
+			require.NotNil(t, o.err)
+			assert.Contains(t, o.err.Error(), middle)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Negotiation didn't finish")
+		}
+	}
+}
+
+type negotiationOutcome struct {
+	idx    int
+	name   string
+	params map[string]string
+	err    error
+}
+
+var negotiationVetoer int
+var negotiationDone chan negotiationOutcome
+
+// runNegotiation runs the test-protocol on a tree of 7 nodes where the node
+// with index veto refuses the parameters.
+func runNegotiation(t *testing.T, veto int) []negotiationOutcome {
+	local := NewLocalTest()
+	defer local.CloseAll()
+	nbrNodes := 7
+	_, _, tree := local.GenTree(nbrNodes, true)
+	negotiationVetoer = veto
+	negotiationDone = make(chan negotiationOutcome, nbrNodes)
+	_, err := local.StartProtocol(negotiationTestName, tree)
+	log.ErrFatal(err)
+	var outcomes []negotiationOutcome
+	for i := 0; i < nbrNodes; i++ {
+		select {
+		case o := <-negotiationDone:
+			outcomes = append(outcomes, o)
+		case <-time.After(5 * time.Second):
+			t.Fatal("Negotiation didn't finish")
+		}
+	}
+	return outcomes
+}
+
+// negotiationProto negotiates the parameters and reports the outcome.
+type negotiationProto struct {
+	*TreeNodeInstance
+	negotiation *Negotiation
+}
+
+func newNegotiationProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	neg, err := NewNegotiation(n, func(params map[string]string) error {
+		if n.Index() == negotiationVetoer {
+			return errors.New("chunk too big")
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	p := &negotiationProto{n, neg}
+	if !n.IsRoot() {
+		go func() {
+			params, err := neg.Wait()
+			p.report(params, err)
+		}()
+	}
+	return p, nil
+}
+
+func (p *negotiationProto) Start() error {
+	go func() {
+		params := map[string]string{"chunk": "16"}
+		p.report(params, p.negotiation.Propose(params))
+	}()
+	return nil
+}
+
+func (p *negotiationProto) report(params map[string]string, err error) {
+	negotiationDone <- negotiationOutcome{p.Index(), p.ServerIdentity().String(),
+		params, err}
+	p.Done()
+}
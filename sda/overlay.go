@@ -182,12 +182,20 @@ func (o *Overlay) TransmitMsg(sdaMsg *ProtocolMsg) error {
 	}
 	// if the TreeNodeInstance is not there, creates it
 	if !ok {
+		err := o.conode.protocols.ValidateConfig(sdaMsg.To.ProtoID, &sdaMsg.Config)
+		if err != nil {
+			return err
+		}
 		log.Lvlf4("Creating TreeNodeInstance at %s %x", o.conode.ServerIdentity, sdaMsg.To.ID())
 		tn, err := o.TreeNodeFromToken(sdaMsg.To)
 		if err != nil {
 			return errors.New("No TreeNode defined in this tree here")
 		}
 		tni := o.newTreeNodeInstanceFromToken(tn, sdaMsg.To)
+		if sdaMsg.Config.Type != uuid.Nil || len(sdaMsg.Config.Data) > 0 {
+			conf := sdaMsg.Config
+			tni.config = &conf
+		}
 		// see if we know the Service Recipient
 		s, ok := o.conode.serviceManager.serviceByID(sdaMsg.To.ServiceID)
 
@@ -227,8 +235,14 @@ func (o *Overlay) TransmitMsg(sdaMsg *ProtocolMsg) error {
 }
 
 // sendSDAData marshals the inner msg and then sends a Data msg
-// to the appropriate entity
-func (o *Overlay) sendSDAData(si *network.ServerIdentity, sdaMsg *ProtocolMsg) error {
+// to the appropriate entity. If batched is true, the message is only
+// buffered, see network.Router.SendBatched.
+func (o *Overlay) sendSDAData(si *network.ServerIdentity, sdaMsg *ProtocolMsg, batched bool) error {
+	msg, err := network.Hop(sdaMsg.Msg)
+	if err != nil {
+		return err
+	}
+	sdaMsg.Msg = msg
 	b, err := network.MarshalRegisteredType(sdaMsg.Msg)
 	if err != nil {
 		return fmt.Errorf("Error marshaling message: %s (msg = %+v)", err.Error(), sdaMsg.Msg)
@@ -239,6 +253,9 @@ func (o *Overlay) sendSDAData(si *network.ServerIdentity, sdaMsg *ProtocolMsg) e
 	// other side (because it doesn't know how to decode it)
 	sdaMsg.Msg = nil
 	log.Lvl4(o.conode.Address(), "Sending to", si.Address)
+	if batched {
+		return o.conode.SendBatched(si, sdaMsg)
+	}
 	return o.conode.Send(si, sdaMsg)
 }
 
@@ -386,13 +403,25 @@ func (o *Overlay) TreeNodeFromToken(t *Token) (*TreeNode, error) {
 
 // SendToTreeNode sends a message to a treeNode
 func (o *Overlay) SendToTreeNode(from *Token, to *TreeNode, msg network.Body) error {
+	_, err := o.sendToTreeNode(from, to, msg, nil, false)
+	return err
+}
+
+// sendToTreeNode is like SendToTreeNode but also returns the size of the
+// marshalled message. If conf is not nil, it is sent along with the message.
+// If batched is true, the message is only buffered.
+func (o *Overlay) sendToTreeNode(from *Token, to *TreeNode, msg network.Body, conf *GenericConfig, batched bool) (int, error) {
 	sda := &ProtocolMsg{
 		Msg:  msg,
 		From: from,
 		To:   from.ChangeTreeNodeID(to.ID),
 	}
+	if conf != nil {
+		sda.Config = *conf
+	}
 	log.Lvl4(o.conode.Address(), "Sending to entity", to.ServerIdentity.Address)
-	return o.sendSDAData(to.ServerIdentity, sda)
+	err := o.sendSDAData(to.ServerIdentity, sda, batched)
+	return len(sda.MsgSlice), err
 }
 
 // nodeDone is called by node to signify that its work is finished and its
@@ -445,7 +474,25 @@ func (o *Overlay) CreateProtocolSDA(name string, t *Tree) (ProtocolInstance, err
 // CreateProtocolService adds the service-id to the token so the protocol will
 // be picked up by the correct service and handled by its NewProtocol method.
 func (o *Overlay) CreateProtocolService(name string, t *Tree, sid ServiceID) (ProtocolInstance, error) {
+	return o.CreateProtocolWithConfig(name, t, sid, nil)
+}
+
+// CreateProtocolWithConfig is like CreateProtocolService, but conf is sent
+// along with the messages of the protocol. So every node gets conf in the
+// NewProtocol-method of the service, or through TreeNodeInstance.Config if
+// no service is given.
+func (o *Overlay) CreateProtocolWithConfig(name string, t *Tree, sid ServiceID, conf *GenericConfig) (ProtocolInstance, error) {
+	// The other nodes would refuse an invalid config, so fail here already
+	// instead of leaving the root waiting for them.
+	check := conf
+	if check == nil {
+		check = &GenericConfig{}
+	}
+	if err := o.conode.protocols.ValidateConfig(ProtocolNameToID(name), check); err != nil {
+		return nil, err
+	}
 	tni := o.NewTreeNodeInstanceFromService(t, t.Root, ProtocolNameToID(name), sid)
+	tni.config = conf
 	pi, err := o.conode.ProtocolInstantiate(tni.token.ProtoID, tni)
 	if err != nil {
 		return nil, err
@@ -0,0 +1,105 @@
+package sda
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/network"
+)
+
+// Ping is sent by Conode.Ping, the remote conode answers with a Pong
+// holding the same Nonce.
+type Ping struct {
+	Nonce uint64
+}
+
+// Pong is the answer to a Ping.
+type Pong struct {
+	Nonce uint64
+}
+
+// PingMessageID of Ping message as registered in network
+var PingMessageID = network.RegisterPacketType(Ping{})
+
+// PongMessageID of Pong message as registered in network
+var PongMessageID = network.RegisterPacketType(Pong{})
+
+// Pinger is implemented by Conode and Context and is used by
+// Tree.CheckReachable.
+type Pinger interface {
+	Ping(si *network.ServerIdentity, timeout time.Duration) error
+}
+
+// pinger answers the pings of other conodes and waits for the pongs of
+// its own pings.
+type pinger struct {
+	conode  *Conode
+	nonce   uint64
+	pending map[uint64]chan bool
+	sync.Mutex
+}
+
+func newPinger(c *Conode) *pinger {
+	p := &pinger{
+		conode:  c,
+		pending: make(map[uint64]chan bool),
+	}
+	c.RegisterProcessor(p, PingMessageID, PongMessageID)
+	return p
+}
+
+// Process implements the Processor-interface.
+func (p *pinger) Process(packet *network.Packet) {
+	switch msg := packet.Msg.(type) {
+	case Ping:
+		if err := p.conode.Send(packet.ServerIdentity, &Pong{msg.Nonce}); err != nil {
+			log.Lvl2(p.conode.Address(), "couldn't answer ping:", err)
+		}
+	case Pong:
+		p.Lock()
+		ch, ok := p.pending[msg.Nonce]
+		p.Unlock()
+		if ok {
+			ch <- true
+		}
+	}
+}
+
+// ping sends a Ping to si and waits for the Pong.
+func (p *pinger) ping(si *network.ServerIdentity, timeout time.Duration) error {
+	if si.ID == p.conode.ServerIdentity.ID {
+		return nil
+	}
+	p.Lock()
+	nonce := p.nonce
+	p.nonce++
+	ch := make(chan bool, 1)
+	p.pending[nonce] = ch
+	p.Unlock()
+	defer func() {
+		p.Lock()
+		delete(p.pending, nonce)
+		p.Unlock()
+	}()
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- p.conode.Send(si, &Ping{nonce})
+	}()
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return err
+		}
+	case <-time.After(timeout):
+		return errors.New("Timeout while sending ping to " + si.String())
+	}
+	select {
+	case <-ch:
+		return nil
+	case <-time.After(timeout):
+		return errors.New("Timeout while waiting for pong of " + si.String())
+	}
+}
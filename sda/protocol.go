@@ -2,6 +2,8 @@ package sda
 
 import (
 	"fmt"
+	"reflect"
+	"sort"
 
 	"github.com/dedis/cothority/log"
 	"github.com/dedis/cothority/network"
@@ -40,6 +42,19 @@ type ProtocolInstance interface {
 	Shutdown() error
 }
 
+// ConfigValidator is called with the GenericConfig of a protocol before
+// the protocol is instantiated. If it returns an error, the protocol is not
+// instantiated.
+type ConfigValidator func(*GenericConfig) error
+
+// Schema describes the type of the input a protocol takes and the type of
+// the output it returns. It allows to check that the output of one protocol
+// can be used as the input of the next protocol in a pipeline.
+type Schema struct {
+	Input  reflect.Type
+	Output reflect.Type
+}
+
 var protocols = newProtocolStorage()
 
 // protocolStorage holds all protocols either globally or per-Conode.
@@ -47,12 +62,18 @@ type protocolStorage struct {
 	// Instantiators maps the name of the protocols to the `NewProtocol`-
 	// methods.
 	instantiators map[string]NewProtocol
+	// validators maps the name of the protocols to their ConfigValidator
+	validators map[string]ConfigValidator
+	// schemas maps the name of the protocols to their Schema
+	schemas map[string]Schema
 }
 
 // newProtocolStorage returns an initialized ProtocolStorage-struct.
 func newProtocolStorage() *protocolStorage {
 	return &protocolStorage{
 		instantiators: map[string]NewProtocol{},
+		validators:    map[string]ConfigValidator{},
+		schemas:       map[string]Schema{},
 	}
 }
 
@@ -66,6 +87,16 @@ func (ps *protocolStorage) ProtocolIDToName(id ProtocolID) string {
 	return ""
 }
 
+// names returns the sorted names of all registered protocols.
+func (ps *protocolStorage) names() []string {
+	names := make([]string, 0, len(ps.instantiators))
+	for n := range ps.instantiators {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	return names
+}
+
 // ProtocolExists returns whether a certain protocol already has been
 // registered.
 func (ps *protocolStorage) ProtocolExists(protoID ProtocolID) bool {
@@ -87,6 +118,34 @@ func (ps *protocolStorage) Register(name string, protocol NewProtocol) (Protocol
 	return id, nil
 }
 
+// RegisterValidator stores a ConfigValidator for the protocol with the given
+// name. An already registered validator is replaced.
+func (ps *protocolStorage) RegisterValidator(name string, v ConfigValidator) {
+	ps.validators[name] = v
+	log.Lvl4("Registered validator for", name)
+}
+
+// RegisterSchema stores the Schema for the protocol with the given name.
+// An already registered schema is replaced.
+func (ps *protocolStorage) RegisterSchema(name string, s Schema) {
+	ps.schemas[name] = s
+	log.Lvl4("Registered schema for", name)
+}
+
+// ValidateConfig calls the ConfigValidator registered for the protocol and
+// returns its error. If no validator is registered, nil is returned.
+func (ps *protocolStorage) ValidateConfig(protoID ProtocolID, conf *GenericConfig) error {
+	v, ok := ps.validators[ps.ProtocolIDToName(protoID)]
+	if !ok {
+		return nil
+	}
+	if err := v(conf); err != nil {
+		return fmt.Errorf("Invalid config for protocol %s: %s",
+			ps.ProtocolIDToName(protoID), err)
+	}
+	return nil
+}
+
 // ProtocolNameToID returns the ProtocolID corresponding to the given name.
 func ProtocolNameToID(name string) ProtocolID {
 	url := network.NamespaceURL + "protocolname/" + name
@@ -100,3 +159,55 @@ func ProtocolNameToID(name string) ProtocolID {
 func GlobalProtocolRegister(name string, protocol NewProtocol) (ProtocolID, error) {
 	return protocols.Register(name, protocol)
 }
+
+// RegisteredProtocols returns the sorted names of all protocols registered
+// with GlobalProtocolRegister.
+func RegisteredProtocols() []string {
+	return protocols.names()
+}
+
+// GlobalConfigValidatorRegister registers a ConfigValidator for the protocol
+// in the global namespace. The validator is called before the protocol is
+// instantiated for a ProtocolMsg, so that bad requests fail before any
+// TreeNodeInstance is created.
+func GlobalConfigValidatorRegister(name string, v ConfigValidator) {
+	protocols.RegisterValidator(name, v)
+}
+
+// GlobalSchemaRegister registers the Schema of the protocol in the global
+// namespace. input and output are values of the types the protocol takes
+// and returns, for example pointers to empty message-structures.
+func GlobalSchemaRegister(name string, input, output interface{}) {
+	protocols.RegisterSchema(name, Schema{
+		Input:  reflect.TypeOf(input),
+		Output: reflect.TypeOf(output),
+	})
+}
+
+// ProtocolSchema returns the Schema registered for the protocol with the
+// given name. If no Schema is registered, false is returned.
+func ProtocolSchema(name string) (Schema, bool) {
+	s, ok := protocols.schemas[name]
+	return s, ok
+}
+
+// CheckPipeline verifies that the protocols can be run one after the other,
+// each one getting the output of the previous one as input. It returns an
+// error if a protocol has no Schema or if the types don't match.
+func CheckPipeline(names ...string) error {
+	var previous string
+	var output reflect.Type
+	for i, name := range names {
+		s, ok := ProtocolSchema(name)
+		if !ok {
+			return fmt.Errorf("No schema registered for protocol %s", name)
+		}
+		if i > 0 && s.Input != output {
+			return fmt.Errorf("Output %s of protocol %s doesn't match input %s of protocol %s",
+				output, previous, s.Input, name)
+		}
+		previous = name
+		output = s.Output
+	}
+	return nil
+}
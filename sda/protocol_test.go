@@ -3,6 +3,7 @@ package sda
 import (
 	"errors"
 	"fmt"
+	"reflect"
 	"testing"
 
 	"github.com/dedis/cothority/log"
@@ -139,3 +140,73 @@ func TestProtocolAutomaticInstantiation(t *testing.T) {
 	// entity list from h1
 	<-chanH2
 }
+
+// Tests that a registered ConfigValidator stops the instantiation of a
+// protocol before any TreeNodeInstance is created.
+func TestProtocolValidateConfig(t *testing.T) {
+	name := "validatedProto"
+	GlobalProtocolRegister(name, NewProtocolTest)
+	GlobalConfigValidatorRegister(name, func(c *GenericConfig) error {
+		if c.Type == uuid.Nil {
+			return errors.New("empty config")
+		}
+		return nil
+	})
+	local := NewLocalTest()
+	defer local.CloseAll()
+	h, list, tree := local.GenTree(2, true)
+	h[1].overlay.RegisterRoster(list)
+	h[1].overlay.RegisterTree(tree)
+
+	tok := &Token{
+		RosterID:   list.ID,
+		TreeID:     tree.ID,
+		ProtoID:    ProtocolNameToID(name),
+		TreeNodeID: tree.Root.Children[0].ID,
+		RoundID:    RoundID(uuid.NewV4()),
+	}
+	err := h[1].overlay.TransmitMsg(&ProtocolMsg{From: tok, To: tok})
+	require.NotNil(t, err)
+	h[1].overlay.instancesLock.Lock()
+	require.Equal(t, 0, len(h[1].overlay.instances))
+	h[1].overlay.instancesLock.Unlock()
+
+	// The root refuses the config before creating its instance.
+	_, err = local.CreateProtocolWithConfig(name, tree, &GenericConfig{})
+	require.NotNil(t, err)
+	_, err = local.CreateProtocol(name, tree)
+	require.NotNil(t, err)
+	h[0].overlay.instancesLock.Lock()
+	require.Equal(t, 0, len(h[0].overlay.instances))
+	h[0].overlay.instancesLock.Unlock()
+	_, err = local.CreateProtocolWithConfig(name, tree,
+		&GenericConfig{Type: uuid.NewV4()})
+	require.Nil(t, err)
+
+	require.NotNil(t, h[1].ValidateConfig(name, &GenericConfig{}))
+	require.Nil(t, h[1].ValidateConfig(name, &GenericConfig{Type: uuid.NewV4()}))
+	require.Nil(t, h[1].ValidateConfig(testProto, &GenericConfig{}))
+}
+
+type schemaNumbers struct {
+	Numbers []int
+}
+
+type schemaSum struct {
+	Sum int
+}
+
+func TestProtocolSchema(t *testing.T) {
+	GlobalSchemaRegister("schemaShuffle", &schemaNumbers{}, &schemaNumbers{})
+	GlobalSchemaRegister("schemaAdd", &schemaNumbers{}, &schemaSum{})
+	s, ok := ProtocolSchema("schemaAdd")
+	require.True(t, ok)
+	require.Equal(t, reflect.TypeOf(&schemaNumbers{}), s.Input)
+	require.Equal(t, reflect.TypeOf(&schemaSum{}), s.Output)
+	_, ok = ProtocolSchema("schemaUnknown")
+	require.False(t, ok)
+
+	require.Nil(t, CheckPipeline("schemaShuffle", "schemaShuffle", "schemaAdd"))
+	require.NotNil(t, CheckPipeline("schemaAdd", "schemaShuffle"))
+	require.NotNil(t, CheckPipeline("schemaShuffle", "schemaUnknown"))
+}
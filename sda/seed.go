@@ -0,0 +1,108 @@
+package sda
+
+import (
+	"crypto/cipher"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/dedis/crypto/random"
+	"github.com/satori/go.uuid"
+)
+
+// The random sources of sda can be seeded to reproduce a simulation. Once
+// SetSeed is called with a seed different from 0, the following values are
+// derived from that seed only:
+//  - the keys created by SimulationBFTree.CreateRoster
+//  - the IDs of the Roster and the TreeNodes created by
+//    SimulationBFTree.CreateRoster and SimulationBFTree.CreateTree
+//  - the ServerIdentity returned by Roster.RandomServerIdentity
+// NewRoster and NewTreeNode always use random IDs, so that a seed set for a
+// simulation doesn't leak into the IDs used elsewhere.
+// The trees created by the Generate*Tree-methods don't use any randomness, so
+// they are the same for the same Roster.
+// The seeded sources are not suited for anything but simulations and tests,
+// as the keys can be recreated by anybody knowing the seed.
+
+// seeded is nil if no seed is set.
+var seeded struct {
+	rand *rand.Rand
+	sync.Mutex
+}
+
+// timeRand is used for RandomServerIdentity if no seed is set.
+var timeRand = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+// SetSeed makes the random sources of sda deterministic, starting from seed.
+// Calling it with the same seed again restarts the same sequence of values.
+// A seed of 0 goes back to the non-deterministic sources.
+func SetSeed(seed int64) {
+	seeded.Lock()
+	defer seeded.Unlock()
+	if seed == 0 {
+		seeded.rand = nil
+		return
+	}
+	seeded.rand = rand.New(rand.NewSource(seed))
+}
+
+// Seeded returns true if a seed different from 0 is set.
+func Seeded() bool {
+	seeded.Lock()
+	defer seeded.Unlock()
+	return seeded.rand != nil
+}
+
+// randomStream returns the stream to be used to create keys: random.Stream
+// if no seed is set, or a stream derived from the seed.
+func randomStream() cipher.Stream {
+	seeded.Lock()
+	defer seeded.Unlock()
+	if seeded.rand == nil {
+		return random.Stream
+	}
+	return seededStream{}
+}
+
+// seededStream implements cipher.Stream using the seeded source.
+type seededStream struct{}
+
+func (s seededStream) XORKeyStream(dst, src []byte) {
+	seeded.Lock()
+	defer seeded.Unlock()
+	if seeded.rand == nil {
+		random.Stream.XORKeyStream(dst, src)
+		return
+	}
+	for i := range src {
+		dst[i] = src[i] ^ byte(seeded.rand.Intn(256))
+	}
+}
+
+// randomUUID returns a version 4 UUID, derived from the seed if one is set.
+// It is only used by the methods of SimulationBFTree.
+func randomUUID() uuid.UUID {
+	seeded.Lock()
+	defer seeded.Unlock()
+	if seeded.rand == nil {
+		return uuid.NewV4()
+	}
+	var u uuid.UUID
+	for i := range u {
+		u[i] = byte(seeded.rand.Intn(256))
+	}
+	// set version 4 and the RFC 4122 variant
+	u[6] = (u[6] & 0x0f) | 0x40
+	u[8] = (u[8] & 0x3f) | 0x80
+	return u
+}
+
+// randomInt returns a non-negative int, derived from the seed if one is set.
+func randomInt() int {
+	seeded.Lock()
+	defer seeded.Unlock()
+	if seeded.rand == nil {
+		return timeRand.Int()
+	}
+	return seeded.rand.Int()
+}
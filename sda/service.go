@@ -60,6 +60,9 @@ type NewServiceFunc func(c *Context, path string) Service
 // protocols. It is passed down to the service NewProtocol function.
 type GenericConfig struct {
 	Type uuid.UUID
+	// Data holds the parameters of a run of the protocol, e.g. encoded
+	// using network.MarshalRegisteredType.
+	Data []byte
 }
 
 // GenericConfigID is the ID used by the network library for sending / receiving
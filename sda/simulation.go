@@ -170,12 +170,20 @@ func SimulationRegister(name string, sim simulationCreate) {
 }
 
 // NewSimulation returns a simulation and decodes the 'conf' into the
-// simulation-structure
+// simulation-structure. If 'conf' has a 'Seed' different from 0, it is
+// passed to SetSeed, so that the simulation can be reproduced, else the
+// random sources are reset to be non-deterministic. As the seed is global to
+// sda, this replaces any seed set before with SetSeed.
 func NewSimulation(name string, conf string) (Simulation, error) {
 	sim, ok := simulationRegistered[name]
 	if !ok {
 		return nil, errors.New("Didn't find simulation " + name)
 	}
+	var seed struct{ Seed int64 }
+	if _, err := toml.Decode(conf, &seed); err != nil {
+		return nil, err
+	}
+	SetSeed(seed.Seed)
 	simInst, err := sim(conf)
 	if err != nil {
 		return nil, err
@@ -195,6 +203,8 @@ type SimulationBFTree struct {
 	Hosts      int
 	SingleHost bool
 	Depth      int
+	// Seed is used by NewSimulation to make the simulation reproducible
+	Seed int64
 }
 
 // CreateRoster creates an Roster with the host-names in 'addresses'.
@@ -223,7 +233,8 @@ func (s *SimulationBFTree) CreateRoster(sc *SimulationConfig, addresses []string
 	}
 	entities := make([]*network.ServerIdentity, hosts)
 	log.Lvl3("Doing", hosts, "hosts")
-	key := config.NewKeyPair(network.Suite)
+	key := new(config.KeyPair)
+	key.Gen(network.Suite, randomStream())
 	for c := 0; c < hosts; c++ {
 		key.Secret.Add(key.Secret,
 			key.Suite.Scalar().One())
@@ -268,6 +279,9 @@ func (s *SimulationBFTree) CreateRoster(sc *SimulationConfig, addresses []string
 	}
 
 	sc.Roster = NewRoster(entities)
+	if Seeded() {
+		sc.Roster.ID = RosterID(randomUUID())
+	}
 	log.Lvl3("Creating entity List took: " + time.Now().Sub(start).String())
 }
 
@@ -280,6 +294,13 @@ func (s *SimulationBFTree) CreateTree(sc *SimulationConfig) error {
 		return errors.New("Empty Roster")
 	}
 	sc.Tree = sc.Roster.GenerateBigNaryTree(s.BF, s.Hosts)
+	if Seeded() {
+		for _, tn := range sc.Tree.List() {
+			tn.ID = TreeNodeID(randomUUID())
+		}
+		// the ID of the tree depends on the ID of the root
+		sc.Tree = NewTree(sc.Roster, sc.Tree.Root)
+	}
 	log.Lvl3("Creating tree took: " + time.Now().Sub(start).String())
 	return nil
 }
@@ -10,6 +10,8 @@ import (
 	"time"
 
 	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/network"
+	"github.com/stretchr/testify/assert"
 )
 
 func TestSimulationBF(t *testing.T) {
@@ -114,6 +116,60 @@ func closeAll(scs []*SimulationConfig) {
 	}
 }
 
+func TestSimulationSeed(t *testing.T) {
+	defer SetSeed(0)
+	run := func(seed int64) ([]byte, string) {
+		SetSeed(seed)
+		sc, _, err := createBFTree(7, 2, []string{"test1", "test2"})
+		log.ErrFatal(err)
+		buf, err := network.MarshalRegisteredType(sc.Tree.MakeTreeMarshal())
+		log.ErrFatal(err)
+		root := sc.Tree.Root.ServerIdentity.Address
+		return buf, sc.PrivateKeys[root].String()
+	}
+	tree1, key1 := run(42)
+	tree2, key2 := run(42)
+	assert.Equal(t, tree1, tree2, "Same seed should give the same tree")
+	assert.Equal(t, key1, key2, "Same seed should give the same keys")
+
+	tree3, key3 := run(0)
+	assert.NotEqual(t, tree1, tree3)
+	assert.NotEqual(t, key1, key3)
+
+	// the seed is only used for the simulation
+	SetSeed(42)
+	id1 := NewTreeNode(0, nil).ID
+	SetSeed(42)
+	assert.False(t, id1.Equal(NewTreeNode(0, nil).ID))
+	SetSeed(0)
+
+	sim, err := NewSimulation("SeedTest", "Seed = 42\nHosts = 7\nBF = 2")
+	log.ErrFatal(err)
+	assert.True(t, Seeded())
+	assert.Equal(t, int64(42), sim.(*seedSimulation).Seed)
+	_, err = NewSimulation("SeedTest", "Hosts = 7")
+	log.ErrFatal(err)
+	assert.False(t, Seeded())
+}
+
+func init() {
+	SimulationRegister("SeedTest", func(string) (Simulation, error) {
+		return &seedSimulation{}, nil
+	})
+}
+
+type seedSimulation struct {
+	SimulationBFTree
+}
+
+func (s *seedSimulation) Setup(dir string, hosts []string) (*SimulationConfig, error) {
+	return nil, nil
+}
+
+func (s *seedSimulation) Run(config *SimulationConfig) error {
+	return nil
+}
+
 func createBFTree(hosts, bf int, addresses []string) (*SimulationConfig, *SimulationBFTree, error) {
 	sc := &SimulationConfig{}
 	sb := &SimulationBFTree{
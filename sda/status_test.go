@@ -26,6 +26,8 @@ func TestStatusHost(t *testing.T) {
 	a := ServiceFactory.RegisteredServiceNames()
 	services := strings.Split(stats["Available_Services"], ",")
 	assert.Equal(t, len(services), len(a))
+	assert.Equal(t, "0", stats["Connection_Hits"])
+	assert.Equal(t, "0", stats["Connection_Misses"])
 }
 
 type dummyTestReporter struct {
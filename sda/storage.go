@@ -0,0 +1,132 @@
+package sda
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"sync"
+)
+
+// ErrNotStored is returned by StorageBackend.Load if nothing has been saved
+// under the given key.
+var ErrNotStored = errors.New("Nothing stored under this key")
+
+// StorageBackend is used by services to persist their data instead of
+// accessing files directly. This allows to run conodes in environments
+// without a persistent file-system, or to keep secrets in an external
+// key-value store. Keys may contain '/' to structure the data.
+type StorageBackend interface {
+	// Save stores data under the given key, overwriting any former data.
+	Save(key string, data []byte) error
+	// Load returns the data stored under the key or ErrNotStored.
+	Load(key string) ([]byte, error)
+	// Delete removes the key. Deleting a missing key is not an error.
+	Delete(key string) error
+}
+
+// defaultStorage is used by all new conodes if it is not nil, else they
+// store in the config-folder.
+var defaultStorage StorageBackend
+
+// SetDefaultStorage sets the StorageBackend used by all conodes created
+// afterwards. If s is nil, the conodes store their data in files.
+func SetDefaultStorage(s StorageBackend) {
+	defaultStorage = s
+}
+
+// FileStorage implements StorageBackend by writing every key to a file
+// in its directory.
+type FileStorage struct {
+	dir string
+}
+
+// NewFileStorage returns a FileStorage storing its files in dir.
+func NewFileStorage(dir string) *FileStorage {
+	return &FileStorage{dir: dir}
+}
+
+// Save writes data to the file named key.
+func (fs *FileStorage) Save(key string, data []byte) error {
+	name := path.Join(fs.dir, key)
+	if err := os.MkdirAll(path.Dir(name), 0770); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(name, data, 0660)
+}
+
+// Load reads the file named key.
+func (fs *FileStorage) Load(key string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path.Join(fs.dir, key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotStored
+	}
+	return data, err
+}
+
+// Delete removes the file named key.
+func (fs *FileStorage) Delete(key string) error {
+	err := os.Remove(path.Join(fs.dir, key))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+// MemoryStorage implements StorageBackend in memory, it is useful for
+// tests and stateless deployments.
+type MemoryStorage struct {
+	data map[string][]byte
+	sync.Mutex
+}
+
+// NewMemoryStorage returns an empty MemoryStorage.
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{data: make(map[string][]byte)}
+}
+
+// Save stores a copy of data.
+func (ms *MemoryStorage) Save(key string, data []byte) error {
+	ms.Lock()
+	defer ms.Unlock()
+	ms.data[key] = append([]byte{}, data...)
+	return nil
+}
+
+// Load returns a copy of the stored data.
+func (ms *MemoryStorage) Load(key string) ([]byte, error) {
+	ms.Lock()
+	defer ms.Unlock()
+	data, ok := ms.data[key]
+	if !ok {
+		return nil, ErrNotStored
+	}
+	return append([]byte{}, data...), nil
+}
+
+// Delete removes the key.
+func (ms *MemoryStorage) Delete(key string) error {
+	ms.Lock()
+	defer ms.Unlock()
+	delete(ms.data, key)
+	return nil
+}
+
+// serviceStorage prefixes all keys with the name of the service, so that
+// services don't overwrite each other's data.
+type serviceStorage struct {
+	backend StorageBackend
+	prefix  string
+}
+
+func (ss *serviceStorage) Save(key string, data []byte) error {
+	return ss.backend.Save(path.Join(ss.prefix, key), data)
+}
+
+func (ss *serviceStorage) Load(key string) ([]byte, error) {
+	return ss.backend.Load(path.Join(ss.prefix, key))
+}
+
+func (ss *serviceStorage) Delete(key string) error {
+	return ss.backend.Delete(path.Join(ss.prefix, key))
+}
@@ -0,0 +1,86 @@
+package sda
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/dedis/cothority/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const storageServiceName = "StorageService"
+
+// storageService loads its state at startup and saves it on request, like
+// services keeping an identity do.
+type storageService struct {
+	*DummyService
+	c     *Context
+	state []byte
+}
+
+func newStorageService(c *Context, path string) Service {
+	s := &storageService{DummyService: &DummyService{}, c: c}
+	s.tryLoad()
+	return s
+}
+
+func (s *storageService) save() error {
+	return s.c.Storage().Save("state.bin", s.state)
+}
+
+func (s *storageService) tryLoad() {
+	state, err := s.c.Storage().Load("state.bin")
+	if err != nil {
+		if err != ErrNotStored {
+			log.Error(err)
+		}
+		return
+	}
+	s.state = state
+}
+
+func TestStorageServiceRoundTrip(t *testing.T) {
+	ms := NewMemoryStorage()
+	SetDefaultStorage(ms)
+	defer SetDefaultStorage(nil)
+	log.ErrFatal(RegisterNewService(storageServiceName, newStorageService))
+	defer UnregisterService(storageServiceName)
+
+	local := NewLocalTest()
+	c := local.GenConodes(1)[0]
+	s := c.GetService(storageServiceName).(*storageService)
+	assert.Nil(t, s.state)
+	s.state = []byte("identity")
+	log.ErrFatal(s.save())
+	local.CloseAll()
+
+	data, err := ms.Load(storageServiceName + "/state.bin")
+	log.ErrFatal(err)
+	assert.Equal(t, []byte("identity"), data)
+
+	local = NewLocalTest()
+	defer local.CloseAll()
+	c = local.GenConodes(1)[0]
+	s = c.GetService(storageServiceName).(*storageService)
+	assert.Equal(t, []byte("identity"), s.state)
+}
+
+func TestFileStorage(t *testing.T) {
+	dir, err := ioutil.TempDir("", "storage")
+	log.ErrFatal(err)
+	defer os.RemoveAll(dir)
+	fs := NewFileStorage(dir)
+
+	_, err = fs.Load("service/key")
+	assert.Equal(t, ErrNotStored, err)
+	require.Nil(t, fs.Save("service/key", []byte("data")))
+	data, err := fs.Load("service/key")
+	log.ErrFatal(err)
+	assert.Equal(t, []byte("data"), data)
+	assert.Nil(t, fs.Delete("service/key"))
+	assert.Nil(t, fs.Delete("service/key"))
+	_, err = fs.Load("service/key")
+	assert.Equal(t, ErrNotStored, err)
+}
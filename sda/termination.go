@@ -0,0 +1,84 @@
+package sda
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/dedis/cothority/log"
+)
+
+// Termination lets the root tell all nodes of a tree that the protocol
+// finished successfully. Nodes that only forward messages never learn from
+// the protocol itself when the root is done, so they can't know when to
+// release their state.
+//
+// Once the root calls Terminate, a TerminationMessage is sent down the tree.
+// Every node forwards it to its children, calls the onTerminate-hook given
+// to NewTermination and then calls Done, so that its resources are released.
+// A protocol using Termination should not call Done itself.
+type Termination struct {
+	tni         *TreeNodeInstance
+	onTerminate func()
+	terminated  chan bool
+	once        sync.Once
+}
+
+// TerminationMessage is sent down the tree once the root terminated the
+// protocol.
+type TerminationMessage struct{}
+
+// NewTermination returns a Termination for the given TreeNodeInstance and
+// registers the handler for the termination-message. It has to be called on
+// every node of the tree, usually in the constructor of the protocol.
+// onTerminate is called on every node once the protocol terminated and can
+// be nil.
+func NewTermination(tni *TreeNodeInstance, onTerminate func()) (*Termination, error) {
+	t := &Termination{
+		tni:         tni,
+		onTerminate: onTerminate,
+		terminated:  make(chan bool),
+	}
+	if err := tni.RegisterHandler(t.handleTermination); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// Terminate sends the termination down the tree and terminates the root.
+// It can only be called by the root.
+func (t *Termination) Terminate() error {
+	if !t.tni.IsRoot() {
+		return errors.New("Only the root can terminate the protocol")
+	}
+	return t.terminate()
+}
+
+// Terminated returns a channel that is closed once this node terminated.
+func (t *Termination) Terminated() <-chan bool {
+	return t.terminated
+}
+
+// terminate informs the children and releases this node. Only the first
+// call has an effect.
+func (t *Termination) terminate() error {
+	var err error
+	t.once.Do(func() {
+		log.Lvl3(t.tni.Name(), "terminating protocol")
+		err = t.tni.SendToChildren(&TerminationMessage{})
+		if t.onTerminate != nil {
+			t.onTerminate()
+		}
+		close(t.terminated)
+		t.tni.Done()
+	})
+	return err
+}
+
+func (t *Termination) handleTermination(msg struct {
+	*TreeNode
+	TerminationMessage
+}) {
+	if err := t.terminate(); err != nil {
+		log.Error(t.tni.Name(), "couldn't forward termination:", err)
+	}
+}
@@ -0,0 +1,111 @@
+package sda
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/dedis/cothority/log"
+	"github.com/stretchr/testify/assert"
+)
+
+const terminationTestName = "TerminationTest"
+
+func init() {
+	GlobalProtocolRegister(terminationTestName, newTerminationProto)
+}
+
+func TestTermination(t *testing.T) {
+	local := NewLocalTest()
+	defer local.CloseAll()
+	nbrNodes := 7
+	_, _, tree := local.GenTree(nbrNodes, true)
+
+	terminationState.Lock()
+	terminationState.runs = make(map[int]bool)
+	terminationState.Unlock()
+	terminationDone = make(chan bool, nbrNodes)
+	_, err := local.StartProtocol(terminationTestName, tree)
+	log.ErrFatal(err)
+	for i := 0; i < nbrNodes; i++ {
+		select {
+		case <-terminationDone:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Not all nodes received the termination")
+		}
+	}
+
+	terminationState.Lock()
+	defer terminationState.Unlock()
+	assert.Equal(t, 0, len(terminationState.runs),
+		"some nodes didn't release their state")
+}
+
+// terminationState holds the per-run state of every node, which is
+// released by the onTerminate-hook.
+var terminationState struct {
+	runs map[int]bool
+	sync.Mutex
+}
+var terminationDone chan bool
+
+type terminationStart struct{}
+
+type terminationReady struct{}
+
+// terminationProto sends a message down the tree and back up. Once the
+// root has the answer of all children, it terminates the protocol.
+type terminationProto struct {
+	*TreeNodeInstance
+	termination *Termination
+	ready       int
+}
+
+func newTerminationProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	p := &terminationProto{TreeNodeInstance: n}
+	terminationState.Lock()
+	terminationState.runs[n.Index()] = true
+	terminationState.Unlock()
+	var err error
+	p.termination, err = NewTermination(n, func() {
+		terminationState.Lock()
+		delete(terminationState.runs, n.Index())
+		terminationState.Unlock()
+		terminationDone <- true
+	})
+	if err != nil {
+		return nil, err
+	}
+	return p, n.RegisterHandlers(p.handleStart, p.handleReady)
+}
+
+func (p *terminationProto) Start() error {
+	return p.handleStart(struct {
+		*TreeNode
+		terminationStart
+	}{})
+}
+
+func (p *terminationProto) handleStart(msg struct {
+	*TreeNode
+	terminationStart
+}) error {
+	if p.IsLeaf() {
+		return p.SendToParent(&terminationReady{})
+	}
+	return p.SendToChildren(&terminationStart{})
+}
+
+func (p *terminationProto) handleReady(msg struct {
+	*TreeNode
+	terminationReady
+}) error {
+	p.ready++
+	if p.ready < len(p.Children()) {
+		return nil
+	}
+	if p.IsRoot() {
+		return p.termination.Terminate()
+	}
+	return p.SendToParent(&terminationReady{})
+}
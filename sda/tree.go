@@ -4,7 +4,8 @@ import (
 	"errors"
 	"fmt"
 
-	"math/rand"
+	"sync"
+	"time"
 
 	"github.com/dedis/cothority/log"
 	"github.com/dedis/cothority/network"
@@ -221,6 +222,84 @@ func (t *Tree) Size() int {
 	return size
 }
 
+// Subtree returns a new tree containing only the given ServerIdentities,
+// so that a protocol can be run on a subset of the nodes. The structure of
+// the original tree is kept: every node is attached to its closest ancestor
+// that is part of the subset. If the root is not part of the subset, the
+// first node found in a depth-first search becomes the new root and the other
+// orphaned nodes are attached to it.
+// ServerIdentities not part of the tree are ignored. If none of them is part
+// of the tree, nil is returned.
+func (t *Tree) Subtree(sis []*network.ServerIdentity) *Tree {
+	want := make(map[network.ServerIdentityID]bool)
+	for _, si := range sis {
+		want[si.ID] = true
+	}
+	var list []*network.ServerIdentity
+	var root *TreeNode
+	var copyNode func(n, parent *TreeNode)
+	copyNode = func(n, parent *TreeNode) {
+		if want[n.ServerIdentity.ID] {
+			// Only take the first occurrence of every ServerIdentity
+			want[n.ServerIdentity.ID] = false
+			tn := NewTreeNode(len(list), n.ServerIdentity)
+			list = append(list, n.ServerIdentity)
+			switch {
+			case parent != nil:
+				parent.AddChild(tn)
+			case root == nil:
+				root = tn
+			default:
+				root.AddChild(tn)
+			}
+			parent = tn
+		}
+		for _, c := range n.Children {
+			copyNode(c, parent)
+		}
+	}
+	copyNode(t.Root, nil)
+	if root == nil {
+		return nil
+	}
+	return NewTree(NewRoster(list), root)
+}
+
+// CheckReachable pings all nodes of the tree in parallel using p and
+// returns the ServerIdentities that didn't answer within the timeout. It can
+// be used to verify a tree before starting a protocol on it.
+func (t *Tree) CheckReachable(p Pinger, timeout time.Duration) []*network.ServerIdentity {
+	var sis []*network.ServerIdentity
+	seen := make(map[network.ServerIdentityID]bool)
+	for _, tn := range t.List() {
+		if !seen[tn.ServerIdentity.ID] {
+			seen[tn.ServerIdentity.ID] = true
+			sis = append(sis, tn.ServerIdentity)
+		}
+	}
+	reachable := make([]bool, len(sis))
+	var wg sync.WaitGroup
+	for i, si := range sis {
+		wg.Add(1)
+		go func(i int, si *network.ServerIdentity) {
+			defer wg.Done()
+			if err := p.Ping(si, timeout); err != nil {
+				log.Lvl2("Node", si, "unreachable:", err)
+				return
+			}
+			reachable[i] = true
+		}(i, si)
+	}
+	wg.Wait()
+	var unreachable []*network.ServerIdentity
+	for i, si := range sis {
+		if !reachable[i] {
+			unreachable = append(unreachable, si)
+		}
+	}
+	return unreachable
+}
+
 // UsesList returns true if all ServerIdentities of the list are used at least once
 // in the tree
 func (t *Tree) UsesList() bool {
@@ -501,7 +580,7 @@ func (el *Roster) RandomServerIdentity() *network.ServerIdentity {
 	if el.List == nil || len(el.List) == 0 {
 		return nil
 	}
-	return el.List[rand.Int()%len(el.List)]
+	return el.List[randomInt()%len(el.List)]
 }
 
 // addNary is a recursive function to create the binary tree.
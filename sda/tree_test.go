@@ -2,7 +2,9 @@ package sda
 
 import (
 	"strconv"
+	"sync"
 	"testing"
+	"time"
 
 	"strings"
 
@@ -543,3 +545,104 @@ func genLocalTree(count, port int) (*Tree, *Roster) {
 	tree := peerList.GenerateBinaryTree()
 	return tree, peerList
 }
+
+func TestTreeCheckReachable(t *testing.T) {
+	local := NewLocalTest()
+	defer local.CloseAll()
+	conodes, roster, _ := local.GenTree(3, true)
+	_, down := NewPrivIdentity(2900)
+	list := append([]*network.ServerIdentity{}, roster.List...)
+	list = append(list, down)
+	tree := NewRoster(list).GenerateBinaryTree()
+
+	unreachable := tree.CheckReachable(conodes[0], time.Second)
+	assert.Equal(t, 1, len(unreachable))
+	assert.Equal(t, down.ID, unreachable[0].ID)
+
+	tree = roster.GenerateBinaryTree()
+	assert.Equal(t, 0, len(tree.CheckReachable(conodes[1], time.Second)))
+}
+
+func TestTreeSubtree(t *testing.T) {
+	local := NewLocalTest()
+	defer local.CloseAll()
+	_, roster, tree := local.GenTree(5, true)
+	l := roster.List
+
+	sub := tree.Subtree([]*network.ServerIdentity{l[0], l[2], l[4]})
+	assert.Equal(t, 3, sub.Size())
+	assert.Equal(t, 3, len(sub.Roster.List))
+	assert.True(t, sub.UsesList())
+	assert.Equal(t, l[0].ID, sub.Root.ServerIdentity.ID)
+
+	// Without the root, the first node found becomes the new root.
+	sub2 := tree.Subtree([]*network.ServerIdentity{l[3], l[4]})
+	assert.Equal(t, 2, sub2.Size())
+	assert.Equal(t, 1, len(sub2.Root.Children))
+	assert.Nil(t, tree.Subtree(nil))
+
+	subtreeParticipants.Lock()
+	subtreeParticipants.ids = make(map[network.ServerIdentityID]bool)
+	subtreeParticipants.Unlock()
+	subtreeDone = make(chan bool, 5)
+	_, err := local.StartProtocol(subtreeTestName, sub)
+	log.ErrFatal(err)
+	for i := 0; i < 3; i++ {
+		select {
+		case <-subtreeDone:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Not all nodes of the subtree participated")
+		}
+	}
+	time.Sleep(100 * time.Millisecond)
+	subtreeParticipants.Lock()
+	defer subtreeParticipants.Unlock()
+	assert.Equal(t, 3, len(subtreeParticipants.ids))
+	for _, si := range sub.Roster.List {
+		assert.True(t, subtreeParticipants.ids[si.ID])
+	}
+}
+
+const subtreeTestName = "SubtreeTest"
+
+func init() {
+	GlobalProtocolRegister(subtreeTestName, newSubtreeProto)
+}
+
+var subtreeParticipants struct {
+	ids map[network.ServerIdentityID]bool
+	sync.Mutex
+}
+var subtreeDone chan bool
+
+type subtreeMsg struct{}
+
+// subtreeProto records every node it runs on and passes the message down
+// the tree.
+type subtreeProto struct {
+	*TreeNodeInstance
+}
+
+func newSubtreeProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	p := &subtreeProto{n}
+	return p, n.RegisterHandler(p.handleMsg)
+}
+
+func (p *subtreeProto) Start() error {
+	return p.handleMsg(struct {
+		*TreeNode
+		subtreeMsg
+	}{})
+}
+
+func (p *subtreeProto) handleMsg(msg struct {
+	*TreeNode
+	subtreeMsg
+}) error {
+	subtreeParticipants.Lock()
+	subtreeParticipants.ids[p.ServerIdentity().ID] = true
+	subtreeParticipants.Unlock()
+	subtreeDone <- true
+	defer p.Done()
+	return p.SendToChildren(&subtreeMsg{})
+}
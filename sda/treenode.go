@@ -5,10 +5,12 @@ import (
 	"fmt"
 	"reflect"
 	"sync"
+	"time"
 
 	"strings"
 
 	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/monitor"
 	"github.com/dedis/cothority/network"
 	"github.com/dedis/crypto/abstract"
 )
@@ -46,6 +48,23 @@ type TreeNodeInstance struct {
 	msgDispatchQueueWait chan bool
 	// whether this node is closing
 	closing bool
+	// config is sent along with all messages, so that the other nodes
+	// get it when their TreeNodeInstance is created
+	config *GenericConfig
+
+	// statistics of this node that are sent to the monitor
+	stats    treeNodeStats
+	statsMut sync.Mutex
+}
+
+// treeNodeStats holds the standard measures of a TreeNodeInstance.
+type treeNodeStats struct {
+	start    time.Time
+	msgTx    int
+	msgRx    int
+	bytesTx  int
+	bytesRx  int
+	recorded bool
 }
 
 // aggregateMessages (if set) tells to aggregate messages from all children
@@ -69,6 +88,7 @@ func newTreeNodeInstance(o *Overlay, tok *Token, tn *TreeNode) *TreeNodeInstance
 		treeNode:             tn,
 		msgDispatchQueue:     make([]*ProtocolMsg, 0, 1),
 		msgDispatchQueueWait: make(chan bool, 1),
+		stats:                treeNodeStats{start: time.Now()},
 	}
 	go n.dispatchMsgReader()
 	return n
@@ -112,10 +132,70 @@ func (n *TreeNodeInstance) IsLeaf() bool {
 
 // SendTo sends to a given node
 func (n *TreeNodeInstance) SendTo(to *TreeNode, msg interface{}) error {
+	return n.sendTo(to, msg, false)
+}
+
+// SendToBatched is like SendTo, but the message is only buffered in the
+// connection to the conode of to, until Flush is called or another message
+// is sent to that conode with SendTo. A protocol knowing that it sends many
+// messages in a row can send them all at once like this. With local
+// connections, the message is sent right away.
+func (n *TreeNodeInstance) SendToBatched(to *TreeNode, msg interface{}) error {
+	return n.sendTo(to, msg, true)
+}
+
+// Flush sends all messages buffered by SendToBatched to the conode of to.
+func (n *TreeNodeInstance) Flush(to *TreeNode) error {
+	if to == nil {
+		return errors.New("Flushing a nil TreeNode")
+	}
+	return n.overlay.conode.Flush(to.ServerIdentity)
+}
+
+// sendTo does the work of SendTo and SendToBatched.
+func (n *TreeNodeInstance) sendTo(to *TreeNode, msg interface{}, batched bool) error {
 	if to == nil {
 		return errors.New("Sent to a nil TreeNode")
 	}
-	return n.overlay.SendToTreeNode(n.token, to, msg)
+	size, err := n.overlay.sendToTreeNode(n.token, to, msg, n.config, batched)
+	if err == nil {
+		n.statsMut.Lock()
+		n.stats.msgTx++
+		n.stats.bytesTx += size
+		n.statsMut.Unlock()
+	}
+	return err
+}
+
+// SendToWithRetry works like SendTo, but tries again up to 'retries' times
+// if sending fails, e.g. because the remote conode is restarting. It waits
+// 'backoff' before the first retry and doubles the waiting time for every
+// further retry. It returns the error of the last try. If the message can
+// never be sent or this node is closing, it returns right away.
+func (n *TreeNodeInstance) SendToWithRetry(to *TreeNode, msg interface{},
+	retries int, backoff time.Duration) error {
+	if to == nil {
+		return errors.New("Sent to a nil TreeNode")
+	}
+	if network.TypeFromData(msg) == network.ErrorType {
+		return fmt.Errorf("Message-type %s not registered",
+			reflect.TypeOf(msg))
+	}
+	err := n.SendTo(to, msg)
+	for i := 0; err != nil && i < retries; i++ {
+		n.msgDispatchQueueMutex.Lock()
+		closing := n.closing
+		n.msgDispatchQueueMutex.Unlock()
+		if closing {
+			return fmt.Errorf("Node closed while retrying: %s", err)
+		}
+		log.Lvl2(n.Name(), "couldn't send to", to.Name(), "- retrying in",
+			backoff, ":", err)
+		time.Sleep(backoff)
+		backoff *= 2
+		err = n.SendTo(to, msg)
+	}
+	return err
 }
 
 // Tree returns the tree of that node
@@ -266,9 +346,31 @@ func (n *TreeNodeInstance) Close() error {
 		n.msgDispatchQueueWait <- true
 	}
 	n.msgDispatchQueueMutex.Unlock()
+	n.recordStats()
 	return n.ProtocolInstance().Shutdown()
 }
 
+// recordStats sends the number of messages and bytes sent and received,
+// as well as the duration of this node to the monitor. This is only done
+// if a monitor-sink is connected, which is the case in simulations.
+// The measures are called "protocolname_msg_tx", "protocolname_bytes_rx",
+// "protocolname_duration" and so on.
+func (n *TreeNodeInstance) recordStats() {
+	n.statsMut.Lock()
+	defer n.statsMut.Unlock()
+	if n.stats.recorded || !monitor.Connected() {
+		return
+	}
+	n.stats.recorded = true
+	name := n.ProtocolName()
+	monitor.NewSingleMeasure(name+"_msg_tx", float64(n.stats.msgTx)).Record()
+	monitor.NewSingleMeasure(name+"_msg_rx", float64(n.stats.msgRx)).Record()
+	monitor.NewSingleMeasure(name+"_bytes_tx", float64(n.stats.bytesTx)).Record()
+	monitor.NewSingleMeasure(name+"_bytes_rx", float64(n.stats.bytesRx)).Record()
+	monitor.NewSingleMeasure(name+"_duration",
+		time.Since(n.stats.start).Seconds()).Record()
+}
+
 // ProtocolName will return the string representing that protocol
 func (n *TreeNodeInstance) ProtocolName() string {
 	return n.overlay.conode.protocols.ProtocolIDToName(n.token.ProtoID)
@@ -385,6 +487,10 @@ func (n *TreeNodeInstance) dispatchMsgToProtocol(sdaMsg *ProtocolMsg) error {
 	// Put the msg into SDAData
 	sdaMsg.MsgType = t
 	sdaMsg.Msg = msg
+	n.statsMut.Lock()
+	n.stats.msgRx++
+	n.stats.bytesRx += len(sdaMsg.MsgSlice)
+	n.statsMut.Unlock()
 
 	// if message comes from parent, dispatch directly
 	// if messages come from children we must aggregate them
@@ -521,6 +627,12 @@ func (n *TreeNodeInstance) Token() *Token {
 	return n.token.Clone()
 }
 
+// Config returns the GenericConfig given to CreateProtocolWithConfig on the
+// root, or nil if there is none.
+func (n *TreeNodeInstance) Config() *GenericConfig {
+	return n.config
+}
+
 // List returns the list of TreeNodes cached in the node (creating it if necessary)
 func (n *TreeNodeInstance) List() []*TreeNode {
 	n.mtx.Lock()
@@ -536,16 +648,21 @@ func (n *TreeNodeInstance) Index() int {
 	return n.TreeNode().RosterIndex
 }
 
-// Broadcast sends a given message from the calling node directly to all other TreeNodes
-func (n *TreeNodeInstance) Broadcast(msg interface{}) error {
+// Broadcast sends a given message from the calling node directly to all other
+// TreeNodes. It doesn't stop if sending to one of the nodes fails, but
+// returns one error for every node that couldn't be reached, or nil if all
+// nodes got the message.
+func (n *TreeNodeInstance) Broadcast(msg interface{}) []error {
+	var errs []error
 	for _, node := range n.List() {
 		if node != n.TreeNode() {
 			if err := n.SendTo(node, msg); err != nil {
-				return err
+				errs = append(errs, fmt.Errorf("Error while sending to %s: %s",
+					node.Name(), err))
 			}
 		}
 	}
-	return nil
+	return errs
 }
 
 // Multicast ... XXX: should probably have a parallel more robust version like "SendToChildrenInParallel"
@@ -1,9 +1,14 @@
 package sda
 
 import (
+	"fmt"
+	"strconv"
 	"testing"
+	"time"
 
 	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/monitor"
+	"github.com/stretchr/testify/require"
 )
 
 func TestTreeNodeCreateProtocol(t *testing.T) {
@@ -54,3 +59,277 @@ func (s *spawnProto) Start() error {
 	go proto.Start()
 	return nil
 }
+
+func TestTreeNodeMeasures(t *testing.T) {
+	stats := monitor.NewStats(map[string]string{"servers": "1"})
+	mon := monitor.NewMonitor(stats)
+	mon.SinkPort = 10010
+	go mon.Listen()
+	time.Sleep(100 * time.Millisecond)
+	log.ErrFatal(monitor.ConnectSink("localhost:" + strconv.Itoa(mon.SinkPort)))
+	defer mon.Stop()
+
+	GlobalProtocolRegister(measureName, newMeasureProto)
+	local := NewLocalTest()
+	_, _, tree := local.GenTree(3, true)
+	_, err := local.StartProtocol(measureName, tree)
+	log.ErrFatal(err)
+	select {
+	case <-measureDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Protocol didn't finish")
+	}
+	local.CloseAll()
+	monitor.EndAndCleanup()
+	time.Sleep(100 * time.Millisecond)
+
+	st := mon.Stats()
+	st.Collect()
+	for _, m := range []string{"_msg_tx", "_msg_rx", "_bytes_tx",
+		"_bytes_rx", "_duration"} {
+		require.NotNil(t, st.Value(measureName+m), "missing measure "+m)
+	}
+	// the root sends to 2 children and every child sends back once
+	require.Equal(t, 2.0, st.Value(measureName+"_msg_tx").Max())
+	require.Equal(t, 1.0, st.Value(measureName+"_msg_tx").Min())
+	require.True(t, st.Value(measureName+"_bytes_rx").Min() > 0)
+}
+
+const measureName = "Measure"
+
+var measureDone = make(chan bool, 1)
+
+type measureMsg struct {
+	I int
+}
+
+// measureProto sends a message from the root to all children who send it
+// back.
+type measureProto struct {
+	*TreeNodeInstance
+	replies int
+}
+
+func newMeasureProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	p := &measureProto{TreeNodeInstance: n}
+	return p, p.RegisterHandler(p.handleMsg)
+}
+
+func (p *measureProto) Start() error {
+	return p.SendToChildren(&measureMsg{1})
+}
+
+func (p *measureProto) handleMsg(msg struct {
+	*TreeNode
+	measureMsg
+}) {
+	if !p.IsRoot() {
+		log.ErrFatal(p.SendToParent(&msg.measureMsg))
+		p.Done()
+		return
+	}
+	p.replies++
+	if p.replies == len(p.Children()) {
+		p.Done()
+		measureDone <- true
+	}
+}
+
+func TestTreeNodeSendToWithRetry(t *testing.T) {
+	GlobalProtocolRegister(retryName, newRetryProto)
+	local := NewLocalTest()
+	defer local.CloseAll()
+	conodes, _, tree := local.GenTree(2, true)
+	pi, err := local.CreateProtocol(retryName, tree)
+	log.ErrFatal(err)
+	p := pi.(*retryProto)
+	child := tree.Root.Children[0]
+
+	log.ErrFatal(p.SendToWithRetry(child, &retryMsg{1}, 2, time.Millisecond))
+	select {
+	case i := <-retryReceived:
+		require.Equal(t, 1, i)
+	case <-time.After(time.Second):
+		t.Fatal("Message didn't arrive")
+	}
+
+	// errors that can't be fixed by retrying are returned right away
+	start := time.Now()
+	require.NotNil(t, p.SendToWithRetry(nil, &retryMsg{2}, 2, time.Second))
+	require.NotNil(t, p.SendToWithRetry(child, &retryUnregistered{}, 2,
+		time.Second))
+	require.True(t, time.Since(start) < time.Second)
+
+	log.ErrFatal(conodes[1].Close())
+	delete(local.Conodes, conodes[1].ServerIdentity.ID)
+	start = time.Now()
+	err = p.SendToWithRetry(child, &retryMsg{3}, 2, 20*time.Millisecond)
+	require.NotNil(t, err)
+	require.True(t, time.Since(start) >= 60*time.Millisecond,
+		"should wait 20ms and 40ms before retrying")
+	p.Done()
+}
+
+const retryName = "Retry"
+
+var retryReceived = make(chan int, 1)
+
+type retryMsg struct {
+	I int
+}
+
+type retryUnregistered struct{}
+
+type retryProto struct {
+	*TreeNodeInstance
+}
+
+func newRetryProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	p := &retryProto{n}
+	return p, p.RegisterHandler(p.handleMsg)
+}
+
+func (p *retryProto) Start() error {
+	return nil
+}
+
+func (p *retryProto) handleMsg(msg struct {
+	*TreeNode
+	retryMsg
+}) {
+	retryReceived <- msg.I
+	p.Done()
+}
+
+func TestTreeNodeBroadcast(t *testing.T) {
+	GlobalProtocolRegister(broadcastName, newBroadcastProto)
+	local := NewLocalTest()
+	defer local.CloseAll()
+	nbrNodes := 5
+	_, _, tree := local.GenTree(nbrNodes, true)
+	_, err := local.StartProtocol(broadcastName, tree)
+	log.ErrFatal(err)
+
+	received := make(map[int]int)
+	for i := 0; i < nbrNodes-1; i++ {
+		select {
+		case idx := <-broadcastReceived:
+			received[idx]++
+		case <-time.After(time.Second):
+			t.Fatal("Not all nodes received the broadcast")
+		}
+	}
+	select {
+	case idx := <-broadcastReceived:
+		t.Fatal("Node", idx, "received more than one copy")
+	case <-time.After(100 * time.Millisecond):
+	}
+	require.Equal(t, nbrNodes-1, len(received))
+	for idx, nbr := range received {
+		require.NotEqual(t, 0, idx, "root shouldn't receive its broadcast")
+		require.Equal(t, 1, nbr)
+	}
+}
+
+const broadcastName = "Broadcast"
+
+var broadcastReceived = make(chan int, 10)
+
+type broadcastMsg struct{}
+
+type broadcastProto struct {
+	*TreeNodeInstance
+}
+
+func newBroadcastProto(n *TreeNodeInstance) (ProtocolInstance, error) {
+	p := &broadcastProto{n}
+	return p, p.RegisterHandler(p.handleMsg)
+}
+
+func (p *broadcastProto) Start() error {
+	if errs := p.Broadcast(&broadcastMsg{}); errs != nil {
+		return fmt.Errorf("%v", errs)
+	}
+	p.Done()
+	return nil
+}
+
+func (p *broadcastProto) handleMsg(msg struct {
+	*TreeNode
+	broadcastMsg
+}) {
+	broadcastReceived <- p.Index()
+	p.Done()
+}
+
+func TestTreeNodeSendToBatched(t *testing.T) {
+	GlobalProtocolRegister(batchName, newBatchProto)
+	local := NewTCPTest()
+	defer local.CloseAll()
+
+	_, _, tree := local.GenTree(2, true)
+	pi, err := local.CreateProtocol(batchName, tree)
+	log.ErrFatal(err)
+	root := pi.(*batchProto)
+	child := root.Children()[0]
+	for i := 0; i < 3; i++ {
+		log.ErrFatal(root.SendToBatched(child, &batchMsg{i}))
+	}
+	select {
+	case <-batchReceived:
+		t.Fatal("Message delivered before Flush")
+	case <-time.After(100 * time.Millisecond):
+	}
+	log.ErrFatal(root.Flush(child))
+	for i := 0; i < 3; i++ {
+		select {
+		case v := <-batchReceived:
+			require.Equal(t, i, v)
+		case <-time.After(time.Second):
+			t.Fatal("Message not delivered after Flush")
+		}
+	}
+
+	// SendTo sends the buffered messages first
+	log.ErrFatal(root.SendToBatched(child, &batchMsg{3}))
+	log.ErrFatal(root.SendTo(child, &batchMsg{4}))
+	for i := 3; i < 5; i++ {
+		select {
+		case v := <-batchReceived:
+			require.Equal(t, i, v)
+		case <-time.After(time.Second):
+			t.Fatal("Message not delivered")
+		}
+	}
+}
+
+const batchName = "Batch"
+
+// batchReceived gets the messages received by the batchProto
+var batchReceived = make(chan int, 10)
+
+type batchMsg struct {
+	I int
+}
+
+// batchProto passes all batchMsgs it receives to batchReceived.
+type batchProto struct {
+	*TreeNodeInstance
+}
+
+func newBatchProto(tn *TreeNodeInstance) (ProtocolInstance, error) {
+	b := &batchProto{TreeNodeInstance: tn}
+	return b, tn.RegisterHandler(b.handleMsg)
+}
+
+func (b *batchProto) Start() error {
+	return nil
+}
+
+func (b *batchProto) handleMsg(msg struct {
+	*TreeNode
+	batchMsg
+}) error {
+	batchReceived <- msg.I
+	return nil
+}
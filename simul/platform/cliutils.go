@@ -5,8 +5,10 @@ package platform
 import (
 	"bufio"
 	"bytes"
+	"fmt"
 	"os"
 	"os/exec"
+	"sort"
 
 	"github.com/dedis/cothority/log"
 )
@@ -66,11 +68,53 @@ func SSHRunStdout(username, host, command string) error {
 	return cmd.Run()
 }
 
+// supportedTargets lists the GOARCHs go can build for every GOOS.
+var supportedTargets = map[string][]string{
+	"android":   {"arm"},
+	"darwin":    {"386", "amd64", "arm", "arm64"},
+	"dragonfly": {"amd64"},
+	"freebsd":   {"386", "amd64", "arm"},
+	"linux": {"386", "amd64", "arm", "arm64", "mips64", "mips64le",
+		"ppc64", "ppc64le", "s390x"},
+	"nacl":    {"386", "amd64p32", "arm"},
+	"netbsd":  {"386", "amd64", "arm"},
+	"openbsd": {"386", "amd64", "arm"},
+	"plan9":   {"386", "amd64", "arm"},
+	"solaris": {"amd64"},
+	"windows": {"386", "amd64"},
+}
+
+// CheckTarget returns an error if go can't build binaries for the given
+// GOARCH and GOOS.
+func CheckTarget(goarch, goos string) error {
+	archs, ok := supportedTargets[goos]
+	if !ok {
+		var systems []string
+		for s := range supportedTargets {
+			systems = append(systems, s)
+		}
+		sort.Strings(systems)
+		return fmt.Errorf("Can't build for GOARCH=%s GOOS=%s: unknown GOOS, "+
+			"supported are %v", goarch, goos, systems)
+	}
+	for _, a := range archs {
+		if a == goarch {
+			return nil
+		}
+	}
+	return fmt.Errorf("Can't build for GOARCH=%s GOOS=%s: supported GOARCHs "+
+		"for %s are %v", goarch, goos, goos, archs)
+}
+
 // Build builds the the golang packages in `path` and stores the result in `out`. Besides specifying the environment
 // variables GOOS and GOARCH you can pass any additional argument using the buildArgs
 // argument. The command which will be executed is of the following form:
 // $ go build -v buildArgs... -o out path
+// An error is returned if goarch and goos are not a supported combination.
 func Build(path, out, goarch, goos string, buildArgs ...string) (string, error) {
+	if err := CheckTarget(goarch, goos); err != nil {
+		return "", err
+	}
 	var cmd *exec.Cmd
 	var b bytes.Buffer
 	buildBuffer := bufio.NewWriter(&b)
@@ -85,7 +129,9 @@ func Build(path, out, goarch, goos string, buildArgs ...string) (string, error)
 	log.Lvl4("Building", cmd.Args, "in", path)
 	cmd.Stdout = buildBuffer
 	cmd.Stderr = buildBuffer
-	cmd.Env = append([]string{"GOOS=" + goos, "GOARCH=" + goarch}, os.Environ()...)
+	// the last definition of a variable wins, so GOOS and GOARCH from the
+	// environment are overwritten
+	cmd.Env = append(os.Environ(), "GOOS="+goos, "GOARCH="+goarch)
 	wd, err := os.Getwd()
 	log.Lvl4(wd)
 	log.Lvl4("Command:", cmd.Args)
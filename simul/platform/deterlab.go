@@ -113,7 +113,7 @@ func (d *Deterlab) Configure(pc *Config) {
 // the ones indicated. Either "simul" or "users"
 func (d *Deterlab) Build(build string, arg ...string) error {
 	log.Lvl1("Building for", d.Login, d.Host, d.Project, build, "cothorityDir=", d.cothorityDir)
-	start := time.Now()
+	defer log.Timer("Build").Done()
 
 	var wg sync.WaitGroup
 
@@ -176,7 +176,6 @@ func (d *Deterlab) Build(build string, arg ...string) error {
 	}
 	// wait for the build to finish
 	wg.Wait()
-	log.Lvl1("Build is finished after", time.Since(start))
 	return nil
 }
 
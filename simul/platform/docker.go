@@ -0,0 +1,260 @@
+package platform
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/sda"
+)
+
+// Docker is the platform for running the simulation in docker-containers on
+// the local machine. Every server runs in its own container, the containers
+// are connected by a user-defined bridge network, so the messages go
+// through a real network-stack. If Delay is given, all containers add this
+// latency to their outgoing packets using 'tc'.
+//
+// The following fields can be set in the run-config:
+//
+//	Image = "cothority-simul" // name of the image that is built
+//	Network = "cothority" // name of the docker-network
+//	Subnet = "172.30.0" // the first three bytes of the network's subnet
+//	Delay = 50 // latency in ms
+//	Arch = "arm64" // GOARCH of the docker-host, defaults to the local one
+//	CloseWait = 600 // seconds to wait for the simulation to finish
+type Docker struct {
+	// The simulation to run
+	Simulation string
+	// Image is the name of the docker-image to build
+	Image string
+	// Network is the name of the docker-network connecting the containers
+	Network string
+	// Subnet holds the first three bytes of the /24 subnet of the network
+	Subnet string
+	// Delay is the latency in milliseconds added to all outgoing packets
+	Delay int
+	// Arch is the GOARCH of the machine running the containers
+	Arch string
+	// CloseWait is the number of seconds to wait for the simulation to
+	// finish
+	CloseWait int
+
+	// Where to build the image and where to put the config-files
+	runDir string
+	// Debug level 1 - 5
+	debug int
+	// The number of servers
+	servers int
+	// Addresses of all containers
+	addresses []string
+	// Listening monitor port
+	monitorPort int
+	// WaitGroup for running containers
+	wgRun sync.WaitGroup
+	// errors go here, it is created by Start:
+	errChan chan error
+	// SimulationConfig holds all things necessary for the run
+	sc *sda.SimulationConfig
+}
+
+// dockerContainerPrefix is used for the names of all containers, so that
+// Cleanup finds them.
+const dockerContainerPrefix = "cothority-simul-"
+
+// dockerfile is used to build the image with the simulation-binary.
+const dockerfile = `FROM debian:jessie
+RUN apt-get update && apt-get install -y iproute2 && rm -rf /var/lib/apt/lists/*
+COPY %s /usr/local/bin/%s
+WORKDIR /cothority
+`
+
+// Configure sets the directories and the default values.
+func (d *Docker) Configure(pc *Config) {
+	pwd, _ := os.Getwd()
+	d.runDir = pwd + "/platform/docker"
+	d.debug = pc.Debug
+	d.monitorPort = pc.MonitorPort
+	if d.Simulation == "" {
+		log.Fatal("No simulation defined in simulation")
+	}
+	if d.Image == "" {
+		d.Image = "cothority-simul"
+	}
+	if d.Network == "" {
+		d.Network = "cothority"
+	}
+	if d.Subnet == "" {
+		d.Subnet = "172.30.0"
+	}
+	if d.Arch == "" {
+		d.Arch = runtime.GOARCH
+	}
+	if d.CloseWait == 0 {
+		d.CloseWait = 600
+	}
+	log.Lvl3("Docker dirs: RunDir", d.runDir)
+	log.Lvl3("Docker configured ...")
+}
+
+// Build compiles the simulation for linux and builds the docker-image with
+// it.
+func (d *Docker) Build(build string, arg ...string) error {
+	if err := CheckTarget(d.Arch, "linux"); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.runDir, 0770); err != nil {
+		return err
+	}
+	src := "./cothority"
+	dst := d.runDir + "/" + d.Simulation
+	start := time.Now()
+	res, err := Build(src, dst, d.Arch, "linux", arg...)
+	if err != nil {
+		return fmt.Errorf("Error while building for docker (src %s, dst %s): %s\n%s",
+			src, dst, err, res)
+	}
+	log.Lvl4("Docker: Results of build:", res)
+	df := fmt.Sprintf(dockerfile, d.Simulation, d.Simulation)
+	if err := ioutil.WriteFile(path.Join(d.runDir, "Dockerfile"), []byte(df), 0660); err != nil {
+		return err
+	}
+	out, err := exec.Command("docker", "build", "-t", d.Image, d.runDir).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("Couldn't build docker-image: %s\n%s", err, out)
+	}
+	log.Lvl2("Docker: build finished in", time.Since(start))
+	return nil
+}
+
+// Cleanup removes all containers of former runs and the network.
+func (d *Docker) Cleanup() error {
+	log.Lvl3("Cleaning up")
+	out, err := exec.Command("docker", "ps", "-aq", "--filter",
+		"name="+dockerContainerPrefix).Output()
+	if err != nil {
+		log.Lvl3("Error listing containers", err)
+		return nil
+	}
+	ids := strings.Fields(string(out))
+	if len(ids) > 0 {
+		if err := exec.Command("docker", append([]string{"rm", "-f"}, ids...)...).Run(); err != nil {
+			log.Lvl3("Error removing containers", err)
+		}
+	}
+	if err := exec.Command("docker", "network", "rm", d.Network).Run(); err != nil {
+		log.Lvl3("Error removing network", err)
+	}
+	return nil
+}
+
+// Deploy creates the network and writes the config-files to the
+// run-directory, which is mounted in all containers.
+func (d *Docker) Deploy(rc RunConfig) error {
+	d.servers, _ = strconv.Atoi(rc.Get("servers"))
+	if d.servers > 250 {
+		return fmt.Errorf("Docker: can't run more than 250 servers in subnet %s", d.Subnet)
+	}
+	log.Lvl2("Docker: Deploying and writing config-files for", d.servers, "servers")
+	out, err := exec.Command("docker", "network", "create", "--driver", "bridge",
+		"--subnet", d.Subnet+".0/24", d.Network).CombinedOutput()
+	if err != nil && !strings.Contains(string(out), "already exists") {
+		return fmt.Errorf("Couldn't create network %s: %s\n%s", d.Network, err, out)
+	}
+	sim, err := sda.NewSimulation(d.Simulation, string(rc.Toml()))
+	if err != nil {
+		return err
+	}
+	// .1 is the gateway, which is the host running the monitor
+	d.addresses = make([]string, d.servers)
+	for i := range d.addresses {
+		d.addresses[i] = d.Subnet + "." + strconv.Itoa(i+2)
+	}
+	d.sc, err = sim.Setup(d.runDir, d.addresses)
+	if err != nil {
+		return err
+	}
+	d.sc.Config = string(rc.Toml())
+	if err := d.sc.Save(d.runDir); err != nil {
+		return err
+	}
+	log.Lvl2("Docker: Done deploying")
+	return nil
+}
+
+// Start runs one container for every server. The output of the containers
+// is shown on stdout and stderr, and the measurements are sent to the
+// monitor on the host through the gateway of the network.
+func (d *Docker) Start(args ...string) error {
+	monitor := d.Subnet + ".1:" + strconv.Itoa(d.monitorPort)
+	// buffered so that containers failing after a timeout in Wait don't
+	// block
+	d.errChan = make(chan error, len(d.addresses)+1)
+	log.Lvl1("Starting", d.servers, "containers of", d.Image)
+	for index, address := range d.addresses {
+		d.wgRun.Add(1)
+		cmdArgs := append(args, "-address", address, "-monitor", monitor,
+			"-simul", d.Simulation,
+			"-debug", strconv.Itoa(log.DebugVisible()))
+		run := d.Simulation + " " + strings.Join(cmdArgs, " ")
+		if d.Delay > 0 {
+			run = fmt.Sprintf("tc qdisc add dev eth0 root netem delay %dms && %s",
+				d.Delay, run)
+		}
+		cmd := exec.Command("docker", "run", "--rm",
+			"--name", dockerContainerPrefix+strconv.Itoa(index),
+			"--net", d.Network, "--ip", address,
+			"--cap-add", "NET_ADMIN",
+			"-v", d.runDir+":/cothority",
+			d.Image, "sh", "-c", run)
+		log.Lvl3("Docker: running", cmd.Args)
+		cmd.Stdout = os.Stdout
+		cmd.Stderr = os.Stderr
+		go func(i int, h string) {
+			log.Lvl3("Docker: will start host", h)
+			err := cmd.Run()
+			if err != nil {
+				log.Error("Error running docker", h, ":", err)
+				d.errChan <- err
+			}
+			d.wgRun.Done()
+			log.Lvl3("host (index", i, ")", h, "done")
+		}(index, address)
+	}
+	return nil
+}
+
+// Wait for all containers to finish, at most CloseWait seconds.
+func (d *Docker) Wait() error {
+	log.Lvl3("Waiting for containers to finish")
+
+	var err error
+	go func() {
+		d.wgRun.Wait()
+		log.Lvl3("WaitGroup is 0")
+		d.errChan <- nil
+	}()
+
+	// if one of the containers fails, stop waiting and return the error:
+	select {
+	case e := <-d.errChan:
+		err = e
+	case <-time.After(time.Duration(d.CloseWait) * time.Second):
+		err = fmt.Errorf("Containers didn't finish after %d seconds", d.CloseWait)
+	}
+	if err != nil {
+		if err := d.Cleanup(); err != nil {
+			log.Error("Couldn't cleanup running containers", err)
+		}
+	}
+
+	log.Lvl2("Containers finished")
+	return err
+}
@@ -84,7 +84,7 @@ func (d *Localhost) Configure(pc *Config) {
 func (d *Localhost) Build(build string, arg ...string) error {
 	src := "./cothority"
 	dst := d.runDir + "/" + d.Simulation
-	start := time.Now()
+	defer log.Timer("Localhost: build").Lvl(2).Done()
 	// build for the local machine
 	res, err := Build(src, dst,
 		runtime.GOARCH, runtime.GOOS,
@@ -94,7 +94,6 @@ func (d *Localhost) Build(build string, arg ...string) error {
 	}
 	log.Lvl3("Localhost: Build src", src, ", dst", dst)
 	log.Lvl4("Localhost: Results of localhost build:", res)
-	log.Lvl2("Localhost: build finished in", time.Since(start))
 	return err
 }
 
@@ -1,6 +1,7 @@
 // Package platform contains interface and implementation to run SDA code
 // amongst multiple platforms. Such implementations include Localhost (run your
-// test locally) and Deterlab (similar to emulab).
+// test locally), Docker (run your test locally in containers), SSHCluster (run
+// your test on machines reachable by SSH) and Deterlab (similar to emulab).
 package platform
 
 import (
@@ -44,9 +45,11 @@ type Config struct {
 
 var deterlab = "deterlab"
 var localhost = "localhost"
+var docker = "docker"
+var ssh = "ssh"
 
 // NewPlatform returns the appropriate platform
-// [deterlab,localhost]
+// [deterlab,localhost,docker,ssh]
 func NewPlatform(t string) Platform {
 	var p Platform
 	switch t {
@@ -54,6 +57,10 @@ func NewPlatform(t string) Platform {
 		p = &Deterlab{}
 	case localhost:
 		p = &Localhost{}
+	case docker:
+		p = &Docker{}
+	case ssh:
+		p = &SSHCluster{}
 	}
 	return p
 }
@@ -40,6 +40,21 @@ func TestReadRunfile(t *testing.T) {
 	}
 }
 
+func TestCheckTarget(t *testing.T) {
+	if err := platform.CheckTarget("amd64", "linux"); err != nil {
+		t.Fatal("linux/amd64 should be supported:", err)
+	}
+	if err := platform.CheckTarget("arm64", "linux"); err != nil {
+		t.Fatal("linux/arm64 should be supported:", err)
+	}
+	if err := platform.CheckTarget("arm64", "windows"); err == nil {
+		t.Fatal("windows/arm64 should not be supported")
+	}
+	if err := platform.CheckTarget("amd64", "beos"); err == nil {
+		t.Fatal("beos should not be supported")
+	}
+}
+
 type TPlat struct {
 	App      string
 	Machines int
@@ -0,0 +1,252 @@
+package platform
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/dedis/cothority/log"
+	"github.com/dedis/cothority/sda"
+)
+
+// SSHCluster is the platform for running the simulation on a list of
+// machines reachable by SSH, without any framework installed on them. The
+// simulation-binary and the config-files are copied to every machine and
+// the binary is started directly. The machines need to reach the monitor
+// on this machine.
+//
+// The following fields can be set in the run-config:
+//
+//	Nodes = ["10.0.0.1", "10.0.0.2"] // machines to use, also the addresses of the conodes
+//	Login = "user" // login on all machines, defaults to the local user
+//	MonitorAddress = "10.0.0.100" // address of this machine as seen by the machines
+//	RemoteDir = "cothority" // directory on the machines, relative to the home
+//	Arch = "amd64" // GOARCH of the machines
+//	CloseWait = 600 // seconds to wait for the simulation to finish
+type SSHCluster struct {
+	// The simulation to run
+	Simulation string
+	// Nodes are the hostnames or IPs of the machines
+	Nodes []string
+	// Login used on all machines
+	Login string
+	// MonitorAddress is the address of this machine as seen by the nodes
+	MonitorAddress string
+	// RemoteDir is the directory on the nodes where everything is copied to
+	RemoteDir string
+	// Arch is the GOARCH of the nodes
+	Arch string
+	// CloseWait is the number of seconds to wait for the simulation to
+	// finish
+	CloseWait int
+
+	// Where to build the binary
+	buildDir string
+	// Directory copied to all nodes
+	deployDir string
+	// Debug level 1 - 5
+	debug int
+	// The nodes used by the current run
+	servers []string
+	// Listening monitor port
+	monitorPort int
+	// WaitGroup for the running nodes
+	wgRun sync.WaitGroup
+	// errors go here, it is created by Start:
+	errChan chan error
+}
+
+// sshBinary is the name of the simulation-binary on the nodes.
+const sshBinary = "cothority"
+
+// Configure sets the directories and the default values.
+func (d *SSHCluster) Configure(pc *Config) {
+	pwd, _ := os.Getwd()
+	sshDir := pwd + "/platform/ssh"
+	d.buildDir = sshDir + "/build"
+	d.deployDir = sshDir + "/remote"
+	d.debug = pc.Debug
+	d.monitorPort = pc.MonitorPort
+	if d.Simulation == "" {
+		log.Fatal("No simulation defined in simulation")
+	}
+	if len(d.Nodes) == 0 {
+		log.Fatal("No Nodes defined in simulation")
+	}
+	if d.MonitorAddress == "" {
+		log.Fatal("No MonitorAddress defined in simulation")
+	}
+	if d.RemoteDir == "" {
+		d.RemoteDir = "cothority"
+	}
+	if d.Arch == "" {
+		d.Arch = "amd64"
+	}
+	if d.CloseWait == 0 {
+		d.CloseWait = 600
+	}
+	log.Lvl3("SSH dirs: BuildDir", d.buildDir, "DeployDir", d.deployDir)
+	log.Lvl3("SSH configured for", d.Nodes)
+}
+
+// Build compiles the simulation for the nodes.
+func (d *SSHCluster) Build(build string, arg ...string) error {
+	if err := CheckTarget(d.Arch, "linux"); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.buildDir, 0770); err != nil {
+		return err
+	}
+	src := "./cothority"
+	dst := d.buildDir + "/" + sshBinary
+	defer log.Timer("SSH: build").Lvl(2).Done()
+	res, err := Build(src, dst, d.Arch, "linux", arg...)
+	if err != nil {
+		return fmt.Errorf("Error while building for ssh (src %s, dst %s): %s\n%s",
+			src, dst, err, res)
+	}
+	log.Lvl4("SSH: Results of build:", res)
+	return nil
+}
+
+// Cleanup kills the simulation on all nodes.
+func (d *SSHCluster) Cleanup() error {
+	log.Lvl3("Cleaning up")
+	var wg sync.WaitGroup
+	for _, node := range d.Nodes {
+		wg.Add(1)
+		go func(node string) {
+			defer wg.Done()
+			_, err := SSHRun(d.Login, node, "pkill -9 -f \"[.]/"+sshBinary+" .*-simul\"")
+			if err != nil {
+				log.Lvl3("Error stopping", node, err)
+			}
+		}(node)
+	}
+	wg.Wait()
+	return nil
+}
+
+// Deploy writes the config-files and copies them together with the binary
+// to all nodes.
+func (d *SSHCluster) Deploy(rc RunConfig) error {
+	servers, _ := strconv.Atoi(rc.Get("servers"))
+	if servers > len(d.Nodes) {
+		return fmt.Errorf("SSH: %d servers needed, but only %d nodes given",
+			servers, len(d.Nodes))
+	}
+	if servers == 0 {
+		servers = len(d.Nodes)
+	}
+	d.servers = d.Nodes[:servers]
+	if err := os.RemoveAll(d.deployDir); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(d.deployDir, 0770); err != nil {
+		return err
+	}
+
+	log.Lvl2("SSH: Deploying and writing config-files for", servers, "servers")
+	sim, err := sda.NewSimulation(d.Simulation, string(rc.Toml()))
+	if err != nil {
+		return err
+	}
+	sda.WriteTomlConfig(d, "ssh.toml", d.deployDir)
+	simulConfig, err = sim.Setup(d.deployDir, d.servers)
+	if err != nil {
+		return err
+	}
+	simulConfig.Config = string(rc.Toml())
+	if err := simulConfig.Save(d.deployDir); err != nil {
+		return err
+	}
+	err = exec.Command("cp", d.buildDir+"/"+sshBinary, d.deployDir).Run()
+	if err != nil {
+		return errors.New("Couldn't copy binary, did you build it? " +
+			err.Error())
+	}
+
+	log.Lvl1("Copying over to", len(d.servers), "nodes")
+	errs := make(chan error, len(d.servers))
+	for _, node := range d.servers {
+		go func(node string) {
+			if _, err := SSHRun(d.Login, node, "mkdir -p "+d.RemoteDir); err != nil {
+				errs <- fmt.Errorf("Couldn't create %s on %s: %s", d.RemoteDir, node, err)
+				return
+			}
+			errs <- Rsync(d.Login, node, d.deployDir+"/", d.RemoteDir+"/")
+		}(node)
+	}
+	for range d.servers {
+		if e := <-errs; e != nil {
+			err = e
+		}
+	}
+	if err != nil {
+		return err
+	}
+	log.Lvl2("SSH: Done deploying")
+	return nil
+}
+
+// Start runs the simulation-binary on all nodes. The output of the nodes
+// is shown on stdout and stderr, and the measurements are sent to the
+// monitor at MonitorAddress.
+func (d *SSHCluster) Start(args ...string) error {
+	monitor := d.MonitorAddress + ":" + strconv.Itoa(d.monitorPort)
+	// buffered so that nodes failing after a timeout in Wait don't block
+	d.errChan = make(chan error, len(d.servers)+1)
+	log.Lvl1("Starting", len(d.servers), "nodes")
+	for index, node := range d.servers {
+		d.wgRun.Add(1)
+		cmdArgs := append(args, "-address", node, "-monitor", monitor,
+			"-simul", d.Simulation,
+			"-debug", strconv.Itoa(log.DebugVisible()))
+		run := fmt.Sprintf("cd %s && ./%s %s", d.RemoteDir, sshBinary,
+			strings.Join(cmdArgs, " "))
+		go func(i int, h string) {
+			log.Lvl3("SSH: will start host", h)
+			err := SSHRunStdout(d.Login, h, run)
+			if err != nil {
+				log.Error("Error running ssh", h, ":", err)
+				d.errChan <- err
+			}
+			d.wgRun.Done()
+			log.Lvl3("host (index", i, ")", h, "done")
+		}(index, node)
+	}
+	return nil
+}
+
+// Wait for all nodes to finish, at most CloseWait seconds.
+func (d *SSHCluster) Wait() error {
+	log.Lvl3("Waiting for nodes to finish")
+
+	var err error
+	go func() {
+		d.wgRun.Wait()
+		log.Lvl3("WaitGroup is 0")
+		d.errChan <- nil
+	}()
+
+	// if one of the nodes fails, stop waiting and return the error:
+	select {
+	case e := <-d.errChan:
+		err = e
+	case <-time.After(time.Duration(d.CloseWait) * time.Second):
+		err = fmt.Errorf("Nodes didn't finish after %d seconds", d.CloseWait)
+	}
+	if err != nil {
+		if err := d.Cleanup(); err != nil {
+			log.Error("Couldn't cleanup running nodes", err)
+		}
+	}
+
+	log.Lvl2("Nodes finished")
+	return err
+}
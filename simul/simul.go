@@ -26,19 +26,21 @@ var clean = true
 var build = ""
 var machines = 3
 var monitorPort = monitor.DefaultSinkPort
+var metricsPort = 0
 var simRange = ""
 var race = false
 var runWait = 180
 var experimentWait = 0
 
 func init() {
-	flag.StringVar(&platformDst, "platform", platformDst, "platform to deploy to [deterlab,localhost]")
+	flag.StringVar(&platformDst, "platform", platformDst, "platform to deploy to [deterlab,localhost,docker,ssh]")
 	flag.BoolVar(&nobuild, "nobuild", false, "Don't rebuild all helpers")
 	flag.BoolVar(&clean, "clean", false, "Only clean platform")
 	flag.StringVar(&build, "build", "", "List of packages to build")
 	flag.BoolVar(&race, "race", false, "Build with go's race detection enabled (doesn't work on all platforms)")
 	flag.IntVar(&machines, "machines", machines, "Number of machines on Deterlab")
 	flag.IntVar(&monitorPort, "mport", monitorPort, "Port-number for monitor")
+	flag.IntVar(&metricsPort, "metrics", metricsPort, "Port-number for Prometheus metrics (0 = disabled), e.g. "+strconv.Itoa(monitor.DefaultMetricsPort))
 	flag.StringVar(&simRange, "range", simRange, "Range of simulations to run. 0: or 3:4 or :4")
 	flag.IntVar(&runWait, "runwait", runWait, "How long to wait for each simulation to finish - overwrites .toml-value")
 	flag.IntVar(&experimentWait, "experimentwait", experimentWait, "How long to wait for the whole experiment to finish")
@@ -203,6 +205,7 @@ func RunTest(rc platform.RunConfig) (*monitor.Stats, error) {
 		return rs, err
 	}
 	monitor.SinkPort = monitorPort
+	monitor.MetricsPort = metricsPort
 	go func() {
 		if err := monitor.Listen(); err != nil {
 			log.Fatal("Could not monitor.Listen():", err)